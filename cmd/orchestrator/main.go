@@ -70,11 +70,21 @@ func runServe(args []string) error {
 	githubAppPrivateKeyFile := flags.String("github-app-private-key-file", os.Getenv("GITHUB_APP_PRIVATE_KEY_FILE"), "GitHub App private key PEM file")
 	githubAPIURL := flags.String("github-api-url", os.Getenv("GITHUB_API_URL"), "GitHub API base URL")
 	githubCheckName := flags.String("github-check-name", os.Getenv("GITHUB_CHECK_NAME"), "GitHub check run name")
+	leaseTokenKey := flags.String("lease-token-key", os.Getenv("DELTA_CI_LEASE_TOKEN_KEY"), "Secret (32+ bytes) signing runner lease tokens; shared by all orchestrator processes")
+	jobTimeout := flags.Duration("job-timeout", defaultTimeouts.Job, "Timeout for jobs whose spec sets no timeout_seconds (0 disables)")
+	runTimeout := flags.Duration("run-timeout", defaultTimeouts.Run, "Maximum total runtime of a run (0 disables)")
+	timeoutGrace := flags.Duration("timeout-grace", defaultTimeouts.Grace, "Time runners get to stop a timed-out job before it is forced")
+	runnerSecret := flags.String("runner-secret", os.Getenv("DELTA_CI_RUNNER_SECRET"), "Shared secret pull runners present to request leases (request-lease is disabled when empty)")
+	cancelSuperseded := flags.String("cancel-superseded", os.Getenv("DELTA_CI_CANCEL_SUPERSEDED"), "Comma-separated repo IDs (or *) whose runs are canceled when a newer commit for the same PR or branch arrives")
 	_ = flags.Parse(args)
 
 	if *databaseURL == "" {
 		return errors.New("database-url or DATABASE_URL required")
 	}
+	tokens, err := leaseTokenSigner(*leaseTokenKey)
+	if err != nil {
+		return err
+	}
 
 	ctx := context.Background()
 	db, err := openDB(ctx, *databaseURL)
@@ -94,8 +104,11 @@ func runServe(args []string) error {
 	}
 	plan := planner.NewDiffPlanner("", planner.StaticPlanner{}, orchestrator.NewRecipeStore(store))
 	service := orchestrator.NewService(store, plan, orchestrator.NewQueueDispatcher(store), nil, reporter, nil)
+	service.SetLeaseTokenSigner(tokens)
+	service.SetCancelSuperseded(splitList(*cancelSuperseded))
 	handler := orchestrator.NewHTTPHandler(service, observability.NewLogger("orchestrator.http"), orchestrator.HTTPConfig{
 		GitHubWebhookSecret: *githubWebhookSecret,
+		RunnerSecret:        *runnerSecret,
 	})
 
 	server := &http.Server{
@@ -104,7 +117,11 @@ func runServe(args []string) error {
 		ReadHeaderTimeout: 5 * time.Second,
 	}
 
-	stop := startLeaseSweeper(service, observability.NewLogger("orchestrator.sweeper"), 5*time.Second)
+	stop := startLeaseSweeper(service, observability.NewLogger("orchestrator.sweeper"), 5*time.Second, orchestrator.TimeoutLimits{
+		Job:   *jobTimeout,
+		Run:   *runTimeout,
+		Grace: *timeoutGrace,
+	})
 	defer close(stop)
 
 	return server.ListenAndServe()
@@ -115,15 +132,14 @@ func runDogfood(args []string) error {
 	databaseURL := flags.String("database-url", os.Getenv("DATABASE_URL"), "Postgres DSN")
 	listen := flags.String("listen", ":8080", "Listen address for runner callbacks")
 	repoID := flags.String("repo-id", "delta-ci", "Repository ID")
+	repoURL := flags.String("repo-url", "", "Clone URL or local path runners check out (empty runs in -workdir)")
 	ref := flags.String("ref", "refs/heads/phase_0", "Git ref")
 	commitSHA := flags.String("commit-sha", "local", "Commit SHA placeholder")
 	runnerID := flags.String("runner-id", "dogfood-runner", "Runner ID for the dogfood run")
 	workdir := flags.String("workdir", ".", "Working directory for runner execution")
 	runnerCmd := flags.String("runner-cmd", "go run ./runner", "Command used to launch the runner")
 	logDir := flags.String("runner-log-dir", ".delta-ci/logs", "Directory for runner logs")
-	s3Bucket := flags.String("s3-bucket", "", "S3 bucket for log uploads")
-	s3Prefix := flags.String("s3-prefix", "", "S3 key prefix for log uploads")
-	s3Region := flags.String("s3-region", "", "S3 region for log uploads")
+	artifactsURL := flags.String("artifacts-url", "", "Artifact store URL passed to the runner (file:///path or s3://bucket/prefix)")
 	visibilityTimeout := flags.Duration("visibility-timeout", 30*time.Second, "Queue visibility timeout")
 	continueOnRunnerError := flags.Bool("continue-on-runner-error", false, "Keep the dogfood loop running after a runner error")
 	githubToken := flags.String("github-token", os.Getenv("GITHUB_TOKEN"), "GitHub API token")
@@ -168,11 +184,12 @@ func runDogfood(args []string) error {
 	logger := observability.NewLogger("dogfood")
 	logger.Info("server started", "event", "server_started", "url", baseURL)
 
-	stop := startLeaseSweeper(service, observability.NewLogger("orchestrator.sweeper"), 5*time.Second)
+	stop := startLeaseSweeper(service, observability.NewLogger("orchestrator.sweeper"), 5*time.Second, defaultTimeouts)
 	defer close(stop)
 
 	runDetails, err := service.CreateRun(ctx, orchestrator.CreateRunRequest{
 		RepoID:    *repoID,
+		RepoURL:   *repoURL,
 		Ref:       *ref,
 		CommitSHA: *commitSHA,
 	})
@@ -201,7 +218,10 @@ func runDogfood(args []string) error {
 			break
 		}
 
-		attemptID, err := service.DequeueJobAttempt(ctx, *visibilityTimeout)
+		if _, err := service.RegisterRunner(ctx, *runnerID, observability.BuildVersion(), protocol.HostLabels()); err != nil {
+			return err
+		}
+		attemptID, err := service.DequeueJobAttempt(ctx, *visibilityTimeout, protocol.HostLabels())
 		if err != nil {
 			if errors.Is(err, state.ErrQueueEmpty) {
 				if time.Since(lastActivity) > idleTimeout {
@@ -230,7 +250,7 @@ func runDogfood(args []string) error {
 		}
 
 		logPath := filepath.Join(*logDir, attemptID+".log")
-		if err := runRunner(ctx, *runnerCmd, baseURL, *runnerID, leasePath, *workdir, logPath, *s3Bucket, *s3Prefix, *s3Region); err != nil {
+		if err := runRunner(ctx, *runnerCmd, baseURL, *runnerID, leasePath, *workdir, "", logPath, *artifactsURL); err != nil {
 			logger.Warn("runner exited with error", "event", "runner_failed", "error", err)
 			if *continueOnRunnerError {
 				continue
@@ -285,93 +305,15 @@ func loadGitHubAppKey(rawKey, keyFile string) ([]byte, error) {
 	return []byte(rawKey), nil
 }
 
-func runWorker(args []string) error {
-	flags := flag.NewFlagSet("worker", flag.ExitOnError)
-	databaseURL := flags.String("database-url", os.Getenv("DATABASE_URL"), "Postgres DSN")
-	orchestratorURL := flags.String("orchestrator-url", "http://localhost:8080", "Orchestrator base URL")
-	runnerID := flags.String("runner-id", "local-worker", "Runner ID for leases")
-	workdir := flags.String("workdir", ".", "Working directory for runner execution")
-	runnerCmd := flags.String("runner-cmd", "go run ./runner", "Command used to launch the runner")
-	logDir := flags.String("runner-log-dir", ".delta-ci/logs", "Directory for runner logs")
-	s3Bucket := flags.String("s3-bucket", "", "S3 bucket for log uploads")
-	s3Prefix := flags.String("s3-prefix", "", "S3 key prefix for log uploads")
-	s3Region := flags.String("s3-region", "", "S3 region for log uploads")
-	visibilityTimeout := flags.Duration("visibility-timeout", 30*time.Second, "Queue visibility timeout")
-	pollInterval := flags.Duration("poll-interval", 2*time.Second, "Delay between empty queue polls")
-	continueOnRunnerError := flags.Bool("continue-on-runner-error", true, "Keep worker running after a runner error")
-	_ = flags.Parse(args)
-
-	if *databaseURL == "" {
-		return errors.New("database-url or DATABASE_URL required")
-	}
-	if *orchestratorURL == "" {
-		return errors.New("orchestrator-url required")
-	}
-	if *runnerID == "" {
-		return errors.New("runner-id required")
-	}
-
-	ctx := context.Background()
-	db, err := openDB(ctx, *databaseURL)
-	if err != nil {
-		return err
-	}
-	defer db.Close()
-
-	store := state.NewStore(db)
-	if err := store.ApplyMigrations(ctx); err != nil {
-		return err
-	}
-
-	plan := planner.NewDiffPlanner("", planner.StaticPlanner{}, orchestrator.NewRecipeStore(store))
-	service := orchestrator.NewService(store, plan, orchestrator.NewQueueDispatcher(store), nil, nil, nil)
-	logger := observability.NewLogger("worker")
-
-	if err := os.MkdirAll(*logDir, 0o755); err != nil {
-		return err
-	}
-
-	leaseDir, err := os.MkdirTemp("", "delta-ci-worker-leases")
-	if err != nil {
-		return err
-	}
-
-	logger.Info("worker started", "event", "worker_started", "orchestrator_url", *orchestratorURL)
-
-	for {
-		attemptID, err := service.DequeueJobAttempt(ctx, *visibilityTimeout)
-		if err != nil {
-			if errors.Is(err, state.ErrQueueEmpty) {
-				time.Sleep(*pollInterval)
-				continue
-			}
-			return err
-		}
-
-		lease, err := service.GrantLease(ctx, orchestrator.GrantLeaseRequest{
-			AttemptID:        attemptID,
-			RunnerID:         *runnerID,
-			TTLSeconds:       120,
-			HeartbeatSeconds: 30,
-		})
-		if err != nil {
-			return err
-		}
-
-		leasePath := filepath.Join(leaseDir, attemptID+".json")
-		if err := writeLeaseFile(leasePath, lease); err != nil {
-			return err
-		}
-
-		logPath := filepath.Join(*logDir, attemptID+".log")
-		if err := runRunner(ctx, *runnerCmd, *orchestratorURL, *runnerID, leasePath, *workdir, logPath, *s3Bucket, *s3Prefix, *s3Region); err != nil {
-			logger.Warn("runner exited with error", "event", "runner_failed", "error", err)
-			if *continueOnRunnerError {
-				continue
-			}
-			return err
-		}
+// leaseTokenSigner builds the lease token signer from a configured key. serve
+// and worker run as separate processes and must verify each other's tokens
+// across restarts, so the key is required; only dogfood, which mints and
+// verifies in one process, relies on the per-process random key.
+func leaseTokenSigner(key string) (*orchestrator.LeaseTokenSigner, error) {
+	if key == "" {
+		return nil, errors.New("lease-token-key or DELTA_CI_LEASE_TOKEN_KEY required")
 	}
+	return orchestrator.NewLeaseTokenSigner([]byte(key))
 }
 
 func openDB(ctx context.Context, databaseURL string) (*sql.DB, error) {
@@ -410,7 +352,33 @@ func startServer(handler http.Handler, listen string) (*http.Server, string, err
 	return server, baseURL, nil
 }
 
-func startLeaseSweeper(service *orchestrator.Service, logger *slog.Logger, interval time.Duration) chan struct{} {
+// unroutableJobWindow is how long a labelled job may wait with no recently
+// seen runner able to take it before it fails with "no matching runner".
+const unroutableJobWindow = 10 * time.Minute
+
+// runnerOfflineWindow is how long a runner may go without polling or
+// heartbeating before the inventory marks it OFFLINE.
+const runnerOfflineWindow = 2 * time.Minute
+
+// defaultTimeouts bounds jobs without their own timeout_seconds and whole runs.
+var defaultTimeouts = orchestrator.TimeoutLimits{
+	Job:   time.Hour,
+	Run:   3 * time.Hour,
+	Grace: 2 * time.Minute,
+}
+
+// splitList parses a comma-separated flag value, dropping blank entries.
+func splitList(raw string) []string {
+	var values []string
+	for _, value := range strings.Split(raw, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func startLeaseSweeper(service *orchestrator.Service, logger *slog.Logger, interval time.Duration, timeouts orchestrator.TimeoutLimits) chan struct{} {
 	if interval <= 0 {
 		interval = 5 * time.Second
 	}
@@ -427,6 +395,21 @@ func startLeaseSweeper(service *orchestrator.Service, logger *slog.Logger, inter
 				} else if count > 0 {
 					logger.Info("lease sweep completed", "event", "lease_sweep_completed", "count", count)
 				}
+				if _, err := service.MarkSilentRunnersOffline(context.Background(), runnerOfflineWindow); err != nil {
+					logger.Error("runner sweep failed", "event", "runner_sweep_failed", "error", err)
+				}
+				failed, err := service.FailUnroutableJobs(context.Background(), unroutableJobWindow, 25)
+				if err != nil {
+					logger.Error("unroutable job sweep failed", "event", "unroutable_sweep_failed", "error", err)
+				} else if failed > 0 {
+					logger.Info("unroutable jobs failed", "event", "unroutable_sweep_completed", "count", failed)
+				}
+				stopped, err := service.EnforceTimeouts(context.Background(), timeouts, 25)
+				if err != nil {
+					logger.Error("timeout sweep failed", "event", "timeout_sweep_failed", "error", err)
+				} else if stopped > 0 {
+					logger.Info("timeout sweep completed", "event", "timeout_sweep_completed", "count", stopped)
+				}
 			case <-stop:
 				return
 			}
@@ -443,24 +426,22 @@ func writeLeaseFile(path string, lease protocol.LeaseGranted) error {
 	return os.WriteFile(path, data, 0o600)
 }
 
-func runRunner(ctx context.Context, runnerCmd, baseURL, runnerID, leasePath, workdir, logPath, s3Bucket, s3Prefix, s3Region string) error {
+func runRunner(ctx context.Context, runnerCmd, baseURL, runnerID, leasePath, workdir, workspaceRoot, logPath, artifactsURL string) error {
 	parts := strings.Fields(runnerCmd)
 	if len(parts) == 0 {
 		return errors.New("runner-cmd is empty")
 	}
 
 	args := append(parts[1:], "-orchestrator", baseURL, "-runner-id", runnerID, "-lease", leasePath, "-workdir", workdir, "-log", logPath)
-	if s3Bucket != "" {
-		args = append(args, "-s3-bucket", s3Bucket)
-	}
-	if s3Prefix != "" {
-		args = append(args, "-s3-prefix", s3Prefix)
+	if workspaceRoot != "" {
+		args = append(args, "-workspace-root", workspaceRoot)
 	}
-	if s3Region != "" {
-		args = append(args, "-s3-region", s3Region)
+	if artifactsURL != "" {
+		args = append(args, "-artifacts", artifactsURL)
 	}
 
 	cmd := exec.CommandContext(ctx, parts[0], args...)
+	configureRunnerProcess(cmd)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	cmd.Env = os.Environ()
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package main
+
+import "os/exec"
+
+// configureRunnerProcess keeps the default kill-on-cancel behavior; process
+// groups are not available on this platform.
+func configureRunnerProcess(cmd *exec.Cmd) {}
@@ -0,0 +1,29 @@
+//go:build unix
+
+package main
+
+import (
+	"errors"
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// runnerStopGrace is how long a runner has to cancel its job and report after
+// SIGTERM before it is killed.
+const runnerStopGrace = time.Minute
+
+// configureRunnerProcess starts the runner in its own process group, so
+// terminal signals reach only the worker, and makes context cancellation send
+// SIGTERM to the whole group (including a runner started via "go run").
+func configureRunnerProcess(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		err := syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM)
+		if errors.Is(err, syscall.ESRCH) {
+			return nil
+		}
+		return err
+	}
+	cmd.WaitDelay = runnerStopGrace
+}
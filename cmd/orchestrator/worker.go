@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/izavyalov-dev/delta-ci/internal/observability"
+	"github.com/izavyalov-dev/delta-ci/orchestrator"
+	"github.com/izavyalov-dev/delta-ci/planner"
+	"github.com/izavyalov-dev/delta-ci/protocol"
+	"github.com/izavyalov-dev/delta-ci/state"
+)
+
+// workerConfig holds the settings shared by all worker slots.
+type workerConfig struct {
+	orchestratorURL       string
+	workdir               string
+	workspaceRoot         string
+	runnerCmd             string
+	logDir                string
+	leaseDir              string
+	artifactsURL          string
+	visibilityTimeout     time.Duration
+	pollInterval          time.Duration
+	continueOnRunnerError bool
+	labels                []string
+}
+
+// workerSlot runs one job at a time under its own runner ID and workspace.
+type workerSlot struct {
+	cfg           workerConfig
+	service       *orchestrator.Service
+	logger        *slog.Logger
+	runnerID      string
+	workspaceRoot string
+}
+
+func runWorker(args []string) error {
+	flags := flag.NewFlagSet("worker", flag.ExitOnError)
+	databaseURL := flags.String("database-url", os.Getenv("DATABASE_URL"), "Postgres DSN")
+	orchestratorURL := flags.String("orchestrator-url", "http://localhost:8080", "Orchestrator base URL")
+	runnerID := flags.String("runner-id", "local-worker", "Runner ID for leases (suffixed with the slot number when concurrency > 1)")
+	workdir := flags.String("workdir", ".", "Working directory for runner execution")
+	workspaceRoot := flags.String("workspace-root", filepath.Join(os.TempDir(), "delta-ci", "worker"), "Directory holding one workspace root per slot")
+	runnerCmd := flags.String("runner-cmd", "go run ./runner", "Command used to launch the runner")
+	logDir := flags.String("runner-log-dir", ".delta-ci/logs", "Directory for runner logs")
+	artifactsURL := flags.String("artifacts-url", "", "Artifact store URL passed to the runner (file:///path or s3://bucket/prefix)")
+	visibilityTimeout := flags.Duration("visibility-timeout", 30*time.Second, "Queue visibility timeout")
+	pollInterval := flags.Duration("poll-interval", 2*time.Second, "Delay between empty queue polls")
+	continueOnRunnerError := flags.Bool("continue-on-runner-error", true, "Keep worker running after a runner error")
+	leaseTokenKey := flags.String("lease-token-key", os.Getenv("DELTA_CI_LEASE_TOKEN_KEY"), "Secret (32+ bytes) signing runner lease tokens; must match the serving orchestrator")
+	rawLabels := flags.String("labels", os.Getenv("DELTA_CI_RUNNER_LABELS"), "Comma-separated runner labels (key=value or bare) added to os/arch")
+	concurrency := flags.Int("concurrency", 1, "Number of jobs to run in parallel")
+	shutdownTimeout := flags.Duration("shutdown-timeout", 10*time.Minute, "How long in-flight jobs may keep running after SIGTERM before they are canceled")
+	_ = flags.Parse(args)
+
+	if *databaseURL == "" {
+		return errors.New("database-url or DATABASE_URL required")
+	}
+	if *orchestratorURL == "" {
+		return errors.New("orchestrator-url required")
+	}
+	if *runnerID == "" {
+		return errors.New("runner-id required")
+	}
+	if *concurrency < 1 {
+		return errors.New("concurrency must be at least 1")
+	}
+	tokens, err := leaseTokenSigner(*leaseTokenKey)
+	if err != nil {
+		return err
+	}
+
+	// ctx stops dequeuing on SIGINT/SIGTERM; jobCtx additionally cancels
+	// in-flight runners once the shutdown deadline passes.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	jobCtx, cancelJobs := context.WithCancel(context.Background())
+	defer cancelJobs()
+
+	db, err := openDB(ctx, *databaseURL)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	store := state.NewStore(db)
+	if err := store.ApplyMigrations(ctx); err != nil {
+		return err
+	}
+
+	plan := planner.NewDiffPlanner("", planner.StaticPlanner{}, orchestrator.NewRecipeStore(store))
+	service := orchestrator.NewService(store, plan, orchestrator.NewQueueDispatcher(store), nil, nil, nil)
+	service.SetLeaseTokenSigner(tokens)
+	logger := observability.NewLogger("worker")
+
+	if err := os.MkdirAll(*logDir, 0o755); err != nil {
+		return err
+	}
+
+	leaseDir, err := os.MkdirTemp("", "delta-ci-worker-leases")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(leaseDir)
+
+	cfg := workerConfig{
+		orchestratorURL:       *orchestratorURL,
+		workdir:               *workdir,
+		workspaceRoot:         *workspaceRoot,
+		runnerCmd:             *runnerCmd,
+		logDir:                *logDir,
+		leaseDir:              leaseDir,
+		artifactsURL:          *artifactsURL,
+		visibilityTimeout:     *visibilityTimeout,
+		pollInterval:          *pollInterval,
+		continueOnRunnerError: *continueOnRunnerError,
+		labels:                protocol.NormalizeLabels(append(protocol.HostLabels(), protocol.ParseLabels(*rawLabels)...)),
+	}
+	logger.Info("worker started", "event", "worker_started", "orchestrator_url", cfg.orchestratorURL, "labels", cfg.labels, "concurrency", *concurrency)
+
+	// The first slot to fail stops the others from dequeuing.
+	slotCtx, cancelSlots := context.WithCancelCause(ctx)
+	defer cancelSlots(nil)
+
+	var wg sync.WaitGroup
+	for i := 0; i < *concurrency; i++ {
+		slot := workerSlot{
+			cfg:           cfg,
+			service:       service,
+			runnerID:      slotRunnerID(*runnerID, i, *concurrency),
+			workspaceRoot: filepath.Join(cfg.workspaceRoot, "slot-"+strconv.Itoa(i+1)),
+		}
+		slot.logger = logger.With("runner_id", slot.runnerID)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := slot.run(slotCtx, jobCtx); err != nil {
+				cancelSlots(err)
+			}
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-slotCtx.Done():
+		if ctx.Err() != nil {
+			logger.Info("worker draining", "event", "worker_draining", "shutdown_timeout", shutdownTimeout.String())
+		}
+		timer := time.NewTimer(*shutdownTimeout)
+		defer timer.Stop()
+		select {
+		case <-done:
+		case <-timer.C:
+			logger.Warn("shutdown deadline reached; canceling in-flight jobs", "event", "worker_cancel_jobs")
+			cancelJobs()
+			<-done
+		}
+	}
+
+	if err := context.Cause(slotCtx); err != nil && !errors.Is(err, context.Canceled) {
+		return err
+	}
+	logger.Info("worker stopped", "event", "worker_stopped")
+	return nil
+}
+
+// slotRunnerID keeps the configured runner ID for a single slot and numbers
+// the slots otherwise, so each holds its own leases and inventory entry.
+func slotRunnerID(runnerID string, slot, concurrency int) string {
+	if concurrency <= 1 {
+		return runnerID
+	}
+	return fmt.Sprintf("%s-%d", runnerID, slot+1)
+}
+
+// run dequeues and runs jobs until ctx is canceled. Runners are started with
+// jobCtx so in-flight jobs outlive ctx until the shutdown deadline.
+func (w workerSlot) run(ctx, jobCtx context.Context) error {
+	for ctx.Err() == nil {
+		runner, err := w.service.RegisterRunner(ctx, w.runnerID, observability.BuildVersion(), w.cfg.labels)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		if runner.State == state.RunnerStateDraining {
+			sleepContext(ctx, w.cfg.pollInterval)
+			continue
+		}
+		attemptID, err := w.service.DequeueJobAttempt(ctx, w.cfg.visibilityTimeout, w.cfg.labels)
+		if err != nil {
+			if errors.Is(err, state.ErrQueueEmpty) {
+				sleepContext(ctx, w.cfg.pollInterval)
+				continue
+			}
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		if err := w.runAttempt(jobCtx, attemptID); err != nil {
+			w.logger.Warn("runner exited with error", "event", "runner_failed", "attempt_id", attemptID, "error", err)
+			if !w.cfg.continueOnRunnerError && ctx.Err() == nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// runAttempt grants a lease for attemptID and runs it to completion. A lease
+// the runner did not finish is revoked so the attempt is requeued right away.
+func (w workerSlot) runAttempt(ctx context.Context, attemptID string) error {
+	// Grant on a background context: a shutdown signal must not leave the
+	// attempt dequeued with a lease half-written.
+	lease, err := w.service.GrantLease(context.Background(), orchestrator.GrantLeaseRequest{
+		AttemptID:        attemptID,
+		RunnerID:         w.runnerID,
+		TTLSeconds:       120,
+		HeartbeatSeconds: 30,
+	})
+	if err != nil {
+		return err
+	}
+
+	leasePath := filepath.Join(w.cfg.leaseDir, attemptID+".json")
+	defer os.Remove(leasePath)
+	runErr := writeLeaseFile(leasePath, lease)
+	if runErr == nil {
+		logPath := filepath.Join(w.cfg.logDir, attemptID+".log")
+		runErr = runRunner(ctx, w.cfg.runnerCmd, w.cfg.orchestratorURL, w.runnerID, leasePath, w.cfg.workdir, w.workspaceRoot, logPath, w.cfg.artifactsURL)
+	}
+	if runErr == nil && ctx.Err() == nil {
+		return nil
+	}
+
+	// The runner failed or was stopped. If its lease is still open, hand the
+	// attempt back instead of waiting for the lease to expire.
+	if err := w.service.RevokeLease(context.Background(), lease.LeaseID); err != nil && !errors.Is(err, orchestrator.ErrStaleLease) {
+		w.logger.Warn("revoke lease", "event", "lease_revoke_failed", "lease_id", lease.LeaseID, "error", err)
+	}
+	return runErr
+}
+
+// sleepContext waits for d or until ctx is canceled.
+func sleepContext(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
@@ -0,0 +1,56 @@
+package observability
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// RunnerMetrics collects counters reported by runner processes.
+type RunnerMetrics struct {
+	cacheEvictions    prometheus.Counter
+	cacheEvictedBytes prometheus.Counter
+	cacheBytes        prometheus.Gauge
+}
+
+func NewRunnerMetrics(registerer prometheus.Registerer) *RunnerMetrics {
+	if registerer == nil {
+		registerer = prometheus.DefaultRegisterer
+	}
+
+	evictions := prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "delta_runner_cache_evictions_total",
+		Help: "Total cache entries evicted by garbage collection.",
+	})
+	evictedBytes := prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "delta_runner_cache_evicted_bytes_total",
+		Help: "Total bytes freed by cache garbage collection.",
+	})
+	size := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "delta_runner_cache_bytes",
+		Help: "Size of the local cache after the last garbage collection.",
+	})
+
+	return &RunnerMetrics{
+		cacheEvictions:    registerCollector(registerer, evictions),
+		cacheEvictedBytes: registerCollector(registerer, evictedBytes),
+		cacheBytes:        registerCollector(registerer, size),
+	}
+}
+
+// ObserveCacheGC records the outcome of a cache garbage collection pass.
+func (m *RunnerMetrics) ObserveCacheGC(evicted int, freedBytes, remainingBytes int64) {
+	if m == nil {
+		return
+	}
+	m.cacheEvictions.Add(float64(evicted))
+	m.cacheEvictedBytes.Add(float64(freedBytes))
+	m.cacheBytes.Set(float64(remainingBytes))
+}
+
+func registerCollector[T prometheus.Collector](registerer prometheus.Registerer, collector T) T {
+	if err := registerer.Register(collector); err != nil {
+		if already, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			if existing, ok := already.ExistingCollector.(T); ok {
+				return existing
+			}
+		}
+	}
+	return collector
+}
@@ -0,0 +1,21 @@
+package observability
+
+import "runtime/debug"
+
+// BuildVersion describes the running binary: the module version when built
+// from a tagged release, otherwise the VCS revision, falling back to "devel".
+func BuildVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "devel"
+	}
+	if v := info.Main.Version; v != "" && v != "(devel)" {
+		return v
+	}
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" && len(setting.Value) >= 12 {
+			return setting.Value[:12]
+		}
+	}
+	return "devel"
+}
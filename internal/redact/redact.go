@@ -0,0 +1,207 @@
+// Package redact masks secret values in job output and reported text.
+package redact
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"net/url"
+	"sort"
+	"sync"
+
+	"github.com/izavyalov-dev/delta-ci/protocol"
+)
+
+// Mask replaces every redacted value.
+const Mask = "***"
+
+// minSecretLen skips values too short to mask without destroying output.
+const minSecretLen = 4
+
+// Redactor replaces secret values, and their common encodings, with Mask.
+// A nil Redactor leaves input unchanged.
+type Redactor struct {
+	needles [][]byte
+	maxLen  int
+}
+
+// New builds a Redactor for the given secret values. Base64 (standard and
+// URL alphabets, with and without padding) and URL-encoded variants are
+// masked as well. It returns nil when there is nothing to mask.
+func New(secrets []string) *Redactor {
+	seen := make(map[string]bool)
+	var needles [][]byte
+	add := func(value string) {
+		if len(value) < minSecretLen || seen[value] {
+			return
+		}
+		seen[value] = true
+		needles = append(needles, []byte(value))
+	}
+	for _, secret := range secrets {
+		if len(secret) < minSecretLen {
+			continue
+		}
+		raw := []byte(secret)
+		add(secret)
+		add(base64.StdEncoding.EncodeToString(raw))
+		add(base64.RawStdEncoding.EncodeToString(raw))
+		add(base64.URLEncoding.EncodeToString(raw))
+		add(base64.RawURLEncoding.EncodeToString(raw))
+		add(url.QueryEscape(secret))
+		add(url.PathEscape(secret))
+	}
+	if len(needles) == 0 {
+		return nil
+	}
+
+	// Longer needles first so overlapping matches mask as much as possible.
+	sort.Slice(needles, func(i, j int) bool {
+		return len(needles[i]) > len(needles[j])
+	})
+	return &Redactor{needles: needles, maxLen: len(needles[0])}
+}
+
+// ForJobSpec builds a Redactor for the env values a JobSpec marks as secret.
+func ForJobSpec(spec protocol.JobSpec) *Redactor {
+	secrets := make([]string, 0, len(spec.SecretEnv))
+	for _, key := range spec.SecretEnv {
+		if value, ok := spec.Env[key]; ok {
+			secrets = append(secrets, value)
+		}
+	}
+	return New(secrets)
+}
+
+// String masks secrets in s.
+func (r *Redactor) String(s string) string {
+	if r == nil || s == "" {
+		return s
+	}
+	return string(r.Bytes([]byte(s)))
+}
+
+// Bytes masks secrets in data and returns a new slice.
+func (r *Redactor) Bytes(data []byte) []byte {
+	if r == nil {
+		return data
+	}
+	var out bytes.Buffer
+	pos := 0
+	for {
+		start, length := r.next(data, pos)
+		if start < 0 {
+			break
+		}
+		out.Write(data[pos:start])
+		out.WriteString(Mask)
+		pos = start + length
+	}
+	out.Write(data[pos:])
+	return out.Bytes()
+}
+
+// Overwrite masks secrets in data by replacing each matched byte with '*',
+// keeping the length unchanged so byte offsets into the data stay valid.
+func (r *Redactor) Overwrite(data []byte) []byte {
+	if r == nil {
+		return data
+	}
+	out := bytes.Clone(data)
+	pos := 0
+	for {
+		start, length := r.next(out, pos)
+		if start < 0 {
+			return out
+		}
+		for i := start; i < start+length; i++ {
+			out[i] = '*'
+		}
+		pos = start + length
+	}
+}
+
+// next returns the earliest match at or after pos, preferring the longest
+// needle, or -1 when there is none.
+func (r *Redactor) next(data []byte, pos int) (int, int) {
+	start, length := -1, 0
+	for _, needle := range r.needles {
+		idx := bytes.Index(data[pos:], needle)
+		if idx < 0 {
+			continue
+		}
+		idx += pos
+		if start < 0 || idx < start || (idx == start && len(needle) > length) {
+			start, length = idx, len(needle)
+		}
+	}
+	return start, length
+}
+
+// Writer masks secrets in a byte stream. It holds back just enough trailing
+// output to recognise a secret split across writes; Flush releases it.
+type Writer struct {
+	mu      sync.Mutex
+	w       io.Writer
+	r       *Redactor
+	pending []byte
+}
+
+// NewWriter wraps w. With a nil Redactor writes pass straight through.
+func NewWriter(w io.Writer, r *Redactor) *Writer {
+	return &Writer{w: w, r: r}
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	if w.r == nil {
+		return w.w.Write(p)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	buf := append(w.pending, p...)
+	// A match starting before safe is complete within buf; anything after it
+	// may still be the beginning of a secret.
+	safe := len(buf) - (w.r.maxLen - 1)
+
+	var out bytes.Buffer
+	pos := 0
+	for pos < safe {
+		start, length := w.r.next(buf, pos)
+		if start < 0 || start >= safe {
+			out.Write(buf[pos:safe])
+			pos = safe
+			break
+		}
+		out.Write(buf[pos:start])
+		out.WriteString(Mask)
+		pos = start + length
+	}
+	w.pending = append(w.pending[:0:0], buf[pos:]...)
+
+	if out.Len() > 0 {
+		if _, err := w.w.Write(out.Bytes()); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush masks and writes any held-back output.
+func (w *Writer) Flush() error {
+	if w.r == nil {
+		return nil
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.pending) == 0 {
+		return nil
+	}
+	data := w.r.Bytes(w.pending)
+	w.pending = nil
+	_, err := w.w.Write(data)
+	return err
+}
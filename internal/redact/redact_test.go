@@ -0,0 +1,65 @@
+package redact
+
+import (
+	"bytes"
+	"encoding/base64"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestRedactorMasksEncodedVariants(t *testing.T) {
+	secret := "s3cr3t/token+value"
+	r := New([]string{secret, "ab"})
+
+	input := strings.Join([]string{
+		"raw=" + secret,
+		"b64=" + base64.StdEncoding.EncodeToString([]byte(secret)),
+		"url=" + url.QueryEscape(secret),
+		"short=ab",
+	}, "\n")
+	got := r.String(input)
+
+	for _, leaked := range []string{secret, base64.StdEncoding.EncodeToString([]byte(secret)), url.QueryEscape(secret)} {
+		if strings.Contains(got, leaked) {
+			t.Fatalf("secret variant %q leaked in %q", leaked, got)
+		}
+	}
+	if !strings.Contains(got, "short=ab") {
+		t.Fatalf("expected short value to be left alone, got %q", got)
+	}
+	if New(nil) != nil {
+		t.Fatal("expected nil redactor without secrets")
+	}
+}
+
+func TestWriterMasksSecretsSplitAcrossWrites(t *testing.T) {
+	secret := "hunter2-password"
+	var out bytes.Buffer
+	w := NewWriter(&out, New([]string{secret}))
+
+	input := "login with " + secret + " then " + secret + "!"
+	// Feed one byte at a time so every secret straddles write boundaries.
+	for i := 0; i < len(input); i++ {
+		if _, err := w.Write([]byte{input[i]}); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+
+	want := "login with *** then ***!"
+	if out.String() != want {
+		t.Fatalf("expected %q, got %q", want, out.String())
+	}
+}
+
+func TestOverwriteKeepsLength(t *testing.T) {
+	r := New([]string{"topsecret"})
+	data := []byte("a topsecret b")
+	got := r.Overwrite(data)
+	if len(got) != len(data) || string(got) != "a ********* b" {
+		t.Fatalf("unexpected overwrite result %q", got)
+	}
+}
@@ -61,7 +61,8 @@ func (r *Reporter) ReportRun(ctx context.Context, runID string) error {
 	if err != nil && !errors.Is(err, state.ErrNotFound) {
 		return err
 	}
-	if report.LastState == string(run.State) && report.LastState != "" {
+	// A running run is re-reported when a job moves to another step.
+	if report.LastState == string(run.State) && report.LastState != "" && run.State != state.RunStateRunning {
 		return nil
 	}
 
@@ -69,6 +70,23 @@ func (r *Reporter) ReportRun(ctx context.Context, runID string) error {
 	if err != nil {
 		return err
 	}
+	jobProgress := make(map[string]*state.JobProgress, len(jobs))
+	for _, job := range jobs {
+		if job.State != state.JobStateRunning {
+			continue
+		}
+		attempt, err := r.store.GetLatestJobAttempt(ctx, job.ID)
+		if err != nil {
+			return err
+		}
+		if attempt.Progress != nil {
+			jobProgress[job.ID] = attempt.Progress
+		}
+	}
+	progressKey := progressFingerprint(jobs, jobProgress)
+	if report.LastState == string(run.State) && report.LastProgress == progressKey {
+		return nil
+	}
 	var plan *state.RunPlan
 	runPlan, err := r.store.GetRunPlan(ctx, runID)
 	if err != nil {
@@ -80,6 +98,7 @@ func (r *Reporter) ReportRun(ctx context.Context, runID string) error {
 	}
 	jobArtifacts := make(map[string][]state.Artifact, len(jobs))
 	jobFailures := make(map[string]*state.FailureExplanation, len(jobs))
+	jobFailedTests := make(map[string][]state.TestResult, len(jobs))
 	for _, job := range jobs {
 		artifacts, err := r.store.ListArtifactsByJob(ctx, job.ID)
 		if err != nil {
@@ -94,9 +113,15 @@ func (r *Reporter) ReportRun(ctx context.Context, runID string) error {
 		if len(explanations) > 0 {
 			jobFailures[job.ID] = &explanations[0]
 		}
+
+		failedTests, err := r.store.ListFailedTestsByJob(ctx, job.ID)
+		if err != nil {
+			return err
+		}
+		jobFailedTests[job.ID] = latestAttemptTests(failedTests)
 	}
 
-	title, summary := buildSummary(run, plan, jobs, jobArtifacts, jobFailures)
+	title, summary := buildSummary(run, plan, jobs, jobArtifacts, jobFailures, jobFailedTests, jobProgress)
 	checkReq := buildCheckRun(r.checkName, run, title, summary)
 
 	checkRunID := report.CheckRunID
@@ -158,11 +183,12 @@ func (r *Reporter) ReportRun(ctx context.Context, runID string) error {
 	}
 
 	_, err = r.store.UpsertStatusReport(ctx, state.StatusReport{
-		RunID:       runID,
-		Provider:    trigger.Provider,
-		CheckRunID:  checkRunID,
-		PRCommentID: prCommentID,
-		LastState:   string(run.State),
+		RunID:        runID,
+		Provider:     trigger.Provider,
+		CheckRunID:   checkRunID,
+		PRCommentID:  prCommentID,
+		LastState:    string(run.State),
+		LastProgress: progressKey,
 	})
 	if err != nil {
 		return err
@@ -241,13 +267,19 @@ func isReportableTerminal(stateValue state.RunState) bool {
 	}
 }
 
-func buildSummary(run state.Run, plan *state.RunPlan, jobs []state.Job, artifacts map[string][]state.Artifact, failures map[string]*state.FailureExplanation) (string, string) {
+func buildSummary(run state.Run, plan *state.RunPlan, jobs []state.Job, artifacts map[string][]state.Artifact, failures map[string]*state.FailureExplanation, failedTests map[string][]state.TestResult, progress map[string]*state.JobProgress) (string, string) {
 	title := fmt.Sprintf("Delta CI: %s", run.State)
+	if run.SupersededBy != nil {
+		title += " (superseded)"
+	}
 	var b strings.Builder
 	fmt.Fprintf(&b, "Run `%s`\n\n", run.ID)
 	fmt.Fprintf(&b, "State: `%s`\n", run.State)
 	fmt.Fprintf(&b, "Ref: `%s`\n", run.Ref)
 	fmt.Fprintf(&b, "Commit: `%s`\n", run.CommitSHA)
+	if run.SupersededBy != nil {
+		fmt.Fprintf(&b, "Superseded by: run `%s` for a newer commit\n", sanitize(*run.SupersededBy))
+	}
 	if plan != nil {
 		if plan.RecipeSource != "" {
 			fmt.Fprintf(&b, "Plan source: `%s`\n", sanitize(plan.RecipeSource))
@@ -276,10 +308,19 @@ func buildSummary(run state.Run, plan *state.RunPlan, jobs []state.Job, artifact
 		if job.Reason != "" {
 			fmt.Fprintf(&b, "  Reason: %s\n", sanitize(job.Reason))
 		}
+		if p := progress[job.ID]; p != nil {
+			writeProgress(&b, p)
+		}
 		if job.State == state.JobStateFailed || job.State == state.JobStateTimedOut {
 			if failure := failures[job.ID]; failure != nil {
 				fmt.Fprintf(&b, "  Failure: %s (%s/%s)\n", sanitize(failure.Summary), sanitize(string(failure.Category)), sanitize(string(failure.Confidence)))
 			}
+			writeFailedTests(&b, failedTests[job.ID])
+		}
+		if job.State == state.JobStateSucceeded {
+			if failure := failures[job.ID]; failure != nil && failure.Category == state.FailureCategoryFlaky {
+				fmt.Fprintf(&b, "  Flaky: %s\n", sanitize(failure.Summary))
+			}
 		}
 		arts := artifacts[job.ID]
 		if len(arts) == 0 {
@@ -297,6 +338,74 @@ func buildSummary(run state.Run, plan *state.RunPlan, jobs []state.Job, artifact
 	return title, b.String()
 }
 
+func writeProgress(b *strings.Builder, p *state.JobProgress) {
+	b.WriteString("  Progress: ")
+	if p.StepIndex >= 0 {
+		fmt.Fprintf(b, "step %d/%d", p.StepIndex+1, p.StepCount)
+		if p.StepName != "" {
+			fmt.Fprintf(b, " `%s`", sanitize(p.StepName))
+		}
+	} else {
+		b.WriteString("preparing")
+	}
+	fmt.Fprintf(b, ", %s elapsed", time.Duration(p.ElapsedSeconds)*time.Second)
+	if p.Status != "" {
+		fmt.Fprintf(b, " (%s)", sanitize(p.Status))
+	}
+	b.WriteString("\n")
+}
+
+// progressFingerprint identifies the steps shown for running jobs, so reports
+// are refreshed on step changes only.
+func progressFingerprint(jobs []state.Job, progress map[string]*state.JobProgress) string {
+	var parts []string
+	for _, job := range jobs {
+		if p := progress[job.ID]; p != nil {
+			parts = append(parts, fmt.Sprintf("%s:%d", job.ID, p.StepIndex))
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// maxListedTests bounds the failed tests listed per job in a summary.
+const maxListedTests = 20
+
+func writeFailedTests(b *strings.Builder, tests []state.TestResult) {
+	if len(tests) == 0 {
+		return
+	}
+	fmt.Fprintf(b, "  Failed tests (%d):\n", len(tests))
+	for i, test := range tests {
+		if i == maxListedTests {
+			fmt.Fprintf(b, "  - ... and %d more\n", len(tests)-maxListedTests)
+			break
+		}
+		name := test.Name
+		if name == "" {
+			name = "(package)"
+		}
+		if test.Package != "" {
+			fmt.Fprintf(b, "  - `%s` %s\n", sanitize(test.Package), sanitize(name))
+		} else {
+			fmt.Fprintf(b, "  - %s\n", sanitize(name))
+		}
+	}
+}
+
+// latestAttemptTests keeps results from the most recent attempt; results are
+// ordered by attempt.
+func latestAttemptTests(tests []state.TestResult) []state.TestResult {
+	if len(tests) == 0 {
+		return nil
+	}
+	latest := tests[len(tests)-1].JobAttemptID
+	start := len(tests) - 1
+	for start > 0 && tests[start-1].JobAttemptID == latest {
+		start--
+	}
+	return tests[start:]
+}
+
 func buildComment(run state.Run, summary string) string {
 	var b strings.Builder
 	b.WriteString("<!-- delta-ci run:")
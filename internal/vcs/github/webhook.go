@@ -23,6 +23,7 @@ type WebhookEvent struct {
 	RepoID    string
 	RepoOwner string
 	RepoName  string
+	CloneURL  string
 	Ref       string
 	CommitSHA string
 	PRNumber  *int
@@ -97,6 +98,7 @@ func ComputeEventKey(repoID, commitSHA, eventType string, prNumber *int) (string
 type repoRef struct {
 	FullName string `json:"full_name"`
 	Name     string `json:"name"`
+	CloneURL string `json:"clone_url"`
 	Owner    struct {
 		Login string `json:"login"`
 	} `json:"owner"`
@@ -126,6 +128,7 @@ func normalizePush(body []byte) (WebhookEvent, bool, error) {
 		RepoID:    repoID,
 		RepoOwner: owner,
 		RepoName:  name,
+		CloneURL:  strings.TrimSpace(evt.Repository.CloneURL),
 		Ref:       evt.Ref,
 		CommitSHA: evt.After,
 	}, true, nil
@@ -164,6 +167,7 @@ func normalizePullRequest(body []byte) (WebhookEvent, bool, error) {
 		RepoID:    repoID,
 		RepoOwner: owner,
 		RepoName:  name,
+		CloneURL:  strings.TrimSpace(evt.Repository.CloneURL),
 		Ref:       ref,
 		CommitSHA: evt.PullRequest.Head.SHA,
 		PRNumber:  &prNumber,
@@ -13,6 +13,12 @@ type Dispatcher interface {
 	EnqueueJobAttempt(ctx context.Context, attempt state.JobAttempt) error
 }
 
+// DelayedDispatcher is implemented by dispatchers that can hold an attempt back
+// until availableAt, e.g. for retry backoff.
+type DelayedDispatcher interface {
+	EnqueueJobAttemptAt(ctx context.Context, attempt state.JobAttempt, availableAt time.Time) error
+}
+
 // QueueDispatcher publishes attempts to the Postgres-backed queue.
 type QueueDispatcher struct {
 	store *state.Store
@@ -29,6 +35,13 @@ func (d QueueDispatcher) EnqueueJobAttempt(ctx context.Context, attempt state.Jo
 	return d.store.EnqueueJobAttempt(ctx, attempt.ID, time.Now().UTC())
 }
 
+func (d QueueDispatcher) EnqueueJobAttemptAt(ctx context.Context, attempt state.JobAttempt, availableAt time.Time) error {
+	if d.store == nil {
+		return errors.New("queue dispatcher requires store")
+	}
+	return d.store.EnqueueJobAttempt(ctx, attempt.ID, availableAt)
+}
+
 // NoopDispatcher is a placeholder dispatcher used during early bootstrapping.
 type NoopDispatcher struct{}
 
@@ -24,6 +24,10 @@ type FailureInput struct {
 	ExitCode  int
 	Summary   string
 	Artifacts []state.ArtifactRef
+	// FailedTests names failed tests reported by the runner.
+	FailedTests []string
+	// Resources is the runner-reported usage; OOMKilled comes from cgroup events.
+	Resources *protocol.ResourceUsage
 }
 
 // FailureAnalyzer produces a failure explanation for a job attempt.
@@ -63,24 +67,34 @@ func (a *RuleBasedFailureAnalyzer) Analyze(ctx context.Context, input FailureInp
 	if input.AttemptID == "" {
 		return nil, fmt.Errorf("attempt id required for failure analysis")
 	}
-	if input.Status != protocol.CompleteStatusFailed {
+	if input.Status != protocol.CompleteStatusFailed && input.Status != protocol.CompleteStatusTimedOut {
 		return nil, nil
 	}
 
 	summary := sanitizeText(input.Summary, a.MaxSummaryLen)
 	category, confidence, concise := classifyFailure(input.JobName, summary, input.ExitCode)
+	switch {
+	case input.Resources != nil && input.Resources.OOMKilled:
+		category, confidence = state.FailureCategoryInfra, state.FailureConfidenceHigh
+		concise = fmt.Sprintf("Out of memory: killed by the OOM killer (peak %s).", formatBytes(input.Resources.PeakMemoryBytes))
+	case len(input.FailedTests) > 0 && input.Status == protocol.CompleteStatusFailed:
+		category, confidence = state.FailureCategoryUser, state.FailureConfidenceHigh
+		concise = sanitizeText(describeFailedTests(input.FailedTests), a.MaxSummaryLen)
+	}
 
 	details := buildFailureDetails(input, summary, a.MaxDetailsLen)
 	if a.EnableAI && a.Advisor != nil {
 		if aiSummary, err := a.Advisor.Explain(ctx, FailureInput{
-			RunID:     input.RunID,
-			JobID:     input.JobID,
-			JobName:   input.JobName,
-			AttemptID: input.AttemptID,
-			Status:    input.Status,
-			ExitCode:  input.ExitCode,
-			Summary:   summary,
-			Artifacts: input.Artifacts,
+			RunID:       input.RunID,
+			JobID:       input.JobID,
+			JobName:     input.JobName,
+			AttemptID:   input.AttemptID,
+			Status:      input.Status,
+			ExitCode:    input.ExitCode,
+			Summary:     summary,
+			Artifacts:   input.Artifacts,
+			FailedTests: input.FailedTests,
+			Resources:   input.Resources,
 		}); err == nil {
 			aiSummary = sanitizeText(aiSummary, a.MaxDetailsLen)
 			if aiSummary != "" {
@@ -105,7 +119,7 @@ func classifyFailure(jobName, summary string, exitCode int) (state.FailureCatego
 	switch {
 	case containsAny(lower, "timed out", "timeout", "deadline exceeded") || exitCode == 124:
 		return state.FailureCategoryInfra, state.FailureConfidenceMedium, fmt.Sprintf("Job timed out (exit code %d).", exitCode)
-	case containsAny(lower, "out of memory", "no space", "disk full", "signal: killed", "killed"):
+	case containsAny(lower, "out of memory", "no space", "disk full"):
 		return state.FailureCategoryInfra, state.FailureConfidenceHigh, fmt.Sprintf("Resource exhaustion detected (exit code %d).", exitCode)
 	case containsAny(lower, "dial tcp", "connection refused", "i/o timeout", "temporary failure", "tls handshake timeout"):
 		return state.FailureCategoryInfra, state.FailureConfidenceHigh, fmt.Sprintf("Network error detected (exit code %d).", exitCode)
@@ -124,6 +138,44 @@ func classifyFailure(jobName, summary string, exitCode int) (state.FailureCatego
 	}
 }
 
+func formatBytes(n int64) string {
+	const unit = 1 << 20
+	if n < unit {
+		return fmt.Sprintf("%d KiB", n>>10)
+	}
+	return fmt.Sprintf("%d MiB", n/unit)
+}
+
+func describeFailedTests(tests []string) string {
+	const shown = 3
+	noun := "tests"
+	if len(tests) == 1 {
+		noun = "test"
+	}
+	names := tests
+	if len(names) > shown {
+		names = names[:shown]
+	}
+	text := fmt.Sprintf("%d %s failed: %s", len(tests), noun, strings.Join(names, ", "))
+	if len(tests) > shown {
+		text += fmt.Sprintf(" and %d more", len(tests)-shown)
+	}
+	return text + "."
+}
+
+// testDisplayName formats a test as package.Name; package-level failures
+// (empty name) are shown as the package alone.
+func testDisplayName(pkg, name string) string {
+	switch {
+	case name == "":
+		return pkg + " (package)"
+	case pkg == "":
+		return name
+	default:
+		return pkg + "." + name
+	}
+}
+
 func buildFailureDetails(input FailureInput, summary string, maxLen int) string {
 	details := ""
 	if summary != "" && !isGenericSummary(summary) {
@@ -0,0 +1,68 @@
+package orchestrator
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/izavyalov-dev/delta-ci/protocol"
+	"github.com/izavyalov-dev/delta-ci/state"
+)
+
+func TestRuleBasedFailureAnalyzerOOM(t *testing.T) {
+	analyzer := NewRuleBasedFailureAnalyzer()
+	explanation, err := analyzer.Analyze(context.Background(), FailureInput{
+		AttemptID: "attempt-1",
+		JobName:   "go-test",
+		Status:    protocol.CompleteStatusFailed,
+		ExitCode:  137,
+		Summary:   "exit status 137",
+		Resources: &protocol.ResourceUsage{PeakMemoryBytes: 512 << 20, OOMKilled: true},
+	})
+	if err != nil {
+		t.Fatalf("analyze: %v", err)
+	}
+	if explanation.Category != state.FailureCategoryInfra || explanation.Confidence != state.FailureConfidenceHigh {
+		t.Fatalf("unexpected classification %s/%s", explanation.Category, explanation.Confidence)
+	}
+	if !strings.Contains(explanation.Summary, "OOM killer") || !strings.Contains(explanation.Summary, "512 MiB") {
+		t.Fatalf("unexpected summary %q", explanation.Summary)
+	}
+}
+
+func TestRuleBasedFailureAnalyzerKilledWithoutOOMIsNotResourceExhaustion(t *testing.T) {
+	analyzer := NewRuleBasedFailureAnalyzer()
+	explanation, err := analyzer.Analyze(context.Background(), FailureInput{
+		AttemptID: "attempt-1",
+		JobName:   "build",
+		Status:    protocol.CompleteStatusFailed,
+		ExitCode:  1,
+		Summary:   "signal: killed",
+		Resources: &protocol.ResourceUsage{PeakMemoryBytes: 64 << 20},
+	})
+	if err != nil {
+		t.Fatalf("analyze: %v", err)
+	}
+	if strings.Contains(explanation.Summary, "Resource exhaustion") || strings.Contains(explanation.Summary, "OOM") {
+		t.Fatalf("expected no memory classification, got %q", explanation.Summary)
+	}
+}
+
+func TestRuleBasedFailureAnalyzerFailedTests(t *testing.T) {
+	analyzer := NewRuleBasedFailureAnalyzer()
+	explanation, err := analyzer.Analyze(context.Background(), FailureInput{
+		AttemptID:   "attempt-1",
+		JobName:     "go-test",
+		Status:      protocol.CompleteStatusFailed,
+		ExitCode:    1,
+		Summary:     "exit status 1",
+		FailedTests: []string{"pkg/a.TestOne", "pkg/a.TestTwo", "pkg/b.TestThree", "pkg/b.TestFour"},
+	})
+	if err != nil {
+		t.Fatalf("analyze: %v", err)
+	}
+	want := "4 tests failed: pkg/a.TestOne, pkg/a.TestTwo, pkg/b.TestThree and 1 more."
+	if explanation.Summary != want {
+		t.Fatalf("expected %q, got %q", want, explanation.Summary)
+	}
+}
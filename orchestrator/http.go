@@ -1,13 +1,19 @@
 package orchestrator
 
 import (
+	"context"
+	"crypto/subtle"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/izavyalov-dev/delta-ci/internal/observability"
 	"github.com/izavyalov-dev/delta-ci/internal/vcs/github"
@@ -15,12 +21,25 @@ import (
 	"github.com/izavyalov-dev/delta-ci/state"
 )
 
-// HTTPConfig controls public webhook handling.
+// maxLeaseWait caps how long a lease request may be held open.
+const maxLeaseWait = 30 * time.Second
+
+// HTTPConfig controls public webhook handling and runner authentication.
 type HTTPConfig struct {
 	GitHubWebhookSecret   string
 	GitHubWebhookMaxBytes int64
+	// RunnerSecret is the shared bearer credential pull runners present to
+	// request-lease. Empty disables the endpoint.
+	RunnerSecret string
 }
 
+var (
+	// errInvalidRunnerSecret is returned when a lease request lacks the runner secret.
+	errInvalidRunnerSecret = errors.New("invalid runner secret")
+	// errPullRunnersDisabled is returned by request-lease when no runner secret is configured.
+	errPullRunnersDisabled = errors.New("pull runners disabled: runner secret not configured")
+)
+
 // NewHTTPHandler wires minimal internal endpoints for runner protocol and metrics.
 func NewHTTPHandler(service *Service, logger *slog.Logger, config HTTPConfig) http.Handler {
 	if logger == nil {
@@ -36,6 +55,55 @@ func NewHTTPHandler(service *Service, logger *slog.Logger, config HTTPConfig) ht
 		w.WriteHeader(http.StatusOK)
 	})
 
+	mux.HandleFunc("/api/v1/internal/request-lease", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if !authorizeRunner(w, r, config.RunnerSecret) {
+			return
+		}
+		var msg protocol.RequestLease
+		if err := decodeJSON(r, &msg); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if msg.RunnerID == "" {
+			writeError(w, http.StatusBadRequest, errors.New("runner_id is required"))
+			return
+		}
+		wait := time.Duration(msg.WaitSeconds) * time.Second
+		if wait < 0 {
+			wait = 0
+		}
+		if wait > maxLeaseWait {
+			wait = maxLeaseWait
+		}
+		lease, err := service.RequestLease(r.Context(), RequestLeaseRequest{
+			RunnerID: msg.RunnerID,
+			Version:  msg.Version,
+			Labels:   msg.Labels,
+			Wait:     wait,
+		})
+		if err != nil {
+			if errors.Is(err, ErrNoLeaseAvailable) {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			logger.Error("request lease failed", "event", "lease_request_failed", "runner_id", msg.RunnerID, "error", err)
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		if err := writeGrantedLease(w, r, lease); err != nil {
+			// The runner never saw the lease; hand it back so the attempt is
+			// requeued now instead of when the lease expires.
+			logger.Warn("lease not delivered", "event", "lease_undelivered", "lease_id", lease.LeaseID, "runner_id", msg.RunnerID, "error", err)
+			if err := service.RevokeLease(context.WithoutCancel(r.Context()), lease.LeaseID); err != nil && !errors.Is(err, ErrStaleLease) {
+				logger.Error("revoke undelivered lease failed", "event", "lease_revoke_failed", "lease_id", lease.LeaseID, "error", err)
+			}
+		}
+	})
+
 	mux.HandleFunc("/api/v1/internal/ack-lease", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
 			w.WriteHeader(http.StatusMethodNotAllowed)
@@ -46,6 +114,9 @@ func NewHTTPHandler(service *Service, logger *slog.Logger, config HTTPConfig) ht
 			writeError(w, http.StatusBadRequest, err)
 			return
 		}
+		if !authorizeLease(w, r, service, msg.LeaseID, msg.RunnerID) {
+			return
+		}
 		if err := service.AckLease(r.Context(), msg); err != nil {
 			if errors.Is(err, ErrStaleLease) {
 				writeError(w, http.StatusConflict, err)
@@ -68,6 +139,9 @@ func NewHTTPHandler(service *Service, logger *slog.Logger, config HTTPConfig) ht
 			writeError(w, http.StatusBadRequest, err)
 			return
 		}
+		if !authorizeLease(w, r, service, msg.LeaseID, msg.RunnerID) {
+			return
+		}
 		ack, err := service.HandleHeartbeat(r.Context(), msg)
 		if err != nil {
 			if errors.Is(err, ErrStaleLease) {
@@ -81,6 +155,35 @@ func NewHTTPHandler(service *Service, logger *slog.Logger, config HTTPConfig) ht
 		writeJSON(w, http.StatusOK, ack)
 	})
 
+	mux.HandleFunc("/api/v1/internal/log", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		var msg protocol.LogChunk
+		if err := decodeJSON(r, &msg); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if !authorizeLease(w, r, service, msg.LeaseID, msg.RunnerID) {
+			return
+		}
+		if err := service.AppendLog(r.Context(), msg); err != nil {
+			if errors.Is(err, ErrStaleLease) {
+				writeError(w, http.StatusConflict, err)
+				return
+			}
+			if errors.Is(err, state.ErrNotFound) {
+				writeError(w, http.StatusNotFound, err)
+				return
+			}
+			logger.Error("log chunk failed", "event", "log_chunk_failed", "error", err)
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+
 	mux.HandleFunc("/api/v1/internal/complete", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
 			w.WriteHeader(http.StatusMethodNotAllowed)
@@ -91,6 +194,9 @@ func NewHTTPHandler(service *Service, logger *slog.Logger, config HTTPConfig) ht
 			writeError(w, http.StatusBadRequest, err)
 			return
 		}
+		if !authorizeLease(w, r, service, msg.LeaseID, msg.RunnerID) {
+			return
+		}
 		if err := service.CompleteLease(r.Context(), msg); err != nil {
 			if errors.Is(err, ErrStaleLease) {
 				writeError(w, http.StatusConflict, err)
@@ -113,6 +219,9 @@ func NewHTTPHandler(service *Service, logger *slog.Logger, config HTTPConfig) ht
 			writeError(w, http.StatusBadRequest, err)
 			return
 		}
+		if !authorizeLease(w, r, service, msg.LeaseID, msg.RunnerID) {
+			return
+		}
 		if err := service.CancelLease(r.Context(), msg); err != nil {
 			if errors.Is(err, ErrStaleLease) {
 				writeError(w, http.StatusConflict, err)
@@ -174,6 +283,7 @@ func NewHTTPHandler(service *Service, logger *slog.Logger, config HTTPConfig) ht
 
 		runDetails, created, err := service.CreateRunFromTrigger(r.Context(), CreateRunRequest{
 			RepoID:    normalized.RepoID,
+			RepoURL:   normalized.CloneURL,
 			Ref:       normalized.Ref,
 			CommitSHA: normalized.CommitSHA,
 		}, state.RunTrigger{
@@ -201,7 +311,81 @@ func NewHTTPHandler(service *Service, logger *slog.Logger, config HTTPConfig) ht
 		})
 	})
 
+	mux.HandleFunc("/api/v1/runs", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			req, err := parseListRunsQuery(r.URL.Query())
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err)
+				return
+			}
+			page, err := service.ListRuns(r.Context(), req)
+			if err != nil {
+				if errors.Is(err, ErrInvalidCursor) {
+					writeError(w, http.StatusBadRequest, err)
+					return
+				}
+				writeError(w, http.StatusInternalServerError, err)
+				return
+			}
+			writeJSON(w, http.StatusOK, page)
+			return
+		}
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		var body createRunBody
+		if err := decodeJSON(r, &body); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		idempotencyKey := r.Header.Get("Idempotency-Key")
+		details, created, err := service.RequestRun(r.Context(), CreateRunRequest{
+			RepoID:     body.RepoID,
+			RepoURL:    body.RepoURL,
+			Ref:        body.Ref,
+			CommitSHA:  body.CommitSHA,
+			FullRun:    body.FullRun,
+			Parameters: body.Parameters,
+		}, idempotencyKey)
+		if err != nil {
+			if errors.Is(err, ErrInvalidRunRequest) {
+				writeError(w, http.StatusBadRequest, err)
+				return
+			}
+			if errors.Is(err, state.ErrIdempotencyKeyReused) {
+				writeError(w, http.StatusUnprocessableEntity, err)
+				return
+			}
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		status := http.StatusOK
+		if created {
+			status = http.StatusCreated
+		}
+		writeJSON(w, status, map[string]string{
+			"run_id":  details.Run.ID,
+			"state":   string(details.Run.State),
+			"created": fmt.Sprintf("%t", created),
+		})
+	})
+
 	mux.HandleFunc("/api/v1/runs/", func(w http.ResponseWriter, r *http.Request) {
+		if runID, jobID, ok := parseJobLogPath(r.URL.Path); ok {
+			if r.Method != http.MethodGet {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			if r.URL.Query().Get("follow") == "1" {
+				streamJobLog(w, r, service, runID, jobID)
+				return
+			}
+			serveJobLog(w, r, service, runID, jobID)
+			return
+		}
+
 		runID, action, ok := parseRunPath(r.URL.Path)
 		if !ok {
 			w.WriteHeader(http.StatusNotFound)
@@ -285,6 +469,53 @@ func NewHTTPHandler(service *Service, logger *slog.Logger, config HTTPConfig) ht
 		}
 	})
 
+	mux.HandleFunc("/api/v1/runners", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		runners, err := service.ListRunners(r.Context())
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"runners": runners})
+	})
+
+	mux.HandleFunc("/api/v1/runners/", func(w http.ResponseWriter, r *http.Request) {
+		runnerID, action, ok := parseRunnerPath(r.URL.Path)
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		var runner state.Runner
+		var err error
+		switch action {
+		case "drain":
+			runner, err = service.DrainRunner(r.Context(), runnerID)
+		case "offline":
+			runner, err = service.MarkRunnerOffline(r.Context(), runnerID)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			if errors.Is(err, state.ErrNotFound) {
+				writeError(w, http.StatusNotFound, err)
+				return
+			}
+			logger.Error("update runner failed", "event", "runner_update_failed", "runner_id", runnerID, "action", action, "error", err)
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, runner)
+	})
+
 	return mux
 }
 
@@ -323,6 +554,338 @@ func parseRunPath(path string) (string, string, bool) {
 	}
 }
 
+// parseListRunsQuery reads GET /api/v1/runs filters and paging parameters.
+func parseListRunsQuery(query url.Values) (ListRunsRequest, error) {
+	req := ListRunsRequest{
+		Filter: state.RunFilter{
+			RepoID:      query.Get("repo_id"),
+			Ref:         query.Get("ref"),
+			CommitSHA:   query.Get("commit_sha"),
+			TriggerType: query.Get("trigger_type"),
+		},
+		Cursor: query.Get("cursor"),
+	}
+	if raw := query.Get("state"); raw != "" {
+		runState := state.RunState(strings.ToUpper(raw))
+		if !state.ValidRunState(runState) {
+			return ListRunsRequest{}, fmt.Errorf("unknown run state %q", raw)
+		}
+		req.Filter.State = runState
+	}
+	if raw := query.Get("pr_number"); raw != "" {
+		prNumber, err := strconv.Atoi(raw)
+		if err != nil || prNumber <= 0 {
+			return ListRunsRequest{}, fmt.Errorf("invalid pr_number %q", raw)
+		}
+		req.Filter.PRNumber = prNumber
+	}
+	for name, target := range map[string]*time.Time{
+		"created_after":  &req.Filter.CreatedAfter,
+		"created_before": &req.Filter.CreatedBefore,
+	} {
+		raw := query.Get(name)
+		if raw == "" {
+			continue
+		}
+		ts, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return ListRunsRequest{}, fmt.Errorf("invalid %s: %w", name, err)
+		}
+		*target = ts
+	}
+	if raw := query.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit <= 0 {
+			return ListRunsRequest{}, fmt.Errorf("invalid limit %q", raw)
+		}
+		req.Limit = limit
+	}
+	return req, nil
+}
+
+// parseRunnerPath matches /api/v1/runners/{runner}/{action}.
+func parseRunnerPath(path string) (string, string, bool) {
+	path = strings.TrimPrefix(path, "/api/v1/runners/")
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+// parseJobLogPath matches /api/v1/runs/{run}/jobs/{job}/log.
+func parseJobLogPath(path string) (string, string, bool) {
+	path = strings.TrimPrefix(path, "/api/v1/runs/")
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) != 4 || parts[1] != "jobs" || parts[3] != "log" || parts[0] == "" || parts[2] == "" {
+		return "", "", false
+	}
+	return parts[0], parts[2], true
+}
+
+// serveJobLog returns the buffered log of the attempt named by ?attempt= (the
+// latest by default), honouring a single "bytes=" range.
+func serveJobLog(w http.ResponseWriter, r *http.Request, service *Service, runID, jobID string) {
+	attempt, err := parseLogAttempt(r.URL.Query().Get("attempt"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	offset, length, ranged, err := parseByteRange(r.Header.Get("Range"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	jobLog, err := service.ReadJobLog(r.Context(), runID, jobID, attempt, offset, length)
+	if err != nil {
+		if errors.Is(err, state.ErrNotFound) {
+			writeError(w, http.StatusNotFound, err)
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Accept-Ranges", "bytes")
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Log-Attempt", strconv.Itoa(jobLog.AttemptNumber))
+	w.Header().Set("X-Log-Size", strconv.FormatInt(jobLog.Size, 10))
+	w.Header().Set("X-Log-Complete", strconv.FormatBool(jobLog.Complete))
+	if !ranged {
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(jobLog.Data)
+		return
+	}
+	if len(jobLog.Data) == 0 {
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", jobLog.Size))
+		w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+		return
+	}
+	last := jobLog.Offset + int64(len(jobLog.Data)) - 1
+	w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", jobLog.Offset, last, jobLog.Size))
+	w.WriteHeader(http.StatusPartialContent)
+	_, _ = w.Write(jobLog.Data)
+}
+
+// parseLogAttempt parses the ?attempt= log parameter; zero selects the latest
+// attempt.
+func parseLogAttempt(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	attempt, err := strconv.Atoi(value)
+	if err != nil || attempt <= 0 {
+		return 0, errors.New("invalid attempt")
+	}
+	return attempt, nil
+}
+
+// parseLogEventID parses a log stream resume position: "attempt:offset" as
+// sent in event ids, or a bare offset.
+func parseLogEventID(value string) (int, int64, error) {
+	attempt := 0
+	if attemptValue, offsetValue, ok := strings.Cut(value, ":"); ok {
+		var err error
+		if attempt, err = parseLogAttempt(attemptValue); err != nil || attempt == 0 {
+			return 0, 0, errors.New("invalid offset")
+		}
+		value = offsetValue
+	}
+	offset, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || offset < 0 {
+		return 0, 0, errors.New("invalid offset")
+	}
+	return attempt, offset, nil
+}
+
+// parseByteRange parses a single-range "bytes=start-end", "bytes=start-" or
+// "bytes=-suffix" header into an offset and length for Service.ReadJobLog.
+func parseByteRange(header string) (int64, int64, bool, error) {
+	if header == "" {
+		return 0, 0, false, nil
+	}
+	spec, ok := strings.CutPrefix(header, "bytes=")
+	if !ok || strings.Contains(spec, ",") {
+		return 0, 0, false, errors.New("unsupported range")
+	}
+	startValue, endValue, ok := strings.Cut(spec, "-")
+	if !ok {
+		return 0, 0, false, errors.New("invalid range")
+	}
+	if startValue == "" {
+		suffix, err := strconv.ParseInt(endValue, 10, 64)
+		if err != nil || suffix <= 0 {
+			return 0, 0, false, errors.New("invalid range")
+		}
+		return -suffix, 0, true, nil
+	}
+	start, err := strconv.ParseInt(startValue, 10, 64)
+	if err != nil || start < 0 {
+		return 0, 0, false, errors.New("invalid range")
+	}
+	if endValue == "" {
+		return start, 0, true, nil
+	}
+	end, err := strconv.ParseInt(endValue, 10, 64)
+	if err != nil || end < start {
+		return 0, 0, false, errors.New("invalid range")
+	}
+	return start, end - start + 1, true, nil
+}
+
+const (
+	// logFollowInterval is how often a following client is checked for new output.
+	logFollowInterval = 500 * time.Millisecond
+	// logFollowReadBytes bounds a single log event.
+	logFollowReadBytes = 64 << 10
+)
+
+// streamJobLog follows one attempt's log as Server-Sent Events. The attempt is
+// ?attempt=, the one named by Last-Event-ID, or the latest when the stream
+// opens; it stays pinned so offsets never jump to another attempt's log. Each
+// "log" event carries {"offset","data"} and uses "attempt:next offset" as its
+// id, so clients resume with Last-Event-ID. An "end" event is sent once the
+// attempt has finished or a newer attempt has started.
+func streamJobLog(w http.ResponseWriter, r *http.Request, service *Service, runID, jobID string) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, errors.New("streaming unsupported"))
+		return
+	}
+
+	attempt, err := parseLogAttempt(r.URL.Query().Get("attempt"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	var offset int64
+	resume := r.Header.Get("Last-Event-ID")
+	if resume == "" {
+		resume = r.URL.Query().Get("offset")
+	}
+	if resume != "" {
+		resumeAttempt, value, err := parseLogEventID(resume)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if attempt == 0 {
+			attempt = resumeAttempt
+		}
+		offset = value
+	}
+
+	// Validate the job and pin the attempt before committing to an event
+	// stream.
+	jobLog, err := service.ReadJobLog(r.Context(), runID, jobID, attempt, offset, 1)
+	if err != nil {
+		if errors.Is(err, state.ErrNotFound) {
+			writeError(w, http.StatusNotFound, err)
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	attempt = jobLog.AttemptNumber
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ticker := time.NewTicker(logFollowInterval)
+	defer ticker.Stop()
+	for {
+		jobLog, err := service.ReadJobLog(r.Context(), runID, jobID, attempt, offset, logFollowReadBytes)
+		if err != nil {
+			return
+		}
+		// A newer attempt means this one will not grow any further.
+		final := jobLog.Complete || jobLog.Superseded
+		data := jobLog.Data
+		if !final {
+			// Hold back a multi-byte character split across chunks.
+			data = data[:validUTF8Prefix(data)]
+		}
+		if len(data) > 0 {
+			payload, _ := json.Marshal(map[string]any{"offset": offset, "data": string(data)})
+			offset += int64(len(data))
+			fmt.Fprintf(w, "id: %d:%d\nevent: log\ndata: %s\n\n", attempt, offset, payload)
+			flusher.Flush()
+		}
+		if final && (offset >= jobLog.Size || len(data) == 0) {
+			payload, _ := json.Marshal(map[string]any{"size": jobLog.Size, "attempt": attempt, "superseded": jobLog.Superseded})
+			fmt.Fprintf(w, "event: end\ndata: %s\n\n", payload)
+			flusher.Flush()
+			return
+		}
+		if len(data) == logFollowReadBytes {
+			// More output is already buffered; keep reading without waiting.
+			continue
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// validUTF8Prefix returns the length of data without a trailing incomplete
+// UTF-8 sequence.
+func validUTF8Prefix(data []byte) int {
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				return i
+			}
+			break
+		}
+	}
+	return len(data)
+}
+
+// authorizeLease rejects runner messages that lack a valid bearer token for
+// the lease and runner they name.
+func authorizeLease(w http.ResponseWriter, r *http.Request, service *Service, leaseID, runnerID string) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		writeError(w, http.StatusUnauthorized, ErrUnauthorized)
+		return false
+	}
+	if err := service.VerifyLeaseToken(strings.TrimSpace(token), leaseID, runnerID); err != nil {
+		writeError(w, http.StatusUnauthorized, err)
+		return false
+	}
+	return true
+}
+
+// authorizeRunner checks the shared runner secret that request-lease requires
+// before it hands out a lease, and with it a lease token, to the caller.
+func authorizeRunner(w http.ResponseWriter, r *http.Request, secret string) bool {
+	if secret == "" {
+		writeError(w, http.StatusForbidden, errPullRunnersDisabled)
+		return false
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(strings.TrimSpace(token)), []byte(secret)) != 1 {
+		writeError(w, http.StatusUnauthorized, errInvalidRunnerSecret)
+		return false
+	}
+	return true
+}
+
+// createRunBody is the request body of POST /api/v1/runs.
+type createRunBody struct {
+	RepoID     string            `json:"repo_id"`
+	RepoURL    string            `json:"repo_url,omitempty"`
+	Ref        string            `json:"ref"`
+	CommitSHA  string            `json:"commit_sha"`
+	FullRun    bool              `json:"full_run,omitempty"`
+	Parameters map[string]string `json:"parameters,omitempty"`
+}
+
 func decodeJSON(r *http.Request, target any) error {
 	dec := json.NewDecoder(r.Body)
 	dec.DisallowUnknownFields()
@@ -335,6 +898,21 @@ func writeJSON(w http.ResponseWriter, status int, payload any) {
 	_ = json.NewEncoder(w).Encode(payload)
 }
 
+// writeGrantedLease writes a LeaseGranted response and flushes it, reporting
+// whether it reached the connection. A runner that disconnected during the
+// long poll yields an error.
+func writeGrantedLease(w http.ResponseWriter, r *http.Request, lease protocol.LeaseGranted) error {
+	if err := r.Context().Err(); err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(lease); err != nil {
+		return err
+	}
+	return http.NewResponseController(w).Flush()
+}
+
 func writeError(w http.ResponseWriter, status int, err error) {
 	writeJSON(w, status, map[string]string{"error": err.Error()})
 }
@@ -344,9 +922,15 @@ func sanitizeRunDetails(details RunDetails) RunDetails {
 		for j := range details.Jobs[i].Attempts {
 			details.Jobs[i].Attempts[j].LeaseID = nil
 		}
+		if details.Jobs[i].Steps == nil {
+			details.Jobs[i].Steps = []state.JobStep{}
+		}
 		if details.Jobs[i].Artifacts == nil {
 			details.Jobs[i].Artifacts = []state.Artifact{}
 		}
+		if details.Jobs[i].FailedTests == nil {
+			details.Jobs[i].FailedTests = []state.TestResult{}
+		}
 		if details.Jobs[i].FailureExplanations == nil {
 			details.Jobs[i].FailureExplanations = []state.FailureExplanation{}
 		}
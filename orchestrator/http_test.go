@@ -0,0 +1,174 @@
+package orchestrator
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/izavyalov-dev/delta-ci/protocol"
+	"github.com/izavyalov-dev/delta-ci/state"
+)
+
+func TestParseByteRange(t *testing.T) {
+	cases := []struct {
+		header string
+		offset int64
+		length int64
+		ranged bool
+	}{
+		{header: "", ranged: false},
+		{header: "bytes=0-99", offset: 0, length: 100, ranged: true},
+		{header: "bytes=100-", offset: 100, length: 0, ranged: true},
+		{header: "bytes=-500", offset: -500, length: 0, ranged: true},
+	}
+	for _, tc := range cases {
+		offset, length, ranged, err := parseByteRange(tc.header)
+		if err != nil {
+			t.Fatalf("%q: unexpected error %v", tc.header, err)
+		}
+		if offset != tc.offset || length != tc.length || ranged != tc.ranged {
+			t.Fatalf("%q: got offset=%d length=%d ranged=%v", tc.header, offset, length, ranged)
+		}
+	}
+
+	for _, header := range []string{"bytes=5-1", "bytes=0-1,4-5", "items=0-1", "bytes=-0", "bytes=x-"} {
+		if _, _, _, err := parseByteRange(header); err == nil {
+			t.Fatalf("%q: expected error", header)
+		}
+	}
+}
+
+func TestParseJobLogPath(t *testing.T) {
+	runID, jobID, ok := parseJobLogPath("/api/v1/runs/run-1/jobs/job-1/log")
+	if !ok || runID != "run-1" || jobID != "job-1" {
+		t.Fatalf("unexpected parse result %q %q %v", runID, jobID, ok)
+	}
+	for _, path := range []string{"/api/v1/runs/run-1", "/api/v1/runs/run-1/cancel", "/api/v1/runs/run-1/jobs//log"} {
+		if _, _, ok := parseJobLogPath(path); ok {
+			t.Fatalf("%q: expected no match", path)
+		}
+	}
+}
+
+func TestParseRunnerPath(t *testing.T) {
+	runnerID, action, ok := parseRunnerPath("/api/v1/runners/runner-01/drain")
+	if !ok || runnerID != "runner-01" || action != "drain" {
+		t.Fatalf("unexpected parse result %q %q %v", runnerID, action, ok)
+	}
+	for _, path := range []string{"/api/v1/runners/", "/api/v1/runners/runner-01", "/api/v1/runners//drain", "/api/v1/runners/a/b/c"} {
+		if _, _, ok := parseRunnerPath(path); ok {
+			t.Fatalf("%q: expected no match", path)
+		}
+	}
+}
+
+func TestParseListRunsQuery(t *testing.T) {
+	query, _ := url.ParseQuery("repo_id=repo&ref=refs/heads/main&state=running&pr_number=123&trigger_type=pull_request&created_after=2026-01-01T00:00:00Z&limit=20&cursor=abc")
+	req, err := parseListRunsQuery(query)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if req.Filter.RepoID != "repo" || req.Filter.Ref != "refs/heads/main" || req.Filter.State != state.RunStateRunning {
+		t.Fatalf("unexpected filter %+v", req.Filter)
+	}
+	if req.Filter.PRNumber != 123 || req.Filter.TriggerType != "pull_request" || req.Limit != 20 || req.Cursor != "abc" {
+		t.Fatalf("unexpected request %+v", req)
+	}
+	if !req.Filter.CreatedAfter.Equal(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)) || !req.Filter.CreatedBefore.IsZero() {
+		t.Fatalf("unexpected created range %v - %v", req.Filter.CreatedAfter, req.Filter.CreatedBefore)
+	}
+
+	for _, raw := range []string{"state=bogus", "pr_number=0", "pr_number=x", "created_before=yesterday", "limit=-1"} {
+		query, _ := url.ParseQuery(raw)
+		if _, err := parseListRunsQuery(query); err == nil {
+			t.Fatalf("%q: expected error", raw)
+		}
+	}
+}
+
+func TestValidUTF8PrefixHoldsBackSplitRune(t *testing.T) {
+	data := []byte("ok ✓")
+	if got := validUTF8Prefix(data); got != len(data) {
+		t.Fatalf("expected full length, got %d", got)
+	}
+	if got := validUTF8Prefix(data[:len(data)-1]); got != 3 {
+		t.Fatalf("expected split rune to be held back, got %d", got)
+	}
+}
+
+func TestRequestLeaseRequiresRunnerSecret(t *testing.T) {
+	cases := []struct {
+		secret string
+		header string
+		status int
+	}{
+		{secret: "", header: "Bearer anything", status: http.StatusForbidden},
+		{secret: "runner-secret", header: "", status: http.StatusUnauthorized},
+		{secret: "runner-secret", header: "Bearer wrong", status: http.StatusUnauthorized},
+	}
+	for _, tc := range cases {
+		handler := NewHTTPHandler(nil, nil, HTTPConfig{RunnerSecret: tc.secret})
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/internal/request-lease", strings.NewReader(`{"type":"RequestLease","runner_id":"runner-1"}`))
+		if tc.header != "" {
+			req.Header.Set("Authorization", tc.header)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != tc.status {
+			t.Fatalf("secret %q header %q: expected %d, got %d", tc.secret, tc.header, tc.status, rec.Code)
+		}
+	}
+}
+
+func TestWriteGrantedLeaseReportsDisconnectedRunner(t *testing.T) {
+	lease := protocol.LeaseGranted{Type: "LeaseGranted", LeaseID: "lease-1", JobID: "job-1"}
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/internal/request-lease", nil)
+	if err := writeGrantedLease(rec, req, lease); err != nil {
+		t.Fatalf("write lease: %v", err)
+	}
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"lease_id":"lease-1"`) {
+		t.Fatalf("unexpected response %d %s", rec.Code, rec.Body.String())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	rec = httptest.NewRecorder()
+	if err := writeGrantedLease(rec, req.WithContext(ctx), lease); err == nil {
+		t.Fatalf("expected error for a disconnected runner")
+	}
+	if rec.Body.Len() != 0 {
+		t.Fatalf("expected nothing written, got %q", rec.Body.String())
+	}
+}
+
+func TestParseLogEventID(t *testing.T) {
+	cases := []struct {
+		value   string
+		attempt int
+		offset  int64
+	}{
+		{value: "128", attempt: 0, offset: 128},
+		{value: "2:4096", attempt: 2, offset: 4096},
+	}
+	for _, tc := range cases {
+		attempt, offset, err := parseLogEventID(tc.value)
+		if err != nil {
+			t.Fatalf("%q: unexpected error %v", tc.value, err)
+		}
+		if attempt != tc.attempt || offset != tc.offset {
+			t.Fatalf("%q: got attempt=%d offset=%d", tc.value, attempt, offset)
+		}
+	}
+
+	for _, value := range []string{"", "-1", "0:10", "x:10", "2:", "2:-5"} {
+		if _, _, err := parseLogEventID(value); err == nil {
+			t.Fatalf("%q: expected error", value)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package orchestrator
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrUnauthorized is returned when a runner message lacks a valid lease token.
+var ErrUnauthorized = errors.New("invalid lease token")
+
+// minLeaseTokenKeyBytes is the shortest accepted HMAC key.
+const minLeaseTokenKeyBytes = 32
+
+// leaseTokenPrefix versions the token format.
+const leaseTokenPrefix = "v1."
+
+// LeaseTokenSigner mints and verifies HMAC-SHA256 bearer tokens that bind
+// runner messages to one lease and one runner. Tokens expire with the lease
+// TTL; heartbeats hand out fresh ones.
+type LeaseTokenSigner struct {
+	key []byte
+}
+
+type leaseTokenClaims struct {
+	LeaseID   string `json:"lid"`
+	RunnerID  string `json:"rid"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// NewLeaseTokenSigner returns a signer for key, which must be at least 32 bytes.
+func NewLeaseTokenSigner(key []byte) (*LeaseTokenSigner, error) {
+	if len(key) < minLeaseTokenKeyBytes {
+		return nil, fmt.Errorf("lease token key must be at least %d bytes", minLeaseTokenKeyBytes)
+	}
+	return &LeaseTokenSigner{key: append([]byte(nil), key...)}, nil
+}
+
+// newRandomLeaseTokenSigner is used when no key is configured; its tokens do
+// not survive a restart and are not accepted by other orchestrator processes.
+func newRandomLeaseTokenSigner() *LeaseTokenSigner {
+	key := make([]byte, minLeaseTokenKeyBytes)
+	if _, err := rand.Read(key); err != nil {
+		panic(fmt.Sprintf("generate lease token key: %v", err))
+	}
+	return &LeaseTokenSigner{key: key}
+}
+
+// Mint returns a token for leaseID held by runnerID, valid until expires.
+func (s *LeaseTokenSigner) Mint(leaseID, runnerID string, expires time.Time) string {
+	payload, _ := json.Marshal(leaseTokenClaims{LeaseID: leaseID, RunnerID: runnerID, ExpiresAt: expires.Unix()})
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return leaseTokenPrefix + encoded + "." + base64.RawURLEncoding.EncodeToString(s.sign(encoded))
+}
+
+// Verify checks the signature, expiry and that the token was minted for
+// leaseID and runnerID.
+func (s *LeaseTokenSigner) Verify(token, leaseID, runnerID string, now time.Time) error {
+	rest, ok := strings.CutPrefix(token, leaseTokenPrefix)
+	if !ok {
+		return ErrUnauthorized
+	}
+	encoded, signature, ok := strings.Cut(rest, ".")
+	if !ok {
+		return ErrUnauthorized
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, s.sign(encoded)) {
+		return ErrUnauthorized
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return ErrUnauthorized
+	}
+	var claims leaseTokenClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return ErrUnauthorized
+	}
+	if claims.LeaseID != leaseID || claims.RunnerID != runnerID {
+		return fmt.Errorf("%w: not issued for this lease and runner", ErrUnauthorized)
+	}
+	if now.Unix() > claims.ExpiresAt {
+		return fmt.Errorf("%w: expired", ErrUnauthorized)
+	}
+	return nil
+}
+
+func (s *LeaseTokenSigner) sign(encoded string) []byte {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(encoded))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,42 @@
+package orchestrator
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLeaseTokenBindsLeaseAndRunner(t *testing.T) {
+	signer, err := NewLeaseTokenSigner([]byte(strings.Repeat("k", 32)))
+	if err != nil {
+		t.Fatalf("new signer: %v", err)
+	}
+	now := time.Now()
+	token := signer.Mint("lease-1", "runner-1", now.Add(time.Minute))
+
+	if err := signer.Verify(token, "lease-1", "runner-1", now); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+
+	// The payload always starts with `{"lid"`, which encodes to "eyJ".
+	tampered := leaseTokenPrefix + "x" + token[len(leaseTokenPrefix)+1:]
+	other, _ := NewLeaseTokenSigner([]byte(strings.Repeat("x", 32)))
+	cases := map[string]error{
+		"other runner": signer.Verify(token, "lease-1", "runner-2", now),
+		"other lease":  signer.Verify(token, "lease-2", "runner-1", now),
+		"expired":      signer.Verify(token, "lease-1", "runner-1", now.Add(2*time.Minute)),
+		"tampered":     signer.Verify(tampered, "lease-1", "runner-1", now),
+		"other key":    other.Verify(token, "lease-1", "runner-1", now),
+		"missing":      signer.Verify("", "lease-1", "runner-1", now),
+	}
+	for name, err := range cases {
+		if !errors.Is(err, ErrUnauthorized) {
+			t.Fatalf("%s: expected ErrUnauthorized, got %v", name, err)
+		}
+	}
+
+	if _, err := NewLeaseTokenSigner([]byte("short")); err == nil {
+		t.Fatal("expected short key to be rejected")
+	}
+}
@@ -0,0 +1,122 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/izavyalov-dev/delta-ci/protocol"
+	"github.com/izavyalov-dev/delta-ci/state"
+)
+
+// maxLogChunkBytes bounds a single streamed log chunk.
+const maxLogChunkBytes = 1 << 20
+
+// JobLog is a byte range of one attempt's streamed log.
+type JobLog struct {
+	AttemptID     string
+	AttemptNumber int
+	Data          []byte
+	Offset        int64
+	// Size is the number of log bytes received so far.
+	Size int64
+	// Complete reports that the attempt has finished, so Size is final.
+	Complete bool
+	// Superseded reports that a newer attempt of the job exists.
+	Superseded bool
+}
+
+// AppendLog buffers a streamed log chunk for an active lease.
+func (s *Service) AppendLog(ctx context.Context, msg protocol.LogChunk) error {
+	if msg.LeaseID == "" {
+		return errors.New("lease_id is required")
+	}
+	if msg.Seq < 0 || msg.Offset < 0 {
+		return errors.New("seq and offset must be non-negative")
+	}
+	if len(msg.Data) > maxLogChunkBytes {
+		return fmt.Errorf("log chunk exceeds %d bytes", maxLogChunkBytes)
+	}
+
+	lease, err := s.store.GetLease(ctx, msg.LeaseID)
+	if err != nil {
+		return err
+	}
+	if lease.State != state.LeaseStateActive || (lease.RunnerID != nil && *lease.RunnerID != msg.RunnerID) {
+		s.metrics.IncFailure("stale_lease")
+		return ErrStaleLease
+	}
+
+	attempt, err := s.store.GetJobAttempt(ctx, lease.JobAttemptID)
+	if err != nil {
+		return err
+	}
+
+	// Runners mask secrets before shipping; this catches values that still
+	// appear whole within a chunk. Overwrite keeps the chunk length, so the
+	// runner's offsets stay valid.
+	return s.store.AppendLogChunk(ctx, state.LogChunk{
+		JobAttemptID: lease.JobAttemptID,
+		Seq:          msg.Seq,
+		Offset:       msg.Offset,
+		Data:         s.jobRedactor(ctx, attempt.JobID).Overwrite(msg.Data),
+	})
+}
+
+// ReadJobLog returns part of an attempt's log for a job: attemptNumber, or the
+// latest attempt when it is zero. A negative offset reads the last -offset
+// bytes; a non-positive length reads to the end.
+func (s *Service) ReadJobLog(ctx context.Context, runID, jobID string, attemptNumber int, offset, length int64) (JobLog, error) {
+	job, err := s.store.GetJob(ctx, jobID)
+	if err != nil {
+		return JobLog{}, err
+	}
+	if job.RunID != runID {
+		return JobLog{}, fmt.Errorf("%w: job %s in run %s", state.ErrNotFound, jobID, runID)
+	}
+
+	latest, err := s.store.GetLatestJobAttempt(ctx, job.ID)
+	if err != nil {
+		return JobLog{}, err
+	}
+	attempt := latest
+	if attemptNumber > 0 && attemptNumber != latest.AttemptNumber {
+		attempts, err := s.store.ListJobAttempts(ctx, job.ID)
+		if err != nil {
+			return JobLog{}, err
+		}
+		found := false
+		for _, candidate := range attempts {
+			if candidate.AttemptNumber == attemptNumber {
+				attempt, found = candidate, true
+				break
+			}
+		}
+		if !found {
+			return JobLog{}, fmt.Errorf("%w: attempt %d of job %s", state.ErrNotFound, attemptNumber, jobID)
+		}
+	}
+
+	data, start, size, err := s.store.ReadJobLog(ctx, attempt.ID, offset, length)
+	if err != nil {
+		return JobLog{}, err
+	}
+	return JobLog{
+		AttemptID:     attempt.ID,
+		AttemptNumber: attempt.AttemptNumber,
+		Data:          data,
+		Offset:        start,
+		Size:          size,
+		Complete:      isAttemptFinished(attempt.State),
+		Superseded:    attempt.AttemptNumber < latest.AttemptNumber,
+	}, nil
+}
+
+func isAttemptFinished(jobState state.JobState) bool {
+	switch jobState {
+	case state.JobStateSucceeded, state.JobStateFailed, state.JobStateCanceled, state.JobStateTimedOut, state.JobStateStale:
+		return true
+	default:
+		return false
+	}
+}
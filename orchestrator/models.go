@@ -1,12 +1,23 @@
 package orchestrator
 
-import "github.com/izavyalov-dev/delta-ci/state"
+import (
+	"time"
+
+	"github.com/izavyalov-dev/delta-ci/state"
+)
 
 // CreateRunRequest captures inputs to start a new run.
 type CreateRunRequest struct {
-	RepoID    string
+	RepoID string
+	// RepoURL is the clone URL or local path runners fetch the commit from.
+	RepoURL   string
 	Ref       string
 	CommitSHA string
+	// FullRun plans every job instead of only those affected by the diff.
+	FullRun bool
+	// Parameters reach the planner and are exported to jobs as
+	// DELTA_CI_PARAM_<NAME> environment variables.
+	Parameters map[string]string
 }
 
 // GrantLeaseRequest describes parameters to grant a lease to a runner.
@@ -18,6 +29,28 @@ type GrantLeaseRequest struct {
 	MaxRuntimeSeconds int
 }
 
+// RequestLeaseRequest describes a runner polling for work.
+type RequestLeaseRequest struct {
+	RunnerID string
+	Version  string
+	Labels   []string
+	Wait     time.Duration
+}
+
+// ListRunsRequest filters and pages a run listing. Cursor is the NextCursor
+// of the previous page.
+type ListRunsRequest struct {
+	Filter state.RunFilter
+	Cursor string
+	Limit  int
+}
+
+// RunPage is one page of a run listing.
+type RunPage struct {
+	Runs       []state.RunSummary `json:"runs"`
+	NextCursor string             `json:"next_cursor,omitempty"`
+}
+
 // RunDetails aggregates run, jobs, and attempts for read-only APIs.
 type RunDetails struct {
 	Run  state.Run      `json:"run"`
@@ -25,11 +58,15 @@ type RunDetails struct {
 	Plan *RunPlanDetail `json:"plan,omitempty"`
 }
 
-// JobDetail presents a job alongside its attempts.
+// JobDetail presents a job alongside its attempts. Progress is the latest
+// attempt's heartbeat progress while the job is still running.
 type JobDetail struct {
 	Job                 state.Job                  `json:"job"`
 	Attempts            []state.JobAttempt         `json:"attempts"`
+	Progress            *state.JobProgress         `json:"progress,omitempty"`
+	Steps               []state.JobStep            `json:"steps"`
 	Artifacts           []state.Artifact           `json:"artifacts"`
+	FailedTests         []state.TestResult         `json:"failed_tests"`
 	FailureExplanations []state.FailureExplanation `json:"failure_explanations"`
 }
 
@@ -0,0 +1,160 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/izavyalov-dev/delta-ci/protocol"
+	"github.com/izavyalov-dev/delta-ci/state"
+)
+
+// maxRetryBackoff caps the exponential delay between retry attempts.
+const maxRetryBackoff = 15 * time.Minute
+
+// retryDelay reports whether a failed attempt may be retried under policy and
+// how long to wait before the next attempt becomes available.
+func retryDelay(policy *protocol.RetryPolicy, attemptNumber int, category state.FailureCategory) (time.Duration, bool) {
+	if policy == nil || attemptNumber >= policy.MaxAttempts {
+		return 0, false
+	}
+	if len(policy.RetryOn) > 0 {
+		allowed := false
+		for _, retryOn := range policy.RetryOn {
+			if strings.EqualFold(retryOn, string(category)) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return 0, false
+		}
+	}
+
+	delay := time.Duration(policy.BackoffSeconds) * time.Second
+	for i := 1; i < attemptNumber && delay < maxRetryBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryBackoff {
+		delay = maxRetryBackoff
+	}
+	return delay, true
+}
+
+// retryPlan is the next attempt for a failed job and when it may run.
+type retryPlan struct {
+	next     state.JobAttempt
+	delay    time.Duration
+	category state.FailureCategory
+}
+
+// planRetry returns the attempt N+1 to queue when the job's retry policy
+// allows another try for this failure, or nil. It must be decided before the
+// failed attempt is finished so the job can move straight back to QUEUED and
+// the run is never seen with the job failed while its retry is pending.
+func (s *Service) planRetry(ctx context.Context, job state.Job, attempt state.JobAttempt, explanation *state.FailureExplanation, logger *slog.Logger) *retryPlan {
+	specJSON, err := s.store.GetJobSpec(ctx, job.ID)
+	if err != nil {
+		s.metrics.IncFailure("job_retry_failed")
+		logger.Error("job retry failed", "event", "job_retry_failed", "error", err)
+		return nil
+	}
+	var spec protocol.JobSpec
+	if err := json.Unmarshal(specJSON, &spec); err != nil {
+		s.metrics.IncFailure("job_retry_failed")
+		logger.Error("job retry failed", "event", "job_retry_failed", "error", fmt.Errorf("decode job spec %s: %w", job.ID, err))
+		return nil
+	}
+
+	category := state.FailureCategoryUnknown
+	if explanation != nil {
+		category = explanation.Category
+	}
+	delay, ok := retryDelay(spec.Retry, attempt.AttemptNumber, category)
+	if !ok {
+		return nil
+	}
+	return &retryPlan{
+		next: state.JobAttempt{
+			ID:            s.ids.JobAttemptID(),
+			JobID:         job.ID,
+			AttemptNumber: attempt.AttemptNumber + 1,
+			State:         state.JobStateQueued,
+		},
+		delay:    delay,
+		category: category,
+	}
+}
+
+// finishFailedAttempt moves a failed attempt to final and, when plan is set
+// and the run is still open, requeues the job with the planned attempt in the
+// same store transaction. It returns the plan that was queued, or nil.
+func (s *Service) finishFailedAttempt(ctx context.Context, attemptID string, final state.JobState, plan *retryPlan) (*retryPlan, error) {
+	var next *state.JobAttempt
+	if plan != nil {
+		next = &plan.next
+	}
+	retried, err := s.store.FinishJobAttempt(ctx, attemptID, final, next)
+	if err != nil || !retried {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// dispatchRetry hands a queued retry attempt to the dispatcher once its
+// backoff has elapsed.
+func (s *Service) dispatchRetry(ctx context.Context, job state.Job, plan *retryPlan, logger *slog.Logger) {
+	var err error
+	availableAt := time.Now().UTC().Add(plan.delay)
+	if delayed, ok := s.dispatcher.(DelayedDispatcher); ok {
+		err = delayed.EnqueueJobAttemptAt(ctx, plan.next, availableAt)
+	} else {
+		err = s.dispatcher.EnqueueJobAttempt(ctx, plan.next)
+	}
+	if err != nil {
+		s.metrics.IncFailure("enqueue_failed")
+		logger.Error("job retry enqueue failed", "event", "job_retry_failed", "attempt_id", plan.next.ID, "error", err)
+	} else {
+		logger.Info("job retry scheduled", "event", "job_retry_scheduled", "attempt_id", plan.next.ID, "attempt_number", plan.next.AttemptNumber, "category", plan.category, "delay", plan.delay.String())
+		s.metrics.IncJob("retried")
+	}
+	s.reportRun(ctx, job.RunID)
+}
+
+// recordFlakyPass marks a job that succeeded after failed attempts as FLAKY.
+func (s *Service) recordFlakyPass(ctx context.Context, job state.Job, attempt state.JobAttempt, logger *slog.Logger) {
+	attempts, err := s.store.ListJobAttempts(ctx, job.ID)
+	if err != nil {
+		logger.Warn("list attempts failed", "event", "flaky_check_failed", "error", err)
+		return
+	}
+	failed := 0
+	for _, previous := range attempts {
+		if previous.AttemptNumber >= attempt.AttemptNumber {
+			continue
+		}
+		if previous.State == state.JobStateFailed || previous.State == state.JobStateTimedOut {
+			failed++
+		}
+	}
+	if failed == 0 {
+		return
+	}
+
+	explanation := state.FailureExplanation{
+		JobAttemptID: attempt.ID,
+		Category:     state.FailureCategoryFlaky,
+		Summary:      fmt.Sprintf("Passed on attempt %d after %d failed attempt(s).", attempt.AttemptNumber, failed),
+		Confidence:   state.FailureConfidenceMedium,
+	}
+	if err := s.store.RecordFailureExplanation(ctx, explanation); err != nil {
+		s.metrics.IncFailure("failure_analysis_failed")
+		logger.Error("failure explanation persist failed", "event", "failure_explanation_failed", "attempt_id", attempt.ID, "error", err)
+		return
+	}
+	s.metrics.IncJob("flaky")
+	logger.Info("flaky job recorded", "event", "job_flaky", "attempt_id", attempt.ID, "failed_attempts", failed)
+}
@@ -0,0 +1,198 @@
+package orchestrator
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/izavyalov-dev/delta-ci/planner"
+	"github.com/izavyalov-dev/delta-ci/protocol"
+	"github.com/izavyalov-dev/delta-ci/state"
+)
+
+func TestRetryDelay(t *testing.T) {
+	policy := &protocol.RetryPolicy{MaxAttempts: 4, BackoffSeconds: 30, RetryOn: []string{"infra"}}
+	cases := []struct {
+		attempt  int
+		category state.FailureCategory
+		delay    time.Duration
+		retry    bool
+	}{
+		{attempt: 1, category: state.FailureCategoryInfra, delay: 30 * time.Second, retry: true},
+		{attempt: 2, category: state.FailureCategoryInfra, delay: time.Minute, retry: true},
+		{attempt: 3, category: state.FailureCategoryInfra, delay: 2 * time.Minute, retry: true},
+		{attempt: 4, category: state.FailureCategoryInfra, retry: false},
+		{attempt: 1, category: state.FailureCategoryUser, retry: false},
+	}
+	for _, tc := range cases {
+		delay, retry := retryDelay(policy, tc.attempt, tc.category)
+		if delay != tc.delay || retry != tc.retry {
+			t.Fatalf("attempt %d %s: got delay=%s retry=%v", tc.attempt, tc.category, delay, retry)
+		}
+	}
+
+	if _, retry := retryDelay(nil, 1, state.FailureCategoryInfra); retry {
+		t.Fatalf("expected no retry without policy")
+	}
+	if delay, retry := retryDelay(&protocol.RetryPolicy{MaxAttempts: 30, BackoffSeconds: 60}, 20, state.FailureCategoryUser); !retry || delay != maxRetryBackoff {
+		t.Fatalf("expected capped retry for any category, got delay=%s retry=%v", delay, retry)
+	}
+}
+
+func TestFailedAttemptRetriesAndPassIsFlaky(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t, ctx)
+	defer cleanup()
+
+	dispatcher := &recordingDispatcher{}
+	plan := stubPlanner{
+		jobs: []planner.PlannedJob{
+			{
+				Name:     "build",
+				Required: true,
+				Spec: protocol.JobSpec{
+					Name:  "build",
+					Steps: []string{"go build ./..."},
+					Retry: &protocol.RetryPolicy{MaxAttempts: 2, RetryOn: []string{"INFRA"}},
+				},
+			},
+		},
+	}
+	service := NewService(store, plan, dispatcher, &sequenceIDGen{}, nil, nil)
+
+	details, err := service.CreateRun(ctx, CreateRunRequest{RepoID: "repo", Ref: "refs/heads/main", CommitSHA: "deadbeef"})
+	if err != nil {
+		t.Fatalf("create run: %v", err)
+	}
+	jobID := details.Jobs[0].Job.ID
+
+	runAttempt := func(attemptID string, status protocol.CompleteStatus, summary string) {
+		t.Helper()
+		lease, err := service.GrantLease(ctx, GrantLeaseRequest{AttemptID: attemptID, RunnerID: "runner-1", TTLSeconds: 60, HeartbeatSeconds: 10})
+		if err != nil {
+			t.Fatalf("grant lease: %v", err)
+		}
+		if err := service.AckLease(ctx, protocol.AckLease{JobID: jobID, LeaseID: lease.LeaseID, RunnerID: "runner-1"}); err != nil {
+			t.Fatalf("ack lease: %v", err)
+		}
+		if err := service.CompleteLease(ctx, protocol.Complete{LeaseID: lease.LeaseID, RunnerID: "runner-1", Status: status, ExitCode: 1, Summary: summary}); err != nil {
+			t.Fatalf("complete lease: %v", err)
+		}
+	}
+
+	runAttempt(details.Jobs[0].Attempts[0].ID, protocol.CompleteStatusFailed, "dial tcp 10.0.0.1:443: connection refused")
+
+	job, err := store.GetJob(ctx, jobID)
+	if err != nil {
+		t.Fatalf("get job: %v", err)
+	}
+	retry := latestAttemptForJob(t, ctx, store, jobID)
+	if job.State != state.JobStateQueued || retry.AttemptNumber != 2 || retry.State != state.JobStateQueued {
+		t.Fatalf("expected queued retry attempt, got job %s attempt %d %s", job.State, retry.AttemptNumber, retry.State)
+	}
+	if len(dispatcher.attempts) != 2 || dispatcher.attempts[1].ID != retry.ID {
+		t.Fatalf("expected retry attempt to be dispatched, got %+v", dispatcher.attempts)
+	}
+
+	runAttempt(retry.ID, protocol.CompleteStatusSucceeded, "")
+
+	details, err = service.GetRunDetails(ctx, details.Run.ID)
+	if err != nil {
+		t.Fatalf("get run: %v", err)
+	}
+	if details.Run.State != state.RunStateSuccess || details.Jobs[0].Job.State != state.JobStateSucceeded {
+		t.Fatalf("expected successful run, got run %s job %s", details.Run.State, details.Jobs[0].Job.State)
+	}
+	explanations := details.Jobs[0].FailureExplanations
+	if len(explanations) != 2 || explanations[0].Category != state.FailureCategoryFlaky || explanations[0].JobAttemptID != retry.ID {
+		t.Fatalf("expected flaky explanation on retry attempt, got %+v", explanations)
+	}
+}
+
+func TestRetryKeepsRunOpenWhileOtherJobsFinish(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t, ctx)
+	defer cleanup()
+
+	dispatcher := &recordingDispatcher{}
+	plan := stubPlanner{
+		jobs: []planner.PlannedJob{
+			{
+				Name:     "build",
+				Required: true,
+				Spec: protocol.JobSpec{
+					Name:  "build",
+					Steps: []string{"go build ./..."},
+					Retry: &protocol.RetryPolicy{MaxAttempts: 2, RetryOn: []string{"INFRA"}},
+				},
+			},
+			{
+				Name:     "lint",
+				Required: true,
+				Spec:     protocol.JobSpec{Name: "lint", Steps: []string{"go vet ./..."}},
+			},
+		},
+	}
+	service := NewService(store, plan, dispatcher, &sequenceIDGen{}, nil, nil)
+
+	details, err := service.CreateRun(ctx, CreateRunRequest{RepoID: "repo", Ref: "refs/heads/main", CommitSHA: "deadbeef"})
+	if err != nil {
+		t.Fatalf("create run: %v", err)
+	}
+	attempts := make(map[string]state.JobAttempt)
+	for _, job := range details.Jobs {
+		attempts[job.Job.Name] = job.Attempts[0]
+	}
+
+	start := func(attempt state.JobAttempt, runnerID string) string {
+		t.Helper()
+		lease, err := service.GrantLease(ctx, GrantLeaseRequest{AttemptID: attempt.ID, RunnerID: runnerID, TTLSeconds: 60, HeartbeatSeconds: 10})
+		if err != nil {
+			t.Fatalf("grant lease: %v", err)
+		}
+		if err := service.AckLease(ctx, protocol.AckLease{JobID: attempt.JobID, LeaseID: lease.LeaseID, RunnerID: runnerID}); err != nil {
+			t.Fatalf("ack lease: %v", err)
+		}
+		return lease.LeaseID
+	}
+	complete := func(leaseID, runnerID string, status protocol.CompleteStatus, summary string) {
+		t.Helper()
+		if err := service.CompleteLease(ctx, protocol.Complete{LeaseID: leaseID, RunnerID: runnerID, Status: status, ExitCode: 1, Summary: summary}); err != nil {
+			t.Fatalf("complete lease: %v", err)
+		}
+	}
+
+	buildLease := start(attempts["build"], "runner-1")
+	lintLease := start(attempts["lint"], "runner-2")
+
+	// lint finishes after build failed but before its retry has run.
+	complete(buildLease, "runner-1", protocol.CompleteStatusFailed, "dial tcp 10.0.0.1:443: connection refused")
+	complete(lintLease, "runner-2", protocol.CompleteStatusSucceeded, "")
+
+	run, err := store.GetRun(ctx, details.Run.ID)
+	if err != nil {
+		t.Fatalf("get run: %v", err)
+	}
+	if run.State != state.RunStateRunning {
+		t.Fatalf("expected run to stay running while build retries, got %s", run.State)
+	}
+
+	retry := latestAttemptForJob(t, ctx, store, attempts["build"].JobID)
+	if retry.AttemptNumber != 2 || retry.State != state.JobStateQueued {
+		t.Fatalf("expected queued retry attempt, got attempt %d %s", retry.AttemptNumber, retry.State)
+	}
+	complete(start(retry, "runner-1"), "runner-1", protocol.CompleteStatusSucceeded, "")
+
+	details, err = service.GetRunDetails(ctx, details.Run.ID)
+	if err != nil {
+		t.Fatalf("get run: %v", err)
+	}
+	if details.Run.State != state.RunStateSuccess {
+		t.Fatalf("expected successful run, got %s", details.Run.State)
+	}
+	for _, job := range details.Jobs {
+		if job.Job.State != state.JobStateSucceeded {
+			t.Fatalf("expected job %s to succeed, got %s", job.Job.Name, job.Job.State)
+		}
+	}
+}
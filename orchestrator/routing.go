@@ -0,0 +1,63 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/izavyalov-dev/delta-ci/internal/observability"
+	"github.com/izavyalov-dev/delta-ci/state"
+)
+
+// FailUnroutableJobs fails queued attempts whose runs_on labels no runner seen
+// within window advertises, once they have waited at least window. It returns
+// the number of attempts failed.
+func (s *Service) FailUnroutableJobs(ctx context.Context, window time.Duration, limit int) (int, error) {
+	now := time.Now().UTC()
+	attempts, err := s.store.ListUnroutableAttempts(ctx, now.Add(-window), now.Add(-window), limit)
+	if err != nil {
+		return 0, err
+	}
+
+	failed := 0
+	for _, attempt := range attempts {
+		jobLogger := observability.WithJob(observability.WithRun(s.logger, attempt.RunID), attempt.JobID)
+		if err := s.transitionJobAndAttempt(ctx, attempt.JobID, attempt.AttemptID, state.JobStateFailed); err != nil {
+			if state.IsTransitionError(err) {
+				// Leased or canceled since it was listed.
+				continue
+			}
+			return failed, err
+		}
+		if err := s.store.MarkJobAttemptCompleted(ctx, attempt.AttemptID, now); err != nil {
+			return failed, err
+		}
+		if err := s.store.AckJobAttemptDispatch(ctx, attempt.AttemptID); err != nil && !errors.Is(err, state.ErrNotFound) {
+			return failed, err
+		}
+		failed++
+
+		summary := fmt.Sprintf("No matching runner: no active runner has labels [%s].", strings.Join(attempt.RunsOn, ", "))
+		if err := s.store.RecordFailureExplanation(ctx, state.FailureExplanation{
+			JobAttemptID: attempt.AttemptID,
+			Category:     state.FailureCategoryInfra,
+			Summary:      summary,
+			Confidence:   state.FailureConfidenceHigh,
+			Details:      "The job's runs_on requirements were not satisfied by any runner polling for work.",
+		}); err != nil {
+			jobLogger.Warn("failure explanation persist failed", "event", "failure_explanation_failed", "error", err)
+		}
+		jobLogger.Warn("job has no matching runner", "event", "job_unroutable", "runs_on", attempt.RunsOn)
+		s.metrics.IncFailure("no_matching_runner")
+		s.metrics.IncJob("failed")
+
+		if err := s.finalizeRunIfReady(ctx, attempt.RunID); err != nil {
+			s.metrics.IncFailure("run_finalize_failed")
+			jobLogger.Error("run finalization failed", "event", "run_finalize_failed", "error", err)
+		}
+		s.reportRun(ctx, attempt.RunID)
+	}
+	return failed, nil
+}
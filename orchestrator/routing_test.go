@@ -0,0 +1,79 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/izavyalov-dev/delta-ci/planner"
+	"github.com/izavyalov-dev/delta-ci/protocol"
+	"github.com/izavyalov-dev/delta-ci/state"
+)
+
+func TestDequeueMatchesRunnerLabels(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t, ctx)
+	defer cleanup()
+
+	plan := stubPlanner{
+		jobs: []planner.PlannedJob{
+			{
+				Name:     "train",
+				Required: true,
+				Spec: protocol.JobSpec{
+					Name:    "train",
+					Workdir: ".",
+					Steps:   []string{"echo train"},
+					RunsOn:  []string{" gpu", "os=linux"},
+				},
+			},
+		},
+	}
+	service := NewService(store, plan, &recordingDispatcher{}, &sequenceIDGen{}, nil, nil)
+
+	details, err := service.CreateRun(ctx, CreateRunRequest{
+		RepoID:    "repo",
+		Ref:       "refs/heads/main",
+		CommitSHA: "deadbeef",
+	})
+	if err != nil {
+		t.Fatalf("create run: %v", err)
+	}
+	attemptID := details.Jobs[0].Attempts[0].ID
+
+	if _, err := service.RegisterRunner(ctx, "cpu-runner", "test", []string{"os=linux", "arch=amd64"}); err != nil {
+		t.Fatalf("record runner: %v", err)
+	}
+	now := time.Now().UTC()
+	unroutable, err := store.ListUnroutableAttempts(ctx, now.Add(time.Minute), now.Add(-time.Minute), 10)
+	if err != nil {
+		t.Fatalf("list unroutable: %v", err)
+	}
+	if len(unroutable) != 1 || unroutable[0].AttemptID != attemptID {
+		t.Fatalf("expected %s to be unroutable, got %+v", attemptID, unroutable)
+	}
+
+	if _, err := service.DequeueJobAttempt(ctx, time.Second, []string{"os=linux", "arch=amd64"}); !errors.Is(err, state.ErrQueueEmpty) {
+		t.Fatalf("expected no attempt for runner without gpu, got %v", err)
+	}
+
+	if _, err := service.RegisterRunner(ctx, "gpu-runner", "test", []string{"gpu", "os=linux"}); err != nil {
+		t.Fatalf("record runner: %v", err)
+	}
+	unroutable, err = store.ListUnroutableAttempts(ctx, now.Add(time.Minute), now.Add(-time.Minute), 10)
+	if err != nil {
+		t.Fatalf("list unroutable: %v", err)
+	}
+	if len(unroutable) != 0 {
+		t.Fatalf("expected no unroutable attempts, got %+v", unroutable)
+	}
+
+	got, err := service.DequeueJobAttempt(ctx, time.Second, []string{"gpu", "os=linux", "arch=amd64"})
+	if err != nil {
+		t.Fatalf("dequeue: %v", err)
+	}
+	if got != attemptID {
+		t.Fatalf("expected attempt %s, got %s", attemptID, got)
+	}
+}
@@ -0,0 +1,78 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/izavyalov-dev/delta-ci/state"
+)
+
+const (
+	defaultRunPageSize = 50
+	maxRunPageSize     = 200
+)
+
+// ErrInvalidCursor indicates a run listing cursor that this server did not issue.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ListRuns returns one page of runs matching req, newest first.
+func (s *Service) ListRuns(ctx context.Context, req ListRunsRequest) (RunPage, error) {
+	limit := req.Limit
+	if limit <= 0 {
+		limit = defaultRunPageSize
+	}
+	if limit > maxRunPageSize {
+		limit = maxRunPageSize
+	}
+
+	filter := req.Filter
+	if req.Cursor != "" {
+		cursor, err := decodeRunCursor(req.Cursor)
+		if err != nil {
+			return RunPage{}, err
+		}
+		filter.After = &cursor
+	}
+
+	// Fetch one extra run to learn whether another page exists.
+	runs, err := s.store.ListRuns(ctx, filter, limit+1)
+	if err != nil {
+		return RunPage{}, err
+	}
+	page := RunPage{Runs: runs}
+	if len(runs) > limit {
+		page.Runs = runs[:limit]
+		last := page.Runs[limit-1]
+		page.NextCursor = encodeRunCursor(state.RunCursor{CreatedAt: last.CreatedAt, ID: last.ID})
+	}
+	if page.Runs == nil {
+		page.Runs = []state.RunSummary{}
+	}
+	return page, nil
+}
+
+// encodeRunCursor makes an opaque cursor from a run's listing position.
+func encodeRunCursor(cursor state.RunCursor) string {
+	raw := cursor.CreatedAt.UTC().Format(time.RFC3339Nano) + "|" + cursor.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeRunCursor(value string) (state.RunCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return state.RunCursor{}, ErrInvalidCursor
+	}
+	createdAt, id, ok := strings.Cut(string(raw), "|")
+	if !ok || id == "" {
+		return state.RunCursor{}, ErrInvalidCursor
+	}
+	ts, err := time.Parse(time.RFC3339Nano, createdAt)
+	if err != nil {
+		return state.RunCursor{}, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	return state.RunCursor{CreatedAt: ts, ID: id}, nil
+}
@@ -0,0 +1,71 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/izavyalov-dev/delta-ci/planner"
+	"github.com/izavyalov-dev/delta-ci/protocol"
+	"github.com/izavyalov-dev/delta-ci/state"
+)
+
+func TestRunCursorRoundTrip(t *testing.T) {
+	cursor := state.RunCursor{CreatedAt: time.Date(2026, 3, 4, 5, 6, 7, 891011000, time.UTC), ID: "run-42"}
+	decoded, err := decodeRunCursor(encodeRunCursor(cursor))
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if !decoded.CreatedAt.Equal(cursor.CreatedAt) || decoded.ID != cursor.ID {
+		t.Fatalf("expected %+v, got %+v", cursor, decoded)
+	}
+	for _, value := range []string{"%%%", "bm8tc2VwYXJhdG9y", "eHx5"} {
+		if _, err := decodeRunCursor(value); !errors.Is(err, ErrInvalidCursor) {
+			t.Fatalf("%q: expected invalid cursor, got %v", value, err)
+		}
+	}
+}
+
+func TestListRunsPagesNewestFirst(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t, ctx)
+	defer cleanup()
+
+	plan := stubPlanner{
+		jobs: []planner.PlannedJob{
+			{Name: "build", Required: true, Spec: protocol.JobSpec{Name: "build", Steps: []string{"go build ./..."}}},
+			{Name: "test", Required: true, Spec: protocol.JobSpec{Name: "test", Steps: []string{"go test ./..."}}},
+		},
+	}
+	service := NewService(store, plan, &recordingDispatcher{}, &sequenceIDGen{}, nil, nil)
+
+	var created []string
+	for _, ref := range []string{"refs/heads/main", "refs/heads/feature", "refs/heads/main", "refs/heads/main"} {
+		details, err := service.CreateRun(ctx, CreateRunRequest{RepoID: "repo", Ref: ref, CommitSHA: "deadbeef"})
+		if err != nil {
+			t.Fatalf("create run: %v", err)
+		}
+		created = append(created, details.Run.ID)
+	}
+
+	filter := state.RunFilter{RepoID: "repo", Ref: "refs/heads/main"}
+	first, err := service.ListRuns(ctx, ListRunsRequest{Filter: filter, Limit: 2})
+	if err != nil {
+		t.Fatalf("list runs: %v", err)
+	}
+	if len(first.Runs) != 2 || first.Runs[0].ID != created[3] || first.Runs[1].ID != created[2] || first.NextCursor == "" {
+		t.Fatalf("unexpected first page %+v", first)
+	}
+	if first.Runs[0].JobCounts.Total != 2 || first.Runs[0].JobCounts.Pending != 2 || first.Runs[0].TriggerType != "other" {
+		t.Fatalf("unexpected summary %+v", first.Runs[0])
+	}
+
+	second, err := service.ListRuns(ctx, ListRunsRequest{Filter: filter, Limit: 2, Cursor: first.NextCursor})
+	if err != nil {
+		t.Fatalf("list runs: %v", err)
+	}
+	if len(second.Runs) != 1 || second.Runs[0].ID != created[0] || second.NextCursor != "" {
+		t.Fatalf("unexpected second page %+v", second)
+	}
+}
@@ -0,0 +1,110 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/izavyalov-dev/delta-ci/planner"
+	"github.com/izavyalov-dev/delta-ci/protocol"
+	"github.com/izavyalov-dev/delta-ci/state"
+)
+
+func TestValidateCreateRunRequestParameterNames(t *testing.T) {
+	req := CreateRunRequest{RepoID: "repo", Ref: "refs/heads/main", CommitSHA: "deadbeef"}
+	for _, name := range []string{"target", "_DEBUG", "shard_2"} {
+		req.Parameters = map[string]string{name: "x"}
+		if err := validateCreateRunRequest(req); err != nil {
+			t.Fatalf("expected %q to be valid, got %v", name, err)
+		}
+	}
+	for _, name := range []string{"", "2fast", "has-dash", "A=B"} {
+		req.Parameters = map[string]string{name: "x"}
+		if err := validateCreateRunRequest(req); err == nil {
+			t.Fatalf("expected %q to be rejected", name)
+		}
+	}
+
+	req.Parameters = map[string]string{"foo": "a", "FOO": "b"}
+	if err := validateCreateRunRequest(req); err == nil {
+		t.Fatalf("expected names differing only in case to be rejected")
+	}
+}
+
+func TestRequestRunIsIdempotentAndExportsParameters(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t, ctx)
+	defer cleanup()
+
+	plan := &recordingPlanner{jobs: []planner.PlannedJob{
+		{
+			Name:     "deploy",
+			Required: true,
+			Spec: protocol.JobSpec{
+				Name:  "deploy",
+				Steps: []string{"echo $DELTA_CI_PARAM_TARGET"},
+				Env:   map[string]string{"MODE": "fast"},
+			},
+		},
+	}}
+	service := NewService(store, plan, &recordingDispatcher{}, &sequenceIDGen{}, nil, nil)
+
+	req := CreateRunRequest{
+		RepoID:     "repo",
+		Ref:        "refs/heads/main",
+		CommitSHA:  "deadbeef",
+		FullRun:    true,
+		Parameters: map[string]string{"target": "staging"},
+	}
+	first, created, err := service.RequestRun(ctx, req, "key-1")
+	if err != nil {
+		t.Fatalf("request run: %v", err)
+	}
+	if !created {
+		t.Fatalf("expected first request to create a run")
+	}
+	if !plan.last.FullRun || plan.last.Parameters["target"] != "staging" {
+		t.Fatalf("expected full run parameters in plan request, got %+v", plan.last)
+	}
+
+	again, created, err := service.RequestRun(ctx, req, "key-1")
+	if err != nil {
+		t.Fatalf("repeat request: %v", err)
+	}
+	if created || again.Run.ID != first.Run.ID {
+		t.Fatalf("expected run %s to be reused, got %s (created=%t)", first.Run.ID, again.Run.ID, created)
+	}
+
+	other := req
+	other.CommitSHA = "cafef00d"
+	if _, _, err := service.RequestRun(ctx, other, "key-1"); !errors.Is(err, state.ErrIdempotencyKeyReused) {
+		t.Fatalf("expected reused key with a different body to be rejected, got %v", err)
+	}
+
+	specJSON, err := store.GetJobSpec(ctx, first.Jobs[0].Job.ID)
+	if err != nil {
+		t.Fatalf("get job spec: %v", err)
+	}
+	var spec protocol.JobSpec
+	if err := json.Unmarshal(specJSON, &spec); err != nil {
+		t.Fatalf("decode job spec: %v", err)
+	}
+	if spec.Env["DELTA_CI_PARAM_TARGET"] != "staging" || spec.Env["MODE"] != "fast" {
+		t.Fatalf("unexpected job env %v", spec.Env)
+	}
+
+	if _, _, err := service.RequestRun(ctx, CreateRunRequest{RepoID: "repo"}, ""); !errors.Is(err, ErrInvalidRunRequest) {
+		t.Fatalf("expected invalid run request, got %v", err)
+	}
+}
+
+type recordingPlanner struct {
+	jobs []planner.PlannedJob
+	last planner.PlanRequest
+}
+
+func (p *recordingPlanner) Plan(ctx context.Context, req planner.PlanRequest) (planner.PlanResult, error) {
+	p.last = req
+	return planner.PlanResult{Jobs: p.jobs, Explain: "recording plan"}, nil
+}
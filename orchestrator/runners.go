@@ -0,0 +1,83 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/izavyalov-dev/delta-ci/protocol"
+	"github.com/izavyalov-dev/delta-ci/state"
+)
+
+// RegisterRunner records that a runner polled for work, registering it on
+// first contact. Presence is also what lets unroutable jobs be told apart from
+// an idle fleet.
+func (s *Service) RegisterRunner(ctx context.Context, runnerID, version string, labels []string) (state.Runner, error) {
+	return s.store.RegisterRunner(ctx, runnerID, version, protocol.NormalizeLabels(labels), time.Now().UTC())
+}
+
+// ListRunners returns the runner inventory.
+func (s *Service) ListRunners(ctx context.Context) ([]state.Runner, error) {
+	runners, err := s.store.ListRunners(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if runners == nil {
+		runners = []state.Runner{}
+	}
+	return runners, nil
+}
+
+// DrainRunner stops handing new leases to a runner; its current job finishes
+// normally. Polling again does not undrain it.
+func (s *Service) DrainRunner(ctx context.Context, runnerID string) (state.Runner, error) {
+	runner, err := s.store.SetRunnerState(ctx, runnerID, state.RunnerStateDraining)
+	if err != nil {
+		return state.Runner{}, err
+	}
+	s.logger.Info("runner draining", "event", "runner_draining", "runner_id", runnerID)
+	return runner, nil
+}
+
+// MarkRunnerOffline records that a runner is gone. A lease it still holds is
+// revoked and its attempt requeued, so a runner that is in fact alive gets a
+// stale lease on its next heartbeat instead of working on unseen. The runner
+// comes back IDLE if it polls again.
+func (s *Service) MarkRunnerOffline(ctx context.Context, runnerID string) (state.Runner, error) {
+	runner, err := s.store.SetRunnerState(ctx, runnerID, state.RunnerStateOffline)
+	if err != nil {
+		return state.Runner{}, err
+	}
+	s.logger.Info("runner marked offline", "event", "runner_offline", "runner_id", runnerID)
+	if runner.CurrentLeaseID == nil {
+		return runner, nil
+	}
+	if err := s.RevokeLease(ctx, *runner.CurrentLeaseID); err != nil && !errors.Is(err, ErrStaleLease) {
+		return state.Runner{}, err
+	}
+	return s.store.GetRunner(ctx, runnerID)
+}
+
+// MarkSilentRunnersOffline marks runners that have neither polled nor
+// heartbeated within window OFFLINE.
+func (s *Service) MarkSilentRunnersOffline(ctx context.Context, window time.Duration) (int, error) {
+	ids, err := s.store.MarkSilentRunnersOffline(ctx, time.Now().UTC().Add(-window))
+	if err != nil {
+		return 0, err
+	}
+	for _, id := range ids {
+		s.logger.Info("runner went silent", "event", "runner_offline", "runner_id", id)
+	}
+	return len(ids), nil
+}
+
+// releaseRunner frees the runner that held a finished lease.
+func (s *Service) releaseRunner(ctx context.Context, lease state.Lease, logger *slog.Logger) {
+	if lease.RunnerID == nil {
+		return
+	}
+	if err := s.store.ReleaseRunnerLease(ctx, *lease.RunnerID, lease.ID); err != nil {
+		logger.Warn("release runner failed", "event", "runner_release_failed", "runner_id", *lease.RunnerID, "error", err)
+	}
+}
@@ -0,0 +1,69 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/izavyalov-dev/delta-ci/planner"
+	"github.com/izavyalov-dev/delta-ci/protocol"
+	"github.com/izavyalov-dev/delta-ci/state"
+)
+
+func TestRunnerInventoryTracksLeasesAndDraining(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t, ctx)
+	defer cleanup()
+
+	plan := stubPlanner{
+		jobs: []planner.PlannedJob{
+			{Name: "build", Required: true, Spec: protocol.JobSpec{Name: "build", Workdir: ".", Steps: []string{"echo build"}}},
+			{Name: "test", Required: true, Spec: protocol.JobSpec{Name: "test", Workdir: ".", Steps: []string{"echo test"}}},
+		},
+	}
+	service := NewService(store, plan, &recordingDispatcher{}, &sequenceIDGen{}, nil, nil)
+	if _, err := service.CreateRun(ctx, CreateRunRequest{RepoID: "repo", Ref: "refs/heads/main", CommitSHA: "deadbeef"}); err != nil {
+		t.Fatalf("create run: %v", err)
+	}
+
+	lease, err := service.RequestLease(ctx, RequestLeaseRequest{RunnerID: "runner-1", Version: "v1.2.3"})
+	if err != nil {
+		t.Fatalf("request lease: %v", err)
+	}
+	runner, err := store.GetRunner(ctx, "runner-1")
+	if err != nil {
+		t.Fatalf("get runner: %v", err)
+	}
+	if runner.State != state.RunnerStateBusy || runner.CurrentLeaseID == nil || *runner.CurrentLeaseID != lease.LeaseID || runner.Version != "v1.2.3" {
+		t.Fatalf("expected busy runner holding %s, got %+v", lease.LeaseID, runner)
+	}
+
+	if _, err := service.DrainRunner(ctx, "runner-1"); err != nil {
+		t.Fatalf("drain runner: %v", err)
+	}
+	if _, err := service.RequestLease(ctx, RequestLeaseRequest{RunnerID: "runner-1"}); !errors.Is(err, ErrNoLeaseAvailable) {
+		t.Fatalf("expected draining runner to get no lease, got %v", err)
+	}
+
+	runner, err = service.MarkRunnerOffline(ctx, "runner-1")
+	if err != nil {
+		t.Fatalf("mark offline: %v", err)
+	}
+	if runner.State != state.RunnerStateOffline || runner.CurrentLeaseID != nil {
+		t.Fatalf("expected offline runner without lease, got %+v", runner)
+	}
+	revoked, err := store.GetLease(ctx, lease.LeaseID)
+	if err != nil {
+		t.Fatalf("get lease: %v", err)
+	}
+	if revoked.State != state.LeaseStateRevoked {
+		t.Fatalf("expected offline runner's lease revoked, got %s", revoked.State)
+	}
+	if _, err := service.HandleHeartbeat(ctx, protocol.Heartbeat{LeaseID: lease.LeaseID, RunnerID: "runner-1"}); !errors.Is(err, ErrStaleLease) {
+		t.Fatalf("expected heartbeat on revoked lease to be stale, got %v", err)
+	}
+
+	if _, err := service.DrainRunner(ctx, "missing"); !errors.Is(err, state.ErrNotFound) {
+		t.Fatalf("expected not found for unknown runner, got %v", err)
+	}
+}
@@ -6,10 +6,13 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"regexp"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/izavyalov-dev/delta-ci/internal/observability"
+	"github.com/izavyalov-dev/delta-ci/internal/redact"
 	"github.com/izavyalov-dev/delta-ci/planner"
 	"github.com/izavyalov-dev/delta-ci/protocol"
 	"github.com/izavyalov-dev/delta-ci/state"
@@ -20,6 +23,18 @@ var (
 	ErrStaleLease = errors.New("stale lease")
 	// ErrInvalidRunState indicates the run cannot accept the requested transition.
 	ErrInvalidRunState = errors.New("invalid run state")
+	// ErrNoLeaseAvailable indicates no attempt became leasable while a runner waited.
+	ErrNoLeaseAvailable = errors.New("no lease available")
+	// ErrInvalidRunRequest indicates a manual run request is missing or has malformed fields.
+	ErrInvalidRunRequest = errors.New("invalid run request")
+)
+
+const (
+	// leasePollInterval is how often a waiting lease request re-checks the queue.
+	leasePollInterval = time.Second
+	// leaseVisibilityTimeout hides a dequeued attempt from other runners while
+	// its lease is granted.
+	leaseVisibilityTimeout = 30 * time.Second
 )
 
 // Service wires planner outputs to state transitions and dispatch.
@@ -32,6 +47,10 @@ type Service struct {
 	analyzer   FailureAnalyzer
 	logger     *slog.Logger
 	metrics    *observability.Metrics
+	tokens     *LeaseTokenSigner
+	// cancelSuperseded holds the repo IDs ("*" for all) whose older runs are
+	// canceled when a newer commit for the same PR or branch is pushed.
+	cancelSuperseded map[string]bool
 }
 
 type plannedJobRecord struct {
@@ -69,9 +88,27 @@ func NewService(store *state.Store, plan planner.Planner, dispatcher Dispatcher,
 		analyzer:   analyzer,
 		logger:     logger,
 		metrics:    metrics,
+		tokens:     newRandomLeaseTokenSigner(),
 	}
 }
 
+// SetLeaseTokenSigner replaces the per-process random signing key, so lease
+// tokens stay valid across restarts and between orchestrator replicas.
+func (s *Service) SetLeaseTokenSigner(signer *LeaseTokenSigner) {
+	if signer != nil {
+		s.tokens = signer
+	}
+}
+
+// VerifyLeaseToken checks that token authorizes runnerID to act on leaseID.
+func (s *Service) VerifyLeaseToken(token, leaseID, runnerID string) error {
+	if err := s.tokens.Verify(token, leaseID, runnerID, time.Now()); err != nil {
+		s.metrics.IncFailure("unauthorized")
+		return err
+	}
+	return nil
+}
+
 // CreateRun creates a run, transitions it through planning, creates initial jobs
 // and attempts, enqueues them, and returns the resulting state.
 func (s *Service) CreateRun(ctx context.Context, req CreateRunRequest) (RunDetails, error) {
@@ -81,11 +118,14 @@ func (s *Service) CreateRun(ctx context.Context, req CreateRunRequest) (RunDetai
 
 	runID := s.ids.RunID()
 	run, err := s.store.CreateRun(ctx, state.Run{
-		ID:        runID,
-		RepoID:    req.RepoID,
-		Ref:       req.Ref,
-		CommitSHA: req.CommitSHA,
-		State:     state.RunStateCreated,
+		ID:         runID,
+		RepoID:     req.RepoID,
+		RepoURL:    req.RepoURL,
+		Ref:        req.Ref,
+		CommitSHA:  req.CommitSHA,
+		FullRun:    req.FullRun,
+		Parameters: req.Parameters,
+		State:      state.RunStateCreated,
 	})
 	if err != nil {
 		return RunDetails{}, fmt.Errorf("create run: %w", err)
@@ -94,6 +134,36 @@ func (s *Service) CreateRun(ctx context.Context, req CreateRunRequest) (RunDetai
 	return s.startRun(ctx, run)
 }
 
+// RequestRun creates a manually requested run. Requests carrying the same
+// idempotency key return the run created by the first one.
+func (s *Service) RequestRun(ctx context.Context, req CreateRunRequest, idempotencyKey string) (RunDetails, bool, error) {
+	if err := validateCreateRunRequest(req); err != nil {
+		return RunDetails{}, false, fmt.Errorf("%w: %v", ErrInvalidRunRequest, err)
+	}
+
+	runID := s.ids.RunID()
+	run, created, err := s.store.CreateRunWithRequest(ctx, state.Run{
+		ID:         runID,
+		RepoID:     req.RepoID,
+		RepoURL:    req.RepoURL,
+		Ref:        req.Ref,
+		CommitSHA:  req.CommitSHA,
+		FullRun:    req.FullRun,
+		Parameters: req.Parameters,
+		State:      state.RunStateCreated,
+	}, idempotencyKey)
+	if err != nil {
+		return RunDetails{}, false, err
+	}
+	if !created {
+		details, err := s.GetRunDetails(ctx, run.ID)
+		return details, false, err
+	}
+
+	details, err := s.startRun(ctx, run)
+	return details, true, err
+}
+
 // CreateRunFromTrigger creates a run with a webhook trigger if the event is new.
 func (s *Service) CreateRunFromTrigger(ctx context.Context, req CreateRunRequest, trigger state.RunTrigger) (RunDetails, bool, error) {
 	if err := validateCreateRunRequest(req); err != nil {
@@ -102,11 +172,14 @@ func (s *Service) CreateRunFromTrigger(ctx context.Context, req CreateRunRequest
 
 	runID := s.ids.RunID()
 	run, created, err := s.store.CreateRunWithTrigger(ctx, state.Run{
-		ID:        runID,
-		RepoID:    req.RepoID,
-		Ref:       req.Ref,
-		CommitSHA: req.CommitSHA,
-		State:     state.RunStateCreated,
+		ID:         runID,
+		RepoID:     req.RepoID,
+		RepoURL:    req.RepoURL,
+		Ref:        req.Ref,
+		CommitSHA:  req.CommitSHA,
+		FullRun:    req.FullRun,
+		Parameters: req.Parameters,
+		State:      state.RunStateCreated,
 	}, trigger)
 	if err != nil {
 		return RunDetails{}, false, err
@@ -117,6 +190,9 @@ func (s *Service) CreateRunFromTrigger(ctx context.Context, req CreateRunRequest
 	}
 
 	details, err := s.startRun(ctx, run)
+	if err == nil && s.cancelsSuperseded(run.RepoID) {
+		s.cancelSupersededRuns(ctx, run, trigger.PRNumber)
+	}
 	return details, true, err
 }
 
@@ -133,11 +209,14 @@ func (s *Service) RerunRun(ctx context.Context, runID, idempotencyKey string) (R
 
 	newRunID := s.ids.RunID()
 	run, created, err := s.store.CreateRunWithRerun(ctx, state.Run{
-		ID:        newRunID,
-		RepoID:    original.RepoID,
-		Ref:       original.Ref,
-		CommitSHA: original.CommitSHA,
-		State:     state.RunStateCreated,
+		ID:         newRunID,
+		RepoID:     original.RepoID,
+		RepoURL:    original.RepoURL,
+		Ref:        original.Ref,
+		CommitSHA:  original.CommitSHA,
+		FullRun:    original.FullRun,
+		Parameters: original.Parameters,
+		State:      state.RunStateCreated,
 	}, original.ID, idempotencyKey)
 	if err != nil {
 		return RunDetails{}, false, err
@@ -175,52 +254,82 @@ func (s *Service) CancelRun(ctx context.Context, runID string) (RunDetails, erro
 	s.metrics.IncRun("cancel_requested")
 	s.reportRun(ctx, runID)
 
+	if err := s.cancelRunJobs(ctx, runID); err != nil {
+		return RunDetails{}, err
+	}
+	if err := s.finalizeCancelIfReady(ctx, runID); err != nil {
+		return RunDetails{}, err
+	}
+
+	return s.GetRunDetails(ctx, runID)
+}
+
+// cancelRunJobs cancels the queued jobs of a CANCEL_REQUESTED run and asks the
+// runners of its leased and running jobs to stop.
+func (s *Service) cancelRunJobs(ctx context.Context, runID string) error {
 	jobs, err := s.store.ListJobsByRun(ctx, runID)
 	if err != nil {
-		return RunDetails{}, err
+		return err
 	}
 
 	now := time.Now().UTC()
 	for _, job := range jobs {
 		attempt, err := s.store.GetLatestJobAttempt(ctx, job.ID)
 		if err != nil {
-			return RunDetails{}, err
+			return err
 		}
 		switch job.State {
 		case state.JobStateQueued:
 			if err := s.transitionJobAndAttempt(ctx, job.ID, attempt.ID, state.JobStateCancelRequested); err != nil {
-				return RunDetails{}, err
+				return err
 			}
 			if err := s.transitionJobAndAttempt(ctx, job.ID, attempt.ID, state.JobStateCanceled); err != nil {
-				return RunDetails{}, err
+				return err
 			}
 			if err := s.store.MarkJobAttemptCompleted(ctx, attempt.ID, now); err != nil {
-				return RunDetails{}, err
+				return err
 			}
 			s.metrics.IncJob("canceled")
 		case state.JobStateLeased, state.JobStateStarting, state.JobStateRunning:
 			if err := s.transitionJobAndAttempt(ctx, job.ID, attempt.ID, state.JobStateCancelRequested); err != nil {
-				return RunDetails{}, err
+				return err
 			}
 		default:
 			continue
 		}
 	}
-
-	if err := s.finalizeCancelIfReady(ctx, runID); err != nil {
-		return RunDetails{}, err
-	}
-
-	return s.GetRunDetails(ctx, runID)
+	return nil
 }
 
 func validateCreateRunRequest(req CreateRunRequest) error {
 	if req.RepoID == "" || req.Ref == "" || req.CommitSHA == "" {
 		return errors.New("repo_id, ref, and commit_sha are required")
 	}
+	envNames := make(map[string]string, len(req.Parameters))
+	for name := range req.Parameters {
+		if !runParameterName.MatchString(name) {
+			return fmt.Errorf("invalid parameter name %q: use letters, digits and underscores", name)
+		}
+		// Parameters are exported upper-cased, so names differing only in
+		// case would overwrite each other.
+		env := runParameterEnv(name)
+		if other, ok := envNames[env]; ok {
+			first, second := min(name, other), max(name, other)
+			return fmt.Errorf("parameter names %q and %q differ only in case", first, second)
+		}
+		envNames[env] = name
+	}
 	return nil
 }
 
+// runParameterName restricts parameter names to ones usable in env var names.
+var runParameterName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// runParameterEnv is the environment variable a run parameter is exported as.
+func runParameterEnv(name string) string {
+	return "DELTA_CI_PARAM_" + strings.ToUpper(name)
+}
+
 func (s *Service) startRun(ctx context.Context, run state.Run) (RunDetails, error) {
 	runLogger := observability.WithRun(s.logger, run.ID)
 	runLogger.Info("run created", "event", "run_created", "repo_id", run.RepoID, "ref", run.Ref, "commit_sha", run.CommitSHA)
@@ -234,10 +343,12 @@ func (s *Service) startRun(ctx context.Context, run state.Run) (RunDetails, erro
 	s.reportRun(ctx, run.ID)
 
 	planResult, err := s.planner.Plan(ctx, planner.PlanRequest{
-		RunID:     run.ID,
-		RepoID:    run.RepoID,
-		Ref:       run.Ref,
-		CommitSHA: run.CommitSHA,
+		RunID:      run.ID,
+		RepoID:     run.RepoID,
+		Ref:        run.Ref,
+		CommitSHA:  run.CommitSHA,
+		FullRun:    run.FullRun,
+		Parameters: run.Parameters,
 	})
 	if err != nil {
 		if failErr := s.failRun(ctx, run.ID, runLogger, "plan_failed", err); failErr != nil {
@@ -297,6 +408,17 @@ func (s *Service) startRun(ctx context.Context, run state.Run) (RunDetails, erro
 		if len(spec.Steps) == 0 {
 			spec.Steps = []string{"echo \"job spec missing\""}
 		}
+		spec.RunsOn = protocol.NormalizeLabels(spec.RunsOn)
+		if len(run.Parameters) > 0 {
+			env := make(map[string]string, len(spec.Env)+len(run.Parameters))
+			for key, value := range spec.Env {
+				env[key] = value
+			}
+			for name, value := range run.Parameters {
+				env[runParameterEnv(name)] = value
+			}
+			spec.Env = env
+		}
 		specJSON, err := json.Marshal(spec)
 		if err != nil {
 			return RunDetails{}, fmt.Errorf("encode job spec %s: %w", job.ID, err)
@@ -490,15 +612,33 @@ func (s *Service) GetRunDetails(ctx context.Context, runID string) (RunDetails,
 			return RunDetails{}, err
 		}
 
+		steps, err := s.store.ListJobStepsByJob(ctx, job.ID)
+		if err != nil {
+			return RunDetails{}, err
+		}
+
+		failedTests, err := s.store.ListFailedTestsByJob(ctx, job.ID)
+		if err != nil {
+			return RunDetails{}, err
+		}
+
 		failureExplanations, err := s.store.ListFailureExplanationsByJob(ctx, job.ID)
 		if err != nil {
 			return RunDetails{}, err
 		}
 
+		var progress *state.JobProgress
+		if len(attempts) > 0 && !isAttemptFinished(job.State) {
+			progress = attempts[len(attempts)-1].Progress
+		}
+
 		jobDetails = append(jobDetails, JobDetail{
 			Job:                 job,
 			Attempts:            attempts,
+			Progress:            progress,
+			Steps:               steps,
 			Artifacts:           artifacts,
+			FailedTests:         failedTests,
 			FailureExplanations: failureExplanations,
 		})
 	}
@@ -559,6 +699,11 @@ func (s *Service) GrantLease(ctx context.Context, req GrantLeaseRequest) (protoc
 	if err != nil {
 		return protocol.LeaseGranted{}, err
 	}
+	if req.RunnerID != "" {
+		if err := s.store.AssignRunnerLease(ctx, req.RunnerID, lease.ID, lease.GrantedAt); err != nil {
+			return protocol.LeaseGranted{}, err
+		}
+	}
 	leaseLogger := observability.WithLease(observability.WithJob(observability.WithRun(s.logger, run.ID), job.ID), lease.ID)
 	leaseLogger.Info("lease granted", "event", "lease_granted", "attempt_id", attempt.ID, "runner_id", req.RunnerID)
 	s.metrics.IncLease("granted")
@@ -593,37 +738,101 @@ func (s *Service) GrantLease(ctx context.Context, req GrantLeaseRequest) (protoc
 	if len(spec.Steps) == 0 {
 		return protocol.LeaseGranted{}, errors.New("job spec steps required")
 	}
+	maxRuntime := req.MaxRuntimeSeconds
+	if maxRuntime == 0 {
+		maxRuntime = spec.TimeoutSeconds
+	}
 
 	return protocol.LeaseGranted{
 		Type:                     "LeaseGranted",
 		RunID:                    run.ID,
 		JobID:                    job.ID,
+		AttemptNumber:            attempt.AttemptNumber,
+		RepoURL:                  run.RepoURL,
+		Ref:                      run.Ref,
+		CommitSHA:                run.CommitSHA,
 		LeaseID:                  lease.ID,
+		LeaseToken:               s.tokens.Mint(lease.ID, req.RunnerID, lease.GrantedAt.Add(time.Duration(lease.TTLSeconds)*time.Second)),
 		LeaseTTLSeconds:          lease.TTLSeconds,
 		HeartbeatIntervalSeconds: lease.HeartbeatIntervalSeconds,
-		MaxRuntimeSeconds:        req.MaxRuntimeSeconds,
+		MaxRuntimeSeconds:        maxRuntime,
 		JobSpec:                  spec,
 	}, nil
 }
 
-// DequeueJobAttempt pulls the next available job attempt from the queue.
-func (s *Service) DequeueJobAttempt(ctx context.Context, visibilityTimeout time.Duration) (string, error) {
-	return s.store.DequeueJobAttempt(ctx, time.Now().UTC(), visibilityTimeout)
+// DequeueJobAttempt pulls the next available job attempt whose runs_on
+// requirements are satisfied by labels.
+func (s *Service) DequeueJobAttempt(ctx context.Context, visibilityTimeout time.Duration, labels []string) (string, error) {
+	return s.store.DequeueJobAttempt(ctx, time.Now().UTC(), visibilityTimeout, protocol.NormalizeLabels(labels))
 }
 
-// ExpireLeases sweeps expired leases and requeues attempts.
-func (s *Service) ExpireLeases(ctx context.Context, limit int) (int, error) {
-	count, err := s.store.ExpireLeases(ctx, time.Now().UTC(), limit)
+// RequestLease dequeues the next attempt and grants it to the requesting runner,
+// waiting up to req.Wait for work to appear. It returns ErrNoLeaseAvailable when
+// the wait elapses with an empty queue.
+func (s *Service) RequestLease(ctx context.Context, req RequestLeaseRequest) (protocol.LeaseGranted, error) {
+	if req.RunnerID == "" {
+		return protocol.LeaseGranted{}, errors.New("runner_id is required")
+	}
+	runner, err := s.RegisterRunner(ctx, req.RunnerID, req.Version, req.Labels)
 	if err != nil {
-		return 0, err
+		return protocol.LeaseGranted{}, err
+	}
+
+	deadline := time.Now().Add(req.Wait)
+	for {
+		// A draining runner is held for the full wait so it does not spin.
+		if runner.State != state.RunnerStateDraining {
+			attemptID, err := s.DequeueJobAttempt(ctx, leaseVisibilityTimeout, req.Labels)
+			if err == nil {
+				return s.GrantLease(ctx, GrantLeaseRequest{
+					AttemptID: attemptID,
+					RunnerID:  req.RunnerID,
+				})
+			}
+			if !errors.Is(err, state.ErrQueueEmpty) {
+				return protocol.LeaseGranted{}, err
+			}
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return protocol.LeaseGranted{}, ErrNoLeaseAvailable
+		}
+		timer := time.NewTimer(min(leasePollInterval, remaining))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return protocol.LeaseGranted{}, ErrNoLeaseAvailable
+		case <-timer.C:
+		}
+		if runner, err = s.store.GetRunner(ctx, req.RunnerID); err != nil {
+			return protocol.LeaseGranted{}, err
+		}
 	}
-	for i := 0; i < count; i++ {
+}
+
+// ExpireLeases sweeps expired leases and requeues attempts.
+func (s *Service) ExpireLeases(ctx context.Context, limit int) (int, error) {
+	expired, err := s.store.ExpireLeases(ctx, time.Now().UTC(), limit)
+	for _, lease := range expired {
 		s.metrics.IncLease("expired")
+		observability.WithLease(s.logger, lease.LeaseID).Info("expired lease requeued", "event", "lease_expired", "attempt_id", lease.AttemptID, "runner_id", lease.RunnerID)
 	}
-	if count > 0 {
-		s.logger.Info("expired leases requeued", "event", "lease_expired", "count", count)
+	return len(expired), err
+}
+
+// RevokeLease gives a lease back before it finishes, e.g. when a worker shuts
+// down, so its attempt is requeued without waiting for the lease to expire.
+func (s *Service) RevokeLease(ctx context.Context, leaseID string) error {
+	if err := s.store.RevokeLease(ctx, leaseID, time.Now().UTC()); err != nil {
+		if state.IsTransitionError(err) {
+			return ErrStaleLease
+		}
+		return err
 	}
-	return count, nil
+	s.metrics.IncLease("revoked")
+	observability.WithLease(s.logger, leaseID).Info("lease revoked", "event", "lease_revoked")
+	return nil
 }
 
 // AckLease transitions an active lease to ACTIVE and moves attempt/job into STARTING.
@@ -700,6 +909,9 @@ func (s *Service) HandleHeartbeat(ctx context.Context, msg protocol.Heartbeat) (
 		}
 		return protocol.HeartbeatAck{}, err
 	}
+	if err := s.store.MarkRunnerSeen(ctx, msg.RunnerID, ts); err != nil {
+		return protocol.HeartbeatAck{}, err
+	}
 
 	attempt, err := s.store.GetJobAttempt(ctx, lease.JobAttemptID)
 	if err != nil {
@@ -717,6 +929,24 @@ func (s *Service) HandleHeartbeat(ctx context.Context, msg protocol.Heartbeat) (
 	}
 	cancelRequested := run.State == state.RunStateCancelRequested || job.State == state.JobStateCancelRequested
 
+	stepChanged := false
+	if msg.Progress != nil {
+		mask := s.jobRedactor(ctx, job.ID)
+		progress := state.JobProgress{
+			StepIndex:      msg.Progress.StepIndex,
+			StepCount:      msg.Progress.StepCount,
+			StepName:       mask.String(msg.Progress.StepName),
+			ElapsedSeconds: msg.Progress.ElapsedSeconds,
+			LogBytes:       msg.Progress.LogBytes,
+			Status:         mask.String(msg.Progress.Status),
+			UpdatedAt:      ts,
+		}
+		if err := s.store.UpdateJobAttemptProgress(ctx, attempt.ID, progress); err != nil {
+			return protocol.HeartbeatAck{}, err
+		}
+		stepChanged = attempt.Progress == nil || attempt.Progress.StepIndex != progress.StepIndex
+	}
+
 	transitionedToRunning := attempt.State != state.JobStateRunning
 	if attempt.State != state.JobStateCancelRequested {
 		if err := s.transitionJobAndAttempt(ctx, attempt.JobID, attempt.ID, state.JobStateRunning); err != nil {
@@ -736,6 +966,11 @@ func (s *Service) HandleHeartbeat(ctx context.Context, msg protocol.Heartbeat) (
 		}
 	}
 
+	// Status reports follow step changes rather than every heartbeat.
+	if stepChanged {
+		s.reportRun(ctx, job.RunID)
+	}
+
 	deadline := 0
 	if cancelRequested {
 		deadline = cancelDeadlineSeconds
@@ -746,6 +981,7 @@ func (s *Service) HandleHeartbeat(ctx context.Context, msg protocol.Heartbeat) (
 		LeaseID:               lease.ID,
 		ExtendLease:           true,
 		NewLeaseTTLSeconds:    lease.TTLSeconds,
+		LeaseToken:            s.tokens.Mint(lease.ID, msg.RunnerID, ts.Add(time.Duration(lease.TTLSeconds)*time.Second)),
 		CancelRequested:       cancelRequested,
 		CancelDeadlineSeconds: deadline,
 	}, nil
@@ -776,42 +1012,83 @@ func (s *Service) CompleteLease(ctx context.Context, msg protocol.Complete) erro
 		return err
 	}
 	completeLogger := observability.WithLease(observability.WithJob(observability.WithRun(s.logger, job.RunID), job.ID), lease.ID)
+	mask := s.jobRedactor(ctx, job.ID)
+	msg.Summary = mask.String(msg.Summary)
 	run, err := s.store.GetRun(ctx, job.RunID)
 	if err != nil {
 		return err
 	}
 
-	// Ensure attempt/job are running before uploading -> terminal state.
-	if attempt.State != state.JobStateRunning && attempt.State != state.JobStateUploading {
+	var target state.JobState
+	switch msg.Status {
+	case protocol.CompleteStatusSucceeded:
+		target = state.JobStateSucceeded
+	case protocol.CompleteStatusFailed:
+		target = state.JobStateFailed
+	case protocol.CompleteStatusTimedOut:
+		target = state.JobStateTimedOut
+	default:
+		return fmt.Errorf("unknown completion status %q", msg.Status)
+	}
+
+	// Ensure attempt/job are running before uploading -> terminal state. A
+	// runner that hit its own max runtime while the orchestrator was already
+	// stopping the attempt for the same timeout completes it directly.
+	timedOutWhileStopping := target == state.JobStateTimedOut && attempt.State == state.JobStateCancelRequested && attempt.TimeoutRequestedAt != nil
+	if attempt.State != state.JobStateRunning && attempt.State != state.JobStateUploading && !timedOutWhileStopping {
 		if err := s.transitionJobAndAttempt(ctx, job.ID, attempt.ID, state.JobStateRunning); err != nil {
 			return err
 		}
 	}
 
-	if attempt.State != state.JobStateUploading {
+	// Timed out attempts move straight from RUNNING to TIMED_OUT.
+	if target != state.JobStateTimedOut && attempt.State != state.JobStateUploading {
 		if err := s.transitionJobAndAttempt(ctx, job.ID, attempt.ID, state.JobStateUploading); err != nil {
 			return err
 		}
 	}
 
-	var target state.JobState
-	switch msg.Status {
-	case protocol.CompleteStatusSucceeded:
-		target = state.JobStateSucceeded
-	case protocol.CompleteStatusFailed:
-		target = state.JobStateFailed
-	default:
-		return fmt.Errorf("unknown completion status %q", msg.Status)
+	var artifactRefs []state.ArtifactRef
+	if len(msg.Artifacts) > 0 {
+		artifactRefs = make([]state.ArtifactRef, 0, len(msg.Artifacts))
+		for _, artifact := range msg.Artifacts {
+			artifactRefs = append(artifactRefs, state.ArtifactRef{
+				Type:      artifact.Type,
+				URI:       mask.String(artifact.URI),
+				SizeBytes: artifact.SizeBytes,
+				SHA256:    artifact.SHA256,
+			})
+		}
 	}
 
-	if err := s.transitionJobAndAttempt(ctx, job.ID, attempt.ID, target); err != nil {
-		return err
+	// A failed attempt is explained and its retry decided before the job
+	// leaves RUNNING/UPLOADING, so the job goes straight back to QUEUED when
+	// it is retried and the run never sees it failed in between.
+	var retry *retryPlan
+	if target == state.JobStateSucceeded {
+		if err := s.transitionJobAndAttempt(ctx, job.ID, attempt.ID, target); err != nil {
+			return err
+		}
+	} else {
+		explanation := s.recordFailureExplanation(ctx, job, attempt, msg, artifactRefs, mask)
+		if run.State != state.RunStateCancelRequested && !isRunTerminal(run.State) {
+			retry = s.planRetry(ctx, job, attempt, explanation, completeLogger)
+		}
+		if retry, err = s.finishFailedAttempt(ctx, attempt.ID, target, retry); err != nil {
+			return err
+		}
 	}
 	completeLogger.Info("job completed", "event", "job_completed", "status", msg.Status, "exit_code", msg.ExitCode)
+	if msg.Resources != nil {
+		completeLogger.Info("job resource usage", "event", "job_resource_usage", "peak_memory_bytes", msg.Resources.PeakMemoryBytes, "cpu_seconds", msg.Resources.CPUSeconds, "oom_killed", msg.Resources.OOMKilled)
+	}
 	s.metrics.IncJob(jobMetricState(target))
 	s.metrics.IncLease("completed")
-	if target == state.JobStateFailed {
+	switch target {
+	case state.JobStateFailed:
 		s.metrics.IncFailure("job_failed")
+	case state.JobStateTimedOut:
+		s.metrics.IncFailure("job_timed_out")
 	}
 
 	if err := s.store.MarkJobAttemptCompleted(ctx, attempt.ID, now); err != nil {
@@ -824,16 +1101,9 @@ func (s *Service) CompleteLease(ctx context.Context, msg protocol.Complete) erro
 		}
 		return err
 	}
+	s.releaseRunner(ctx, lease, completeLogger)
 
-	var artifactRefs []state.ArtifactRef
-	if len(msg.Artifacts) > 0 {
-		artifactRefs = make([]state.ArtifactRef, 0, len(msg.Artifacts))
-		for _, artifact := range msg.Artifacts {
-			artifactRefs = append(artifactRefs, state.ArtifactRef{
-				Type: artifact.Type,
-				URI:  artifact.URI,
-			})
-		}
+	if len(artifactRefs) > 0 {
 		// Artifact references are best-effort; job completion must not be blocked.
 		_ = s.store.RecordArtifacts(ctx, attempt.ID, artifactRefs)
 	}
@@ -845,16 +1115,62 @@ func (s *Service) CompleteLease(ctx context.Context, msg protocol.Complete) erro
 				CacheType:    cache.Type,
 				CacheKey:     cache.Key,
 				Hit:          cache.Hit,
+				Match:        string(cache.Match),
+				MatchedKey:   cache.MatchedKey,
 				ReadOnly:     cache.ReadOnly,
 			})
 		}
 		_ = s.store.RecordCacheEvents(ctx, attempt.ID, cacheEvents)
 	}
+	if len(msg.Steps) > 0 {
+		steps := make([]state.JobStep, 0, len(msg.Steps))
+		for _, step := range msg.Steps {
+			steps = append(steps, state.JobStep{
+				JobAttemptID: attempt.ID,
+				Index:        step.Index,
+				Command:      mask.String(step.Command),
+				Status:       string(step.Status),
+				ExitCode:     step.ExitCode,
+				StartedAt:    step.StartedAt,
+				FinishedAt:   step.FinishedAt,
+				LogOffset:    step.LogOffset,
+				LogBytes:     step.LogBytes,
+			})
+		}
+		if err := s.store.RecordJobSteps(ctx, attempt.ID, steps); err != nil {
+			completeLogger.Warn("record job steps failed", "event", "job_steps_failed", "error", err)
+		}
+	}
+	if len(msg.Tests) > 0 {
+		tests := make([]state.TestResult, 0, len(msg.Tests))
+		for _, test := range msg.Tests {
+			tests = append(tests, state.TestResult{
+				JobAttemptID: attempt.ID,
+				Package:      test.Package,
+				Name:         test.Name,
+				Status:       string(test.Status),
+				DurationMs:   test.DurationMs,
+				Output:       mask.String(test.Output),
+			})
+		}
+		if err := s.store.RecordTestResults(ctx, attempt.ID, tests); err != nil {
+			completeLogger.Warn("record test results failed", "event", "test_results_failed", "error", err)
+		}
+	}
 
-	if target == state.JobStateFailed {
-		s.recordFailureExplanation(ctx, job, attempt, msg, artifactRefs)
+	if retry != nil {
+		s.dispatchRetry(ctx, job, retry, completeLogger)
+		return nil
+	}
+	if target == state.JobStateSucceeded && attempt.AttemptNumber > 1 {
+		s.recordFlakyPass(ctx, job, attempt, completeLogger)
 	}
 
+	// The run may have been canceled while this attempt was finishing.
+	run, err = s.store.GetRun(ctx, job.RunID)
+	if err != nil {
+		return err
+	}
 	if run.State == state.RunStateCancelRequested {
 		if err := s.finalizeCancelIfReady(ctx, job.RunID); err != nil {
 			s.metrics.IncFailure("run_finalize_failed")
@@ -961,14 +1277,25 @@ func (s *Service) CancelLease(ctx context.Context, msg protocol.CancelAck) error
 		return ErrStaleLease
 	}
 
+	// An attempt stopped for exceeding its job timeout ends TIMED_OUT.
+	final := state.JobStateCanceled
+	if attempt.TimeoutRequestedAt != nil {
+		final = state.JobStateTimedOut
+	}
+
 	cancelLogger := observability.WithLease(observability.WithJob(observability.WithRun(s.logger, job.RunID), job.ID), lease.ID)
-	if err := s.transitionJobAndAttempt(ctx, job.ID, attempt.ID, state.JobStateCanceled); err != nil {
+	var retry *retryPlan
+	if final == state.JobStateTimedOut {
+		retry, err = s.finishFailedAttempt(ctx, attempt.ID, final, s.planTimedOutAttempt(ctx, job, attempt, cancelLogger))
+	} else {
+		err = s.transitionJobAndAttempt(ctx, job.ID, attempt.ID, final)
+	}
+	if err != nil {
 		if state.IsTransitionError(err) {
 			return ErrStaleLease
 		}
 		return err
 	}
-	s.metrics.IncJob("canceled")
 	s.metrics.IncLease("canceled")
 
 	if err := s.store.MarkJobAttemptCompleted(ctx, attempt.ID, now); err != nil {
@@ -981,18 +1308,28 @@ func (s *Service) CancelLease(ctx context.Context, msg protocol.CancelAck) error
 		}
 		return err
 	}
+	s.releaseRunner(ctx, lease, cancelLogger)
 
 	if len(msg.Artifacts) > 0 {
+		mask := s.jobRedactor(ctx, job.ID)
 		refs := make([]state.ArtifactRef, 0, len(msg.Artifacts))
 		for _, artifact := range msg.Artifacts {
 			refs = append(refs, state.ArtifactRef{
-				Type: artifact.Type,
-				URI:  artifact.URI,
+				Type:      artifact.Type,
+				URI:       mask.String(artifact.URI),
+				SizeBytes: artifact.SizeBytes,
+				SHA256:    artifact.SHA256,
 			})
 		}
 		_ = s.store.RecordArtifacts(ctx, attempt.ID, refs)
 	}
 
+	if final == state.JobStateTimedOut {
+		s.finishTimedOutAttempt(ctx, job, attempt, retry, cancelLogger)
+		return nil
+	}
+
+	s.metrics.IncJob("canceled")
 	if err := s.finalizeCancelIfReady(ctx, job.RunID); err != nil {
 		s.metrics.IncFailure("run_finalize_failed")
 		cancelLogger.Error("run cancel finalization failed", "event", "run_finalize_failed", "error", err)
@@ -1002,34 +1339,62 @@ func (s *Service) CancelLease(ctx context.Context, msg protocol.CancelAck) error
 	return nil
 }
 
-func (s *Service) recordFailureExplanation(ctx context.Context, job state.Job, attempt state.JobAttempt, msg protocol.Complete, artifacts []state.ArtifactRef) {
+// jobRedactor masks the secret env values declared in a job's spec. Runners
+// mask their own output; this covers anything the orchestrator stores or
+// reports on their behalf.
+func (s *Service) jobRedactor(ctx context.Context, jobID string) *redact.Redactor {
+	specJSON, err := s.store.GetJobSpec(ctx, jobID)
+	if err != nil {
+		return nil
+	}
+	var spec protocol.JobSpec
+	if err := json.Unmarshal(specJSON, &spec); err != nil {
+		return nil
+	}
+	return redact.ForJobSpec(spec)
+}
+
+// recordFailureExplanation analyzes a failed attempt and stores the result,
+// which is also returned (nil when there is none).
+func (s *Service) recordFailureExplanation(ctx context.Context, job state.Job, attempt state.JobAttempt, msg protocol.Complete, artifacts []state.ArtifactRef, mask *redact.Redactor) *state.FailureExplanation {
 	if s.analyzer == nil {
-		return
+		return nil
+	}
+	var failedTests []string
+	for _, test := range msg.Tests {
+		if test.Status == protocol.TestStatusFailed {
+			failedTests = append(failedTests, testDisplayName(test.Package, test.Name))
+		}
 	}
 	explanation, err := s.analyzer.Analyze(ctx, FailureInput{
-		RunID:     job.RunID,
-		JobID:     job.ID,
-		JobName:   job.Name,
-		AttemptID: attempt.ID,
-		Status:    msg.Status,
-		ExitCode:  msg.ExitCode,
-		Summary:   msg.Summary,
-		Artifacts: artifacts,
+		RunID:       job.RunID,
+		JobID:       job.ID,
+		JobName:     job.Name,
+		AttemptID:   attempt.ID,
+		Status:      msg.Status,
+		ExitCode:    msg.ExitCode,
+		Summary:     msg.Summary,
+		Artifacts:   artifacts,
+		FailedTests: failedTests,
+		Resources:   msg.Resources,
 	})
 	if err != nil {
 		s.metrics.IncFailure("failure_analysis_failed")
 		s.logger.Error("failure analysis failed", "event", "failure_analysis_failed", "job_id", job.ID, "attempt_id", attempt.ID, "error", err)
-		return
+		return nil
 	}
 	if explanation == nil {
-		return
+		return nil
 	}
+	explanation.Summary = mask.String(explanation.Summary)
+	explanation.Details = mask.String(explanation.Details)
 	if err := s.store.RecordFailureExplanation(ctx, *explanation); err != nil {
 		s.metrics.IncFailure("failure_analysis_failed")
 		s.logger.Error("failure explanation persist failed", "event", "failure_explanation_failed", "job_id", job.ID, "attempt_id", attempt.ID, "error", err)
-		return
+		return explanation
 	}
 	s.logger.Info("failure explanation recorded", "event", "failure_explanation_recorded", "job_id", job.ID, "attempt_id", attempt.ID, "category", explanation.Category, "confidence", explanation.Confidence)
+	return explanation
 }
 
 func (s *Service) transitionJobAndAttempt(ctx context.Context, jobID, attemptID string, target state.JobState) error {
@@ -1053,6 +1418,8 @@ func jobMetricState(stateValue state.JobState) string {
 		return "leased"
 	case state.JobStateStarting:
 		return "starting"
+	case state.JobStateTimedOut:
+		return "timed_out"
 	default:
 		return "other"
 	}
@@ -1065,7 +1432,9 @@ func (s *Service) finalizeRunIfReady(ctx context.Context, runID string) error {
 	if err != nil {
 		return err
 	}
-	if run.State != state.RunStateRunning {
+	// A run is still QUEUED when its jobs resolve without ever being leased,
+	// e.g. when no runner matches their labels.
+	if run.State != state.RunStateRunning && run.State != state.RunStateQueued {
 		return nil
 	}
 
@@ -1259,6 +1628,17 @@ func (s *Service) finalizeCancelIfReady(ctx context.Context, runID string) error
 		}
 	}
 
+	// A run stopped for exceeding its runtime limit ends TIMEOUT.
+	if run.TimeoutRequestedAt != nil {
+		if err := s.store.TransitionRunState(ctx, runID, state.RunStateTimeout); err != nil {
+			return err
+		}
+		s.metrics.IncRun("timeout")
+		s.logger.Info("run timed out", "event", "run_timed_out", "run_id", runID)
+		s.reportRun(ctx, runID)
+		return nil
+	}
+
 	if err := s.store.TransitionRunState(ctx, runID, state.RunStateCanceled); err != nil {
 		return err
 	}
@@ -0,0 +1,62 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+
+	"github.com/izavyalov-dev/delta-ci/internal/observability"
+	"github.com/izavyalov-dev/delta-ci/state"
+)
+
+// SetCancelSuperseded opts repositories into canceling superseded runs: when a
+// webhook starts a run, older runs for the same pull request (or, for pushes,
+// the same ref) at another commit are canceled. "*" matches every repository.
+func (s *Service) SetCancelSuperseded(repoIDs []string) {
+	s.cancelSuperseded = make(map[string]bool, len(repoIDs))
+	for _, repoID := range repoIDs {
+		if repoID != "" {
+			s.cancelSuperseded[repoID] = true
+		}
+	}
+}
+
+func (s *Service) cancelsSuperseded(repoID string) bool {
+	return s.cancelSuperseded[repoID] || s.cancelSuperseded["*"]
+}
+
+// cancelSupersededRuns cancels the runs that run replaces and records it as
+// their successor. Failures are logged; they must not fail the new run.
+func (s *Service) cancelSupersededRuns(ctx context.Context, run state.Run, prNumber *int) {
+	logger := observability.WithRun(s.logger, run.ID)
+	runIDs, err := s.store.ListSupersededRuns(ctx, run, prNumber)
+	if err != nil {
+		s.metrics.IncFailure("supersede_failed")
+		logger.Error("list superseded runs failed", "event", "supersede_failed", "error", err)
+		return
+	}
+
+	for _, runID := range runIDs {
+		// Record the successor first so the cancel report already names it.
+		marked, err := s.store.MarkRunSuperseded(ctx, runID, run.ID)
+		if err != nil {
+			s.metrics.IncFailure("supersede_failed")
+			logger.Error("mark run superseded failed", "event", "supersede_failed", "superseded_run_id", runID, "error", err)
+			continue
+		}
+		if !marked {
+			// Finished or superseded since it was listed.
+			continue
+		}
+		if _, err := s.CancelRun(ctx, runID); err != nil {
+			if errors.Is(err, ErrInvalidRunState) {
+				// Finished since it was listed.
+				continue
+			}
+			s.metrics.IncFailure("supersede_failed")
+			logger.Error("cancel superseded run failed", "event", "supersede_failed", "superseded_run_id", runID, "error", err)
+			continue
+		}
+		s.metrics.IncRun("superseded")
+		logger.Info("superseded run canceled", "event", "run_superseded", "superseded_run_id", runID)
+	}
+}
@@ -0,0 +1,68 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/izavyalov-dev/delta-ci/planner"
+	"github.com/izavyalov-dev/delta-ci/protocol"
+	"github.com/izavyalov-dev/delta-ci/state"
+)
+
+func TestTriggeredRunCancelsSupersededRuns(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t, ctx)
+	defer cleanup()
+
+	plan := stubPlanner{
+		jobs: []planner.PlannedJob{
+			{Name: "build", Required: true, Spec: protocol.JobSpec{Name: "build", Steps: []string{"go build ./..."}}},
+		},
+	}
+	service := NewService(store, plan, &recordingDispatcher{}, &sequenceIDGen{}, nil, nil)
+	service.SetCancelSuperseded([]string{"octo/repo"})
+
+	trigger := func(pr int, sha string) RunDetails {
+		t.Helper()
+		details, created, err := service.CreateRunFromTrigger(ctx, CreateRunRequest{
+			RepoID:    "octo/repo",
+			Ref:       fmt.Sprintf("refs/pull/%d/head", pr),
+			CommitSHA: sha,
+		}, state.RunTrigger{
+			Provider:  "github",
+			EventKey:  sha,
+			EventType: "pull_request",
+			RepoID:    "octo/repo",
+			RepoOwner: "octo",
+			RepoName:  "repo",
+			PRNumber:  &pr,
+		})
+		if err != nil || !created {
+			t.Fatalf("create run for %s: created=%t err=%v", sha, created, err)
+		}
+		return details
+	}
+
+	older := trigger(1, "aaa111")
+	otherPR := trigger(2, "bbb222")
+	newer := trigger(1, "ccc333")
+
+	superseded, err := store.GetRun(ctx, older.Run.ID)
+	if err != nil {
+		t.Fatalf("get run: %v", err)
+	}
+	if superseded.State != state.RunStateCanceled || superseded.SupersededBy == nil || *superseded.SupersededBy != newer.Run.ID {
+		t.Fatalf("expected run canceled and superseded by %s, got %s %v", newer.Run.ID, superseded.State, superseded.SupersededBy)
+	}
+
+	for _, runID := range []string{otherPR.Run.ID, newer.Run.ID} {
+		run, err := store.GetRun(ctx, runID)
+		if err != nil {
+			t.Fatalf("get run: %v", err)
+		}
+		if run.State != state.RunStateQueued || run.SupersededBy != nil {
+			t.Fatalf("expected run %s to stay queued, got %s %v", runID, run.State, run.SupersededBy)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/izavyalov-dev/delta-ci/internal/observability"
+	"github.com/izavyalov-dev/delta-ci/protocol"
+	"github.com/izavyalov-dev/delta-ci/state"
+)
+
+// defaultTimeoutGrace is how long a runner has to stop after a timeout before
+// the orchestrator forces the attempt to its final state.
+const defaultTimeoutGrace = 2 * time.Minute
+
+// TimeoutLimits bounds job and run runtimes. Zero Job and Run disable the
+// respective default; jobs with timeout_seconds in their spec are always
+// checked.
+type TimeoutLimits struct {
+	// Job applies to jobs whose spec sets no timeout_seconds.
+	Job time.Duration
+	// Run caps a run's total runtime, measured from its creation.
+	Run time.Duration
+	// Grace is how long runners get to acknowledge the cancel sent through
+	// HeartbeatAck before the timeout is forced.
+	Grace time.Duration
+}
+
+// EnforceTimeouts stops attempts that exceeded their job timeout and runs that
+// exceeded limits.Run. Runners are asked to cancel through HeartbeatAck; what
+// has not stopped after limits.Grace is forced to TIMED_OUT (or CANCELED for
+// the other jobs of a timed-out run) and the run is finalized. It returns the
+// number of attempts and runs it acted on.
+func (s *Service) EnforceTimeouts(ctx context.Context, limits TimeoutLimits, limit int) (int, error) {
+	if limits.Grace <= 0 {
+		limits.Grace = defaultTimeoutGrace
+	}
+	now := time.Now().UTC()
+	count := 0
+
+	attempts, err := s.store.ListOverdueAttempts(ctx, now, limits.Job, limit)
+	if err != nil {
+		return count, err
+	}
+	for _, attempt := range attempts {
+		jobLogger := observability.WithJob(observability.WithRun(s.logger, attempt.RunID), attempt.JobID)
+		if err := s.store.RequestJobAttemptTimeout(ctx, attempt.AttemptID, now); err != nil {
+			if state.IsTransitionError(err) {
+				// Finished or canceled since it was listed.
+				continue
+			}
+			return count, err
+		}
+		count++
+		jobLogger.Warn("job timeout exceeded", "event", "job_timeout_requested", "attempt_id", attempt.AttemptID, "timeout_seconds", attempt.TimeoutSeconds)
+		s.metrics.IncJob("timeout_requested")
+		s.reportRun(ctx, attempt.RunID)
+	}
+
+	if limits.Run > 0 {
+		runIDs, err := s.store.ListOverdueRuns(ctx, now.Add(-limits.Run), limit)
+		if err != nil {
+			return count, err
+		}
+		for _, runID := range runIDs {
+			if err := s.store.RequestRunTimeout(ctx, runID, now); err != nil {
+				if state.IsTransitionError(err) {
+					continue
+				}
+				return count, err
+			}
+			count++
+			s.logger.Warn("run timeout exceeded", "event", "run_timeout_requested", "run_id", runID, "max_runtime", limits.Run.String())
+			s.metrics.IncRun("timeout_requested")
+			s.reportRun(ctx, runID)
+			if err := s.cancelRunJobs(ctx, runID); err != nil {
+				return count, err
+			}
+			if err := s.finalizeCancelIfReady(ctx, runID); err != nil {
+				return count, err
+			}
+		}
+	}
+
+	stops, err := s.store.ListStalledStops(ctx, now.Add(-limits.Grace), limit)
+	if err != nil {
+		return count, err
+	}
+	for _, stop := range stops {
+		jobLogger := observability.WithJob(observability.WithRun(s.logger, stop.RunID), stop.JobID)
+		final := state.JobStateCanceled
+		var job state.Job
+		var attempt state.JobAttempt
+		var retry *retryPlan
+		if stop.JobTimedOut {
+			final = state.JobStateTimedOut
+			if job, err = s.store.GetJob(ctx, stop.JobID); err != nil {
+				return count, err
+			}
+			if attempt, err = s.store.GetJobAttempt(ctx, stop.AttemptID); err != nil {
+				return count, err
+			}
+			retry = s.planTimedOutAttempt(ctx, job, attempt, jobLogger)
+		}
+		var next *state.JobAttempt
+		if retry != nil {
+			next = &retry.next
+		}
+		retried, err := s.store.ForceStopJobAttempt(ctx, stop.AttemptID, final, next, now)
+		if err != nil {
+			if state.IsTransitionError(err) {
+				// The runner acknowledged the cancel since it was listed.
+				continue
+			}
+			return count, err
+		}
+		count++
+		jobLogger.Warn("runner did not stop in time", "event", "job_stop_forced", "attempt_id", stop.AttemptID, "state", final)
+		s.metrics.IncLease("revoked")
+
+		if final == state.JobStateTimedOut {
+			if !retried {
+				retry = nil
+			}
+			s.finishTimedOutAttempt(ctx, job, attempt, retry, jobLogger)
+			continue
+		}
+
+		s.metrics.IncJob("canceled")
+		if err := s.finalizeCancelIfReady(ctx, stop.RunID); err != nil {
+			s.metrics.IncFailure("run_finalize_failed")
+			jobLogger.Error("run cancel finalization failed", "event", "run_finalize_failed", "error", err)
+		}
+	}
+
+	return count, nil
+}
+
+// planTimedOutAttempt explains a TIMED_OUT attempt stopped by the
+// orchestrator and returns its retry when the job's policy allows one. It runs
+// before the attempt is finished so a retried job goes straight back to
+// QUEUED.
+func (s *Service) planTimedOutAttempt(ctx context.Context, job state.Job, attempt state.JobAttempt, logger *slog.Logger) *retryPlan {
+	run, err := s.store.GetRun(ctx, job.RunID)
+	if err != nil {
+		logger.Error("get run failed", "event", "job_retry_failed", "error", err)
+		return nil
+	}
+
+	summary := "timed out: job timeout exceeded, stopped by the orchestrator"
+	if attempt.StartedAt != nil && attempt.TimeoutRequestedAt != nil {
+		summary = fmt.Sprintf("timed out after %s: job timeout exceeded, stopped by the orchestrator", attempt.TimeoutRequestedAt.Sub(*attempt.StartedAt).Round(time.Second))
+	}
+	explanation := s.recordFailureExplanation(ctx, job, attempt, protocol.Complete{
+		Status:   protocol.CompleteStatusTimedOut,
+		ExitCode: -1,
+		Summary:  summary,
+	}, nil, nil)
+
+	if run.State == state.RunStateCancelRequested || isRunTerminal(run.State) {
+		return nil
+	}
+	return s.planRetry(ctx, job, attempt, explanation, logger)
+}
+
+// finishTimedOutAttempt dispatches the retry queued for a TIMED_OUT attempt,
+// or finalizes the run when there is none.
+func (s *Service) finishTimedOutAttempt(ctx context.Context, job state.Job, attempt state.JobAttempt, retry *retryPlan, logger *slog.Logger) {
+	s.metrics.IncJob(jobMetricState(state.JobStateTimedOut))
+	s.metrics.IncFailure("job_timed_out")
+	logger.Info("job timed out", "event", "job_timed_out", "attempt_id", attempt.ID)
+
+	if retry != nil {
+		s.dispatchRetry(ctx, job, retry, logger)
+		return
+	}
+
+	run, err := s.store.GetRun(ctx, job.RunID)
+	if err != nil {
+		logger.Error("get run failed", "event", "run_finalize_failed", "error", err)
+		return
+	}
+	if run.State == state.RunStateCancelRequested {
+		if err := s.finalizeCancelIfReady(ctx, job.RunID); err != nil {
+			s.metrics.IncFailure("run_finalize_failed")
+			logger.Error("run cancel finalization failed", "event", "run_finalize_failed", "error", err)
+		}
+		return
+	}
+	if isRunTerminal(run.State) {
+		return
+	}
+
+	if err := s.finalizeRunIfReady(ctx, job.RunID); err != nil {
+		s.metrics.IncFailure("run_finalize_failed")
+		logger.Error("run finalization failed", "event", "run_finalize_failed", "error", err)
+	}
+	s.reportRun(ctx, job.RunID)
+}
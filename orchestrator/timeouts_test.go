@@ -0,0 +1,140 @@
+package orchestrator
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/izavyalov-dev/delta-ci/planner"
+	"github.com/izavyalov-dev/delta-ci/protocol"
+	"github.com/izavyalov-dev/delta-ci/state"
+)
+
+func TestJobTimeoutCancelsThroughHeartbeat(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t, ctx)
+	defer cleanup()
+
+	plan := stubPlanner{
+		jobs: []planner.PlannedJob{
+			{
+				Name:     "build",
+				Required: true,
+				Spec: protocol.JobSpec{
+					Name:           "build",
+					Steps:          []string{"sleep infinity"},
+					TimeoutSeconds: 5,
+				},
+			},
+		},
+	}
+	service := NewService(store, plan, &recordingDispatcher{}, &sequenceIDGen{}, nil, nil)
+
+	details, err := service.CreateRun(ctx, CreateRunRequest{RepoID: "repo", Ref: "refs/heads/main", CommitSHA: "deadbeef"})
+	if err != nil {
+		t.Fatalf("create run: %v", err)
+	}
+	jobID := details.Jobs[0].Job.ID
+	attemptID := details.Jobs[0].Attempts[0].ID
+
+	lease, err := service.GrantLease(ctx, GrantLeaseRequest{AttemptID: attemptID, RunnerID: "runner-1", TTLSeconds: 60, HeartbeatSeconds: 10})
+	if err != nil {
+		t.Fatalf("grant lease: %v", err)
+	}
+	if lease.MaxRuntimeSeconds != 5 {
+		t.Fatalf("expected job timeout as lease max runtime, got %d", lease.MaxRuntimeSeconds)
+	}
+	if err := service.AckLease(ctx, protocol.AckLease{JobID: jobID, LeaseID: lease.LeaseID, RunnerID: "runner-1"}); err != nil {
+		t.Fatalf("ack lease: %v", err)
+	}
+	if err := store.MarkJobAttemptStarted(ctx, attemptID, time.Now().UTC().Add(-time.Minute)); err != nil {
+		t.Fatalf("backdate attempt: %v", err)
+	}
+
+	if _, err := service.EnforceTimeouts(ctx, TimeoutLimits{Grace: time.Hour}, 10); err != nil {
+		t.Fatalf("enforce timeouts: %v", err)
+	}
+	ack, err := service.HandleHeartbeat(ctx, protocol.Heartbeat{LeaseID: lease.LeaseID, RunnerID: "runner-1"})
+	if err != nil {
+		t.Fatalf("heartbeat: %v", err)
+	}
+	if !ack.CancelRequested {
+		t.Fatalf("expected heartbeat ack to request cancel")
+	}
+
+	if err := service.CancelLease(ctx, protocol.CancelAck{LeaseID: lease.LeaseID, RunnerID: "runner-1", FinalStatus: protocol.CancelFinalStatusCanceled}); err != nil {
+		t.Fatalf("cancel ack: %v", err)
+	}
+
+	details, err = service.GetRunDetails(ctx, details.Run.ID)
+	if err != nil {
+		t.Fatalf("get run: %v", err)
+	}
+	if details.Jobs[0].Job.State != state.JobStateTimedOut || details.Run.State != state.RunStateFailed {
+		t.Fatalf("expected timed out job and failed run, got job %s run %s", details.Jobs[0].Job.State, details.Run.State)
+	}
+}
+
+func TestRunTimeoutForcesStopAfterGrace(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t, ctx)
+	defer cleanup()
+
+	plan := stubPlanner{
+		jobs: []planner.PlannedJob{
+			{Name: "build", Required: true, Spec: protocol.JobSpec{Name: "build", Steps: []string{"go build ./..."}}},
+			{Name: "test", Required: true, Spec: protocol.JobSpec{Name: "test", Steps: []string{"go test ./..."}}},
+		},
+	}
+	service := NewService(store, plan, &recordingDispatcher{}, &sequenceIDGen{}, nil, nil)
+
+	details, err := service.CreateRun(ctx, CreateRunRequest{RepoID: "repo", Ref: "refs/heads/main", CommitSHA: "deadbeef"})
+	if err != nil {
+		t.Fatalf("create run: %v", err)
+	}
+	lease, err := service.GrantLease(ctx, GrantLeaseRequest{AttemptID: details.Jobs[0].Attempts[0].ID, RunnerID: "runner-1", TTLSeconds: 60, HeartbeatSeconds: 10})
+	if err != nil {
+		t.Fatalf("grant lease: %v", err)
+	}
+	if err := service.AckLease(ctx, protocol.AckLease{JobID: details.Jobs[0].Job.ID, LeaseID: lease.LeaseID, RunnerID: "runner-1"}); err != nil {
+		t.Fatalf("ack lease: %v", err)
+	}
+
+	limits := TimeoutLimits{Run: time.Nanosecond, Grace: time.Millisecond}
+	if _, err := service.EnforceTimeouts(ctx, limits, 10); err != nil {
+		t.Fatalf("enforce timeouts: %v", err)
+	}
+	run, err := store.GetRun(ctx, details.Run.ID)
+	if err != nil {
+		t.Fatalf("get run: %v", err)
+	}
+	if run.State != state.RunStateCancelRequested || run.TimeoutRequestedAt == nil {
+		t.Fatalf("expected run timeout to be requested, got %s", run.State)
+	}
+
+	// The runner never acknowledges the cancel.
+	time.Sleep(20 * time.Millisecond)
+	if _, err := service.EnforceTimeouts(ctx, limits, 10); err != nil {
+		t.Fatalf("enforce timeouts: %v", err)
+	}
+
+	details, err = service.GetRunDetails(ctx, details.Run.ID)
+	if err != nil {
+		t.Fatalf("get run: %v", err)
+	}
+	if details.Run.State != state.RunStateTimeout {
+		t.Fatalf("expected run TIMEOUT, got %s", details.Run.State)
+	}
+	for _, job := range details.Jobs {
+		if job.Job.State != state.JobStateCanceled {
+			t.Fatalf("expected job %s canceled, got %s", job.Job.Name, job.Job.State)
+		}
+	}
+	revoked, err := store.GetLease(ctx, lease.LeaseID)
+	if err != nil {
+		t.Fatalf("get lease: %v", err)
+	}
+	if revoked.State != state.LeaseStateRevoked {
+		t.Fatalf("expected revoked lease, got %s", revoked.State)
+	}
+}
@@ -82,6 +82,13 @@ func (p DiffPlanner) Plan(ctx context.Context, req PlanRequest) (PlanResult, err
 		return recipeResult, nil
 	}
 
+	if req.FullRun {
+		impact := fullRunImpact(discovery.projects, discovery.dependencyUnknown)
+		result := planForGo(impact, buildExplain(discovery, impact), discovery.projects, root, cacheReadOnly)
+		applyPlanMetadata(&result, fingerprint, fingerprintErr, PlanSourceDiscovery, recipeNote)
+		return result, nil
+	}
+
 	paths, err := gitChangedFiles(ctx, root, req.CommitSHA)
 	if err != nil {
 		result, planErr := p.fallbackPlan(ctx, req, "diff unavailable", err)
@@ -620,6 +627,7 @@ type impactSummary struct {
 	ImpactedProjects  []string
 	UnknownOwnership  []string
 	DependencyUnknown bool
+	FullRun           bool
 }
 
 func analyzeImpact(paths []string, projects []project, dependencyUnknown bool) impactSummary {
@@ -670,6 +678,17 @@ func analyzeImpact(paths []string, projects []project, dependencyUnknown bool) i
 	}
 }
 
+// fullRunImpact treats every project as impacted without looking at the diff.
+func fullRunImpact(projects []project, dependencyUnknown bool) impactSummary {
+	return impactSummary{
+		Global:            true,
+		CodeChanges:       true,
+		ImpactedProjects:  projectNames(projects),
+		DependencyUnknown: dependencyUnknown,
+		FullRun:           true,
+	}
+}
+
 func planForGo(impact impactSummary, explain string, projects []project, repoRoot string, cacheReadOnly bool) PlanResult {
 	reasons := buildReasons(impact)
 	projectRoots := buildProjectRootIndex(projects)
@@ -800,15 +819,20 @@ func buildExplain(discovery discoveryInputs, impact impactSummary) string {
 	var b bytes.Buffer
 	b.WriteString("diff-aware planner v1: ")
 	appendDiscoveryExplain(&b, discovery)
-	b.WriteString("; ")
-	b.WriteString("changed paths: ")
-	b.WriteString(strings.Join(impact.Paths, ", "))
+	if impact.FullRun {
+		b.WriteString("; full run requested")
+	} else {
+		b.WriteString("; ")
+		b.WriteString("changed paths: ")
+		b.WriteString(strings.Join(impact.Paths, ", "))
+	}
 	if impact.DocsOnly {
 		b.WriteString("; docs-only change")
 	}
-	if impact.Global {
+	// A full run plans every project, so there is no change to describe.
+	if !impact.FullRun && impact.Global {
 		b.WriteString("; global-impact change")
-	} else if !impact.DocsOnly {
+	} else if !impact.FullRun && !impact.DocsOnly {
 		if impact.CodeChanges {
 			b.WriteString("; code change")
 		} else {
@@ -981,22 +1005,35 @@ func isPullRequestRef(ref string) bool {
 	return strings.HasPrefix(ref, "refs/pull/")
 }
 
+// buildGoCacheSpecs keys the module cache by project and go.mod/go.sum hash.
+// When the hash changes, the project's latest cache is restored instead, and
+// failing that any project's; the module cache is content-addressed, so stale
+// entries are harmless.
 func buildGoCacheSpecs(repoRoot, projectRoot string, readOnly bool) []protocol.CacheSpec {
-	key, err := goModuleCacheKey(repoRoot, projectRoot)
+	hash, err := goModuleHash(repoRoot, projectRoot)
 	if err != nil {
 		return nil
 	}
+	projectPrefix := fmt.Sprintf("go:deps:%s:", goCacheScope(projectRoot))
 	return []protocol.CacheSpec{
 		{
-			Type:     "deps",
-			Key:      key,
-			Paths:    []string{"~/go/pkg/mod"},
-			ReadOnly: readOnly,
+			Type:        "deps",
+			Key:         projectPrefix + hash,
+			RestoreKeys: []string{projectPrefix, "go:deps:"},
+			Paths:       []string{"~/go/pkg/mod"},
+			ReadOnly:    readOnly,
 		},
 	}
 }
 
-func goModuleCacheKey(repoRoot, projectRoot string) (string, error) {
+func goCacheScope(projectRoot string) string {
+	if projectRoot == "" {
+		return "."
+	}
+	return filepath.ToSlash(filepath.Clean(projectRoot))
+}
+
+func goModuleHash(repoRoot, projectRoot string) (string, error) {
 	moduleRoot := projectRoot
 	if moduleRoot == "" {
 		moduleRoot = "."
@@ -1031,7 +1068,7 @@ func goModuleCacheKey(repoRoot, projectRoot string) (string, error) {
 	if !found {
 		return "", errors.New("go module files unavailable")
 	}
-	return fmt.Sprintf("go:deps:%s", hex.EncodeToString(hasher.Sum(nil))), nil
+	return hex.EncodeToString(hasher.Sum(nil)), nil
 }
 
 func buildProjectRootIndex(projects []project) map[string]string {
@@ -1187,6 +1224,9 @@ func projectNames(projects []project) []string {
 }
 
 func impactReasonSummary(impact impactSummary) string {
+	if impact.FullRun {
+		return "full run request"
+	}
 	if impact.DocsOnly {
 		return "docs-only change"
 	}
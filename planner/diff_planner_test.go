@@ -180,6 +180,35 @@ func TestPlanForGoRootJobNames(t *testing.T) {
 	}
 }
 
+func TestPlanForGoFullRunPlansAllProjects(t *testing.T) {
+	projects := []project{
+		{
+			Name:       "services/api",
+			Root:       "services/api",
+			Language:   "go",
+			ModulePath: "example.com/api",
+		},
+		{
+			Name:       "services/web",
+			Root:       "services/web",
+			Language:   "go",
+			ModulePath: "example.com/web",
+		},
+	}
+
+	impact := fullRunImpact(projects, false)
+	plan := planForGo(impact, "explain", projects, ".", false)
+	if len(plan.Jobs) != 6 {
+		t.Fatalf("expected 6 jobs, got %d", len(plan.Jobs))
+	}
+	if len(plan.SkippedJobs) != 0 {
+		t.Fatalf("expected no skipped jobs, got %v", plan.SkippedJobs)
+	}
+	if !strings.Contains(plan.Jobs[0].Reason, "full run request") {
+		t.Fatalf("unexpected reason %q", plan.Jobs[0].Reason)
+	}
+}
+
 func TestPlanForGoDocsOnlySkipsTestAndLint(t *testing.T) {
 	projects := []project{
 		{
@@ -216,6 +245,29 @@ func TestPlanForGoDocsOnlySkipsTestAndLint(t *testing.T) {
 	}
 }
 
+func TestBuildGoCacheSpecsRestoreKeys(t *testing.T) {
+	dir := t.TempDir()
+	writeGoMod(t, filepath.Join(dir, "services", "api"), "example.com/api")
+
+	specs := buildGoCacheSpecs(dir, "services/api", false)
+	if len(specs) != 1 {
+		t.Fatalf("expected 1 cache spec, got %d", len(specs))
+	}
+	spec := specs[0]
+	if !strings.HasPrefix(spec.Key, "go:deps:services/api:") || len(spec.Key) != len("go:deps:services/api:")+64 {
+		t.Fatalf("unexpected cache key %q", spec.Key)
+	}
+	expected := []string{"go:deps:services/api:", "go:deps:"}
+	if !reflect.DeepEqual(spec.RestoreKeys, expected) {
+		t.Fatalf("expected restore keys %v, got %v", expected, spec.RestoreKeys)
+	}
+	for _, prefix := range spec.RestoreKeys {
+		if !strings.HasPrefix(spec.Key, prefix) {
+			t.Fatalf("restore key %q is not a prefix of %q", prefix, spec.Key)
+		}
+	}
+}
+
 func TestParseGoWork(t *testing.T) {
 	dir := t.TempDir()
 	path := filepath.Join(dir, "go.work")
@@ -11,12 +11,16 @@ type Planner interface {
 	Plan(ctx context.Context, req PlanRequest) (PlanResult, error)
 }
 
-// PlanRequest contains the context needed to generate a plan.
+// PlanRequest contains the context needed to generate a plan. FullRun asks
+// for every job regardless of the diff; Parameters are the run's free-form
+// inputs.
 type PlanRequest struct {
-	RunID     string
-	RepoID    string
-	Ref       string
-	CommitSHA string
+	RunID      string
+	RepoID     string
+	Ref        string
+	CommitSHA  string
+	FullRun    bool
+	Parameters map[string]string
 }
 
 // PlanResult is the outcome of the planning step.
@@ -0,0 +1,31 @@
+package protocol
+
+import (
+	"runtime"
+	"slices"
+	"strings"
+)
+
+// HostLabels returns the labels every runner advertises for its platform.
+func HostLabels() []string {
+	return []string{"os=" + runtime.GOOS, "arch=" + runtime.GOARCH}
+}
+
+// NormalizeLabels trims, de-duplicates and sorts labels, dropping empty ones.
+func NormalizeLabels(labels []string) []string {
+	out := make([]string, 0, len(labels))
+	for _, label := range labels {
+		label = strings.TrimSpace(label)
+		if label != "" {
+			out = append(out, label)
+		}
+	}
+	slices.Sort(out)
+	return slices.Compact(out)
+}
+
+// ParseLabels splits a comma-separated label list such as "gpu,os=linux" and
+// normalizes it.
+func ParseLabels(raw string) []string {
+	return NormalizeLabels(strings.Split(raw, ","))
+}
@@ -7,14 +7,67 @@ type JobSpec struct {
 	Workdir string            `json:"workdir,omitempty"`
 	Steps   []string          `json:"steps"`
 	Env     map[string]string `json:"env,omitempty"`
-	Caches  []CacheSpec       `json:"caches,omitempty"`
+	// SecretEnv names Env entries whose values are masked in logs and summaries.
+	SecretEnv []string       `json:"secret_env,omitempty"`
+	Artifacts []ArtifactSpec `json:"artifacts,omitempty"`
+	Caches    []CacheSpec    `json:"caches,omitempty"`
+	// Resources limits the job's processes when the runner has cgroup v2.
+	Resources *ResourceLimits `json:"resources,omitempty"`
+	// RunsOn lists runner labels ("os=linux", "gpu") that must all be present.
+	RunsOn []string `json:"runs_on,omitempty"`
+	// Retry re-runs a failed job in a new attempt; nil means no retries.
+	Retry *RetryPolicy `json:"retry,omitempty"`
+	// TimeoutSeconds bounds an attempt's runtime; zero uses the orchestrator
+	// default. Runners get it as the lease's max runtime.
+	TimeoutSeconds int `json:"timeout_seconds,omitempty"`
+}
+
+// RetryPolicy controls automatic retries. MaxAttempts counts the first
+// attempt. RetryOn limits retries to failure categories ("INFRA", "FLAKY",
+// ...); empty retries any failure. The delay before attempt N+1 is
+// BackoffSeconds doubled for every earlier retry.
+type RetryPolicy struct {
+	MaxAttempts    int      `json:"max_attempts"`
+	BackoffSeconds int      `json:"backoff_seconds,omitempty"`
+	RetryOn        []string `json:"retry_on,omitempty"`
+}
+
+// ResourceLimits caps a job's CPU, memory and process count. Zero values mean
+// no limit.
+type ResourceLimits struct {
+	CPUs        float64 `json:"cpus,omitempty"`
+	MemoryBytes int64   `json:"memory_bytes,omitempty"`
+	Pids        int64   `json:"pids,omitempty"`
+}
+
+// ArtifactSpec declares files to upload after the steps run. Path is a glob
+// relative to the job workdir; "**" matches any number of directories.
+// Files larger than MaxBytes are skipped (0 uses the runner default).
+type ArtifactSpec struct {
+	Type     string `json:"type"`
+	Path     string `json:"path"`
+	MaxBytes int64  `json:"max_bytes,omitempty"`
 }
 
 type CacheSpec struct {
-	Type     string   `json:"type"`
-	Key      string   `json:"key"`
-	Paths    []string `json:"paths"`
-	ReadOnly bool     `json:"read_only,omitempty"`
+	Type string `json:"type"`
+	Key  string `json:"key"`
+	// RestoreKeys are ordered key prefixes tried when Key misses; the most
+	// recent entry matching the first prefix with any match is restored.
+	RestoreKeys []string `json:"restore_keys,omitempty"`
+	Paths       []string `json:"paths"`
+	ReadOnly    bool     `json:"read_only,omitempty"`
+}
+
+// RequestLease is sent by a runner polling for work. The orchestrator holds the
+// request open for up to WaitSeconds and answers with a LeaseGranted, or with
+// no content when nothing became available.
+type RequestLease struct {
+	Type        string   `json:"type"` // always "RequestLease"
+	RunnerID    string   `json:"runner_id"`
+	WaitSeconds int      `json:"wait_seconds,omitempty"`
+	Labels      []string `json:"labels,omitempty"`  // capabilities matched against JobSpec.RunsOn
+	Version     string   `json:"version,omitempty"` // runner build, shown in the runner inventory
 }
 
 // LeaseGranted is sent from orchestrator to runner.
@@ -22,7 +75,12 @@ type LeaseGranted struct {
 	Type                     string  `json:"type"` // always "LeaseGranted"
 	RunID                    string  `json:"run_id"`
 	JobID                    string  `json:"job_id"`
+	AttemptNumber            int     `json:"attempt_number,omitempty"`
+	RepoURL                  string  `json:"repo_url,omitempty"` // clone URL or local path
+	Ref                      string  `json:"ref,omitempty"`
+	CommitSHA                string  `json:"commit_sha,omitempty"`
 	LeaseID                  string  `json:"lease_id"`
+	LeaseToken               string  `json:"lease_token,omitempty"` // bearer token for this lease's messages
 	LeaseTTLSeconds          int     `json:"lease_ttl_seconds"`
 	HeartbeatIntervalSeconds int     `json:"heartbeat_interval_seconds"`
 	MaxRuntimeSeconds        int     `json:"max_runtime_seconds,omitempty"`
@@ -40,10 +98,22 @@ type AckLease struct {
 
 // Heartbeat keeps a lease alive and reports optional progress.
 type Heartbeat struct {
-	Type     string    `json:"type"` // always "Heartbeat"
-	LeaseID  string    `json:"lease_id"`
-	RunnerID string    `json:"runner_id"`
-	TS       time.Time `json:"ts"`
+	Type     string       `json:"type"` // always "Heartbeat"
+	LeaseID  string       `json:"lease_id"`
+	RunnerID string       `json:"runner_id"`
+	TS       time.Time    `json:"ts"`
+	Progress *JobProgress `json:"progress,omitempty"`
+}
+
+// JobProgress describes what a running job is doing at heartbeat time.
+// StepIndex is zero-based and -1 before the first step starts.
+type JobProgress struct {
+	StepIndex      int    `json:"step_index"`
+	StepCount      int    `json:"step_count"`
+	StepName       string `json:"step_name,omitempty"`
+	ElapsedSeconds int64  `json:"elapsed_seconds"`
+	LogBytes       int64  `json:"log_bytes"`
+	Status         string `json:"status,omitempty"`
 }
 
 // HeartbeatAck is returned by the orchestrator in response to a heartbeat.
@@ -54,6 +124,20 @@ type HeartbeatAck struct {
 	NewLeaseTTLSeconds    int    `json:"new_lease_ttl_seconds"`
 	CancelRequested       bool   `json:"cancel_requested"`
 	CancelDeadlineSeconds int    `json:"cancel_deadline_seconds"`
+	LeaseToken            string `json:"lease_token,omitempty"` // replaces the token for the extended TTL
+}
+
+// LogChunk streams a slice of the job log while the job runs. Seq increases by
+// one per chunk and Offset is the chunk's byte position in the log; resending a
+// chunk with the same Seq is a no-op.
+type LogChunk struct {
+	Type     string    `json:"type"` // always "LogChunk"
+	LeaseID  string    `json:"lease_id"`
+	RunnerID string    `json:"runner_id"`
+	Seq      int64     `json:"seq"`
+	Offset   int64     `json:"offset"`
+	Data     []byte    `json:"data"` // base64 encoded
+	TS       time.Time `json:"ts"`
 }
 
 type CompleteStatus string
@@ -61,11 +145,56 @@ type CompleteStatus string
 const (
 	CompleteStatusSucceeded CompleteStatus = "SUCCEEDED"
 	CompleteStatusFailed    CompleteStatus = "FAILED"
+	CompleteStatusTimedOut  CompleteStatus = "TIMED_OUT"
 )
 
+type StepStatus string
+
+const (
+	StepStatusSucceeded StepStatus = "SUCCEEDED"
+	StepStatusFailed    StepStatus = "FAILED"
+	StepStatusCanceled  StepStatus = "CANCELED"
+	StepStatusTimedOut  StepStatus = "TIMED_OUT"
+	StepStatusSkipped   StepStatus = "SKIPPED"
+)
+
+// StepResult reports the outcome of a single JobSpec step.
+// LogOffset and LogBytes locate the step's output within the job log.
+type StepResult struct {
+	Index      int        `json:"index"`
+	Command    string     `json:"command"`
+	Status     StepStatus `json:"status"`
+	ExitCode   int        `json:"exit_code"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	LogOffset  int64      `json:"log_offset"`
+	LogBytes   int64      `json:"log_bytes"`
+}
+
+type TestStatus string
+
+const (
+	TestStatusPassed  TestStatus = "PASSED"
+	TestStatusFailed  TestStatus = "FAILED"
+	TestStatusSkipped TestStatus = "SKIPPED"
+)
+
+// TestResult reports a single test parsed from `go test -json` output or a
+// JUnit report. An empty Name marks a package-level failure (e.g. a build
+// error). Output holds an excerpt of the test's output for failures only.
+type TestResult struct {
+	Package    string     `json:"package,omitempty"`
+	Name       string     `json:"name"`
+	Status     TestStatus `json:"status"`
+	DurationMs int64      `json:"duration_ms"`
+	Output     string     `json:"output,omitempty"`
+}
+
 type ArtifactRef struct {
-	Type string `json:"type"`
-	URI  string `json:"uri"`
+	Type      string `json:"type"`
+	URI       string `json:"uri"`
+	SizeBytes int64  `json:"size_bytes,omitempty"`
+	SHA256    string `json:"sha256,omitempty"`
 }
 
 // Complete is sent by the runner when execution finishes.
@@ -79,13 +208,35 @@ type Complete struct {
 	Summary    string         `json:"summary,omitempty"`
 	Artifacts  []ArtifactRef  `json:"artifacts,omitempty"`
 	Caches     []CacheEvent   `json:"caches,omitempty"`
+	Steps      []StepResult   `json:"steps,omitempty"`
+	Tests      []TestResult   `json:"tests,omitempty"`
+	Resources  *ResourceUsage `json:"resources,omitempty"`
+}
+
+// ResourceUsage reports what a job consumed. OOMKilled is only known when the
+// job ran in a cgroup.
+type ResourceUsage struct {
+	PeakMemoryBytes int64   `json:"peak_memory_bytes"`
+	CPUSeconds      float64 `json:"cpu_seconds"`
+	OOMKilled       bool    `json:"oom_killed"`
 }
 
+type CacheMatch string
+
+const (
+	CacheMatchExact   CacheMatch = "exact"
+	CacheMatchPartial CacheMatch = "partial"
+)
+
+// CacheEvent reports a cache restore. Hit is true for exact and partial
+// matches; MatchedKey is the restored entry's key.
 type CacheEvent struct {
-	Type     string `json:"type"`
-	Key      string `json:"key"`
-	Hit      bool   `json:"hit"`
-	ReadOnly bool   `json:"read_only,omitempty"`
+	Type       string     `json:"type"`
+	Key        string     `json:"key"`
+	Hit        bool       `json:"hit"`
+	Match      CacheMatch `json:"match,omitempty"`
+	MatchedKey string     `json:"matched_key,omitempty"`
+	ReadOnly   bool       `json:"read_only,omitempty"`
 }
 
 type CancelFinalStatus string
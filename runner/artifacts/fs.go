@@ -0,0 +1,62 @@
+package artifacts
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+// FileStore stores artifacts on the local filesystem, for single-machine
+// installs and tests.
+type FileStore struct {
+	root string
+}
+
+// NewFileStore returns a store rooted at root, creating it if needed.
+func NewFileStore(root string) (*FileStore, error) {
+	if root == "" {
+		return nil, errors.New("artifact root is required")
+	}
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(abs, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileStore{root: abs}, nil
+}
+
+// Put writes the artifact atomically and returns a file:// URI.
+func (s *FileStore) Put(ctx context.Context, obj Object, body io.Reader, contentType string) (string, error) {
+	key, err := obj.Key()
+	if err != nil {
+		return "", err
+	}
+	dest := filepath.Join(s.root, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return "", err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(dest), ".upload-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, body); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), dest); err != nil {
+		return "", err
+	}
+
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(dest)}).String(), nil
+}
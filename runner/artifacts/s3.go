@@ -3,7 +3,7 @@ package artifacts
 import (
 	"context"
 	"fmt"
-	"os"
+	"io"
 	"path"
 	"strings"
 
@@ -11,22 +11,41 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 )
 
-// S3Config configures the S3 uploader.
+// S3Config configures the S3 store.
 type S3Config struct {
 	Bucket string
 	Prefix string
 	Region string
+	// Endpoint overrides the service endpoint for S3-compatible services.
+	Endpoint string
+	// PathStyle addresses objects as endpoint/bucket/key instead of bucket.endpoint/key.
+	PathStyle bool
 }
 
-// S3Uploader uploads artifacts to AWS S3.
-type S3Uploader struct {
+// S3Store stores artifacts in AWS S3 or an S3-compatible service.
+type S3Store struct {
 	client *s3.Client
 	bucket string
 	prefix string
 }
 
-// NewS3Uploader loads AWS config and prepares an uploader.
-func NewS3Uploader(ctx context.Context, cfg S3Config) (*S3Uploader, error) {
+// NewS3Store loads AWS config and prepares a store.
+func NewS3Store(ctx context.Context, cfg S3Config) (*S3Store, error) {
+	client, err := NewS3Client(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &S3Store{
+		client: client,
+		bucket: cfg.Bucket,
+		prefix: strings.Trim(cfg.Prefix, "/"),
+	}, nil
+}
+
+// NewS3Client builds an S3 client honouring custom endpoints and path-style
+// addressing. It is shared with other runner stores that use S3.
+func NewS3Client(ctx context.Context, cfg S3Config) (*s3.Client, error) {
 	if cfg.Bucket == "" {
 		return nil, fmt.Errorf("s3 bucket is required")
 	}
@@ -41,40 +60,43 @@ func NewS3Uploader(ctx context.Context, cfg S3Config) (*S3Uploader, error) {
 		return nil, err
 	}
 
-	return &S3Uploader{
-		client: s3.NewFromConfig(awsCfg),
-		bucket: cfg.Bucket,
-		prefix: strings.Trim(cfg.Prefix, "/"),
-	}, nil
+	client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		if cfg.Endpoint != "" {
+			o.BaseEndpoint = ptr(cfg.Endpoint)
+		}
+		o.UsePathStyle = cfg.PathStyle
+	})
+	return client, nil
 }
 
-// UploadLog uploads the log file and returns a s3:// URI.
-func (u *S3Uploader) UploadLog(ctx context.Context, runID, jobID, logPath string) (string, error) {
-	key := u.objectKey("runs", runID, "jobs", jobID, "log.txt")
-	file, err := os.Open(logPath)
+// Put uploads the artifact and returns a s3:// URI.
+func (s *S3Store) Put(ctx context.Context, obj Object, body io.Reader, contentType string) (string, error) {
+	objectKey, err := obj.Key()
 	if err != nil {
 		return "", err
 	}
-	defer file.Close()
+	key := s.objectKey(objectKey)
 
-	_, err = u.client.PutObject(ctx, &s3.PutObjectInput{
-		Bucket:      &u.bucket,
-		Key:         &key,
-		Body:        file,
-		ContentType: ptr("text/plain"),
-	})
-	if err != nil {
+	input := &s3.PutObjectInput{
+		Bucket: &s.bucket,
+		Key:    &key,
+		Body:   body,
+	}
+	if contentType != "" {
+		input.ContentType = ptr(contentType)
+	}
+	if _, err := s.client.PutObject(ctx, input); err != nil {
 		return "", err
 	}
 
-	return fmt.Sprintf("s3://%s/%s", u.bucket, key), nil
+	return fmt.Sprintf("s3://%s/%s", s.bucket, key), nil
 }
 
-func (u *S3Uploader) objectKey(parts ...string) string {
-	if u.prefix == "" {
-		return path.Join(parts...)
+func (s *S3Store) objectKey(key string) string {
+	if s.prefix == "" {
+		return key
 	}
-	return path.Join(append([]string{u.prefix}, parts...)...)
+	return path.Join(s.prefix, key)
 }
 
 func ptr[T any](v T) *T {
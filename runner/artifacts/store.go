@@ -0,0 +1,96 @@
+package artifacts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Object identifies an artifact within a job attempt.
+type Object struct {
+	RunID   string
+	JobID   string
+	Attempt int
+	// Name is the artifact's path relative to the attempt, e.g. "log.txt".
+	Name string
+}
+
+// Key returns the backend-independent object layout:
+// runs/{run}/jobs/{job}/attempts/{attempt}/{name}.
+func (o Object) Key() (string, error) {
+	if o.RunID == "" || o.JobID == "" || o.Name == "" {
+		return "", errors.New("artifact run id, job id and name are required")
+	}
+	attempt := o.Attempt
+	if attempt <= 0 {
+		attempt = 1
+	}
+	name := path.Clean("/" + strings.ReplaceAll(o.Name, "\\", "/"))
+	if name == "/" {
+		return "", fmt.Errorf("invalid artifact name %q", o.Name)
+	}
+	return path.Join("runs", o.RunID, "jobs", o.JobID, "attempts", strconv.Itoa(attempt), name), nil
+}
+
+// ArtifactStore persists job artifacts.
+type ArtifactStore interface {
+	// Put stores body under the object's key and returns the artifact URI.
+	Put(ctx context.Context, obj Object, body io.Reader, contentType string) (string, error)
+}
+
+// Open selects a backend by URL scheme:
+//
+//	file:///var/lib/delta-ci/artifacts (or a plain path)
+//	s3://bucket/prefix?region=us-east-1&endpoint=http://minio:9000&path_style=true
+func Open(ctx context.Context, rawURL string) (ArtifactStore, error) {
+	if rawURL == "" {
+		return nil, errors.New("artifact store url is required")
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse artifact store url: %w", err)
+	}
+
+	switch u.Scheme {
+	case "", "file":
+		root := u.Path
+		if u.Scheme == "" {
+			root = rawURL
+		}
+		return NewFileStore(root)
+	case "s3":
+		cfg, err := S3ConfigFromURL(u)
+		if err != nil {
+			return nil, err
+		}
+		return NewS3Store(ctx, cfg)
+	default:
+		return nil, fmt.Errorf("unsupported artifact store scheme %q", u.Scheme)
+	}
+}
+
+// S3ConfigFromURL reads s3://bucket/prefix?region=&endpoint=&path_style=.
+func S3ConfigFromURL(u *url.URL) (S3Config, error) {
+	query := u.Query()
+	cfg := S3Config{
+		Bucket:   u.Host,
+		Prefix:   strings.Trim(u.Path, "/"),
+		Region:   query.Get("region"),
+		Endpoint: query.Get("endpoint"),
+	}
+	// Custom endpoints are usually MinIO-style services that need path-style addressing.
+	cfg.PathStyle = cfg.Endpoint != ""
+	if value := query.Get("path_style"); value != "" {
+		pathStyle, err := strconv.ParseBool(value)
+		if err != nil {
+			return S3Config{}, fmt.Errorf("invalid path_style %q", value)
+		}
+		cfg.PathStyle = pathStyle
+	}
+	return cfg, nil
+}
@@ -0,0 +1,73 @@
+package artifacts
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestObjectKey(t *testing.T) {
+	key, err := Object{RunID: "run1", JobID: "job1", Attempt: 2, Name: "reports/../junit.xml"}.Key()
+	if err != nil {
+		t.Fatalf("key: %v", err)
+	}
+	if key != "runs/run1/jobs/job1/attempts/2/junit.xml" {
+		t.Fatalf("unexpected key %q", key)
+	}
+
+	key, err = Object{RunID: "run1", JobID: "job1", Name: "../../escape.txt"}.Key()
+	if err != nil {
+		t.Fatalf("key: %v", err)
+	}
+	if key != "runs/run1/jobs/job1/attempts/1/escape.txt" {
+		t.Fatalf("expected name to stay under attempt, got %q", key)
+	}
+
+	if _, err := (Object{RunID: "run1", Name: "log.txt"}).Key(); err == nil {
+		t.Fatal("expected error for missing job id")
+	}
+}
+
+func TestFileStorePut(t *testing.T) {
+	root := t.TempDir()
+	store, err := Open(context.Background(), "file://"+root)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+
+	uri, err := store.Put(context.Background(), Object{RunID: "run1", JobID: "job1", Attempt: 3, Name: "log.txt"}, strings.NewReader("hello"), "text/plain")
+	if err != nil {
+		t.Fatalf("put: %v", err)
+	}
+
+	want := filepath.Join(root, "runs", "run1", "jobs", "job1", "attempts", "3", "log.txt")
+	data, err := os.ReadFile(want)
+	if err != nil {
+		t.Fatalf("read artifact: %v", err)
+	}
+	if string(data) != "hello" {
+		t.Fatalf("unexpected content %q", data)
+	}
+
+	parsed, err := url.Parse(uri)
+	if err != nil || parsed.Scheme != "file" || parsed.Path != filepath.ToSlash(want) {
+		t.Fatalf("unexpected uri %q", uri)
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(want))
+	if err != nil {
+		t.Fatalf("read dir: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected only the artifact in attempt dir, got %d entries", len(entries))
+	}
+}
+
+func TestOpenUnsupportedScheme(t *testing.T) {
+	if _, err := Open(context.Background(), "gs://bucket/prefix"); err == nil {
+		t.Fatal("expected unsupported scheme error")
+	}
+}
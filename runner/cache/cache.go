@@ -0,0 +1,237 @@
+// Package cache stores runner cache entries as compressed tar archives in a
+// local directory, an S3-compatible bucket or an HTTP cache server.
+//
+// Every backend uses the same layout:
+//
+//	{type}/entries/{escaped key}.json   manifest naming the archive checksum
+//	{type}/blobs/{sha256}.tar.gz        content-addressed archive
+//
+// Archives are uploaded before the manifest, so a published manifest always
+// points at a complete archive, and readers verify the checksum on download.
+package cache
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// ErrNotFound reports a cache miss.
+var ErrNotFound = errors.New("cache entry not found")
+
+// Entry describes a published cache archive.
+type Entry struct {
+	Type      string    `json:"type"`
+	Key       string    `json:"key"`
+	SHA256    string    `json:"sha256"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Backend stores cache archives keyed by (type, key).
+type Backend interface {
+	// Lookup returns the entry published for key, or ErrNotFound.
+	Lookup(ctx context.Context, cacheType, key string) (Entry, error)
+	// LookupPrefix returns the most recently published entry whose key starts
+	// with prefix, or ErrNotFound.
+	LookupPrefix(ctx context.Context, cacheType, prefix string) (Entry, error)
+	// Open streams the entry's archive.
+	Open(ctx context.Context, entry Entry) (io.ReadCloser, error)
+	// Publish uploads archive and then makes entry visible to Lookup.
+	Publish(ctx context.Context, entry Entry, archive io.Reader) error
+}
+
+// objectStore is the minimal blob interface each backend provides.
+type objectStore interface {
+	get(ctx context.Context, name string) (io.ReadCloser, error)
+	// put must make name visible atomically: readers see the old or the new
+	// object, never a partial one.
+	put(ctx context.Context, name string, body io.ReadSeeker, size int64) error
+	// list returns objects whose name starts with prefix.
+	list(ctx context.Context, prefix string) ([]objectInfo, error)
+}
+
+// toucher is implemented by stores that track last use per object.
+type toucher interface {
+	touch(name string)
+}
+
+type objectInfo struct {
+	name    string
+	modTime time.Time
+}
+
+// errListUnsupported is returned by object stores that cannot list names.
+var errListUnsupported = errors.New("cache backend does not support prefix lookups")
+
+// Store implements Backend on top of an object store.
+type Store struct {
+	objects objectStore
+}
+
+func (s *Store) Lookup(ctx context.Context, cacheType, key string) (Entry, error) {
+	body, err := s.objects.get(ctx, manifestName(cacheType, key))
+	if err != nil {
+		return Entry{}, err
+	}
+	defer body.Close()
+
+	var entry Entry
+	if err := json.NewDecoder(io.LimitReader(body, 1<<20)).Decode(&entry); err != nil {
+		return Entry{}, fmt.Errorf("decode cache manifest: %w", err)
+	}
+	if entry.Key != key || !validSHA256(entry.SHA256) {
+		return Entry{}, fmt.Errorf("invalid cache manifest for key %q", key)
+	}
+	return entry, nil
+}
+
+func (s *Store) LookupPrefix(ctx context.Context, cacheType, prefix string) (Entry, error) {
+	if prefix == "" {
+		return Entry{}, errors.New("restore key prefix is required")
+	}
+	objects, err := s.objects.list(ctx, entriesDir(cacheType)+"/"+url.PathEscape(prefix))
+	if errors.Is(err, errListUnsupported) {
+		return Entry{}, ErrNotFound
+	}
+	if err != nil {
+		return Entry{}, err
+	}
+	var newest *objectInfo
+	for i, object := range objects {
+		if !strings.HasSuffix(object.name, ".json") {
+			continue
+		}
+		if newest == nil || object.modTime.After(newest.modTime) {
+			newest = &objects[i]
+		}
+	}
+	if newest == nil {
+		return Entry{}, ErrNotFound
+	}
+	key, err := url.PathUnescape(strings.TrimSuffix(path.Base(newest.name), ".json"))
+	if err != nil {
+		return Entry{}, err
+	}
+	return s.Lookup(ctx, cacheType, key)
+}
+
+func (s *Store) Open(ctx context.Context, entry Entry) (io.ReadCloser, error) {
+	if !validSHA256(entry.SHA256) {
+		return nil, errors.New("invalid cache entry checksum")
+	}
+	name := blobName(entry.Type, entry.SHA256)
+	if t, ok := s.objects.(toucher); ok {
+		t.touch(name)
+	}
+	return s.objects.get(ctx, name)
+}
+
+// LocalRoot returns the directory of a local backend, for garbage collection.
+func (s *Store) LocalRoot() (string, bool) {
+	objects, ok := s.objects.(fsObjects)
+	return objects.root, ok
+}
+
+func (s *Store) Publish(ctx context.Context, entry Entry, archive io.Reader) error {
+	if entry.Key == "" || !validSHA256(entry.SHA256) {
+		return errors.New("cache entry requires key and sha256")
+	}
+	body, ok := archive.(io.ReadSeeker)
+	if !ok {
+		return errors.New("cache archive must be seekable")
+	}
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now().UTC()
+	}
+	if err := s.objects.put(ctx, blobName(entry.Type, entry.SHA256), body, entry.Size); err != nil {
+		return fmt.Errorf("upload cache archive: %w", err)
+	}
+
+	manifest, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if err := s.objects.put(ctx, manifestName(entry.Type, entry.Key), bytes.NewReader(manifest), int64(len(manifest))); err != nil {
+		return fmt.Errorf("publish cache manifest: %w", err)
+	}
+	return nil
+}
+
+// Download copies an entry's archive into a temp file under dir and verifies
+// its checksum. The caller removes the returned file.
+func Download(ctx context.Context, backend Backend, entry Entry, dir string) (*os.File, error) {
+	body, err := backend.Open(ctx, entry)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	file, err := os.CreateTemp(dir, "restore-*.tar.gz")
+	if err != nil {
+		return nil, err
+	}
+	fail := func(err error) (*os.File, error) {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(file, hash), body); err != nil {
+		return fail(err)
+	}
+	if sum := hex.EncodeToString(hash.Sum(nil)); sum != entry.SHA256 {
+		return fail(fmt.Errorf("cache archive checksum mismatch: got %s, want %s", sum, entry.SHA256))
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return fail(err)
+	}
+	return file, nil
+}
+
+func manifestName(cacheType, key string) string {
+	return entriesDir(cacheType) + "/" + url.PathEscape(key) + ".json"
+}
+
+func entriesDir(cacheType string) string {
+	return path.Join(typeDir(cacheType), "entries")
+}
+
+func blobName(cacheType, sum string) string {
+	return path.Join(typeDir(cacheType), "blobs", sum+".tar.gz")
+}
+
+func typeDir(cacheType string) string {
+	value := strings.TrimSpace(strings.ToLower(cacheType))
+	if value == "" {
+		return "cache"
+	}
+	value = strings.ReplaceAll(value, "/", "_")
+	value = strings.ReplaceAll(value, "\\", "_")
+	if value == "." || value == ".." {
+		return "cache"
+	}
+	return value
+}
+
+func validSHA256(value string) bool {
+	if len(value) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(value)
+	return err == nil
+}
@@ -0,0 +1,257 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLocalPublishAndRestore(t *testing.T) {
+	store, err := NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatalf("new local: %v", err)
+	}
+	testRoundTrip(t, store)
+}
+
+func TestHTTPPublishAndRestore(t *testing.T) {
+	var mu sync.Mutex
+	objects := make(map[string][]byte)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.Method {
+		case http.MethodGet:
+			data, ok := objects[r.URL.Path]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			w.Write(data)
+		case http.MethodPut:
+			data, _ := io.ReadAll(r.Body)
+			objects[r.URL.Path] = data
+			w.WriteHeader(http.StatusCreated)
+		}
+	}))
+	defer server.Close()
+
+	store, err := Open(context.Background(), server.URL+"/cache", "")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	testRoundTrip(t, store)
+}
+
+func testRoundTrip(t *testing.T, store *Store) {
+	t.Helper()
+	ctx := context.Background()
+
+	if _, err := store.Lookup(ctx, "deps", "go-mod:abc"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected miss, got %v", err)
+	}
+
+	src := t.TempDir()
+	writeTestFile(t, filepath.Join(src, "mod", "example.com", "a.go"), "package a", 0o444)
+	writeTestFile(t, filepath.Join(src, "single.txt"), "one file", 0o644)
+
+	entry := publishTestEntry(t, store, "deps", "go-mod:abc", map[string]string{
+		"dir":  filepath.Join(src, "mod"),
+		"file": filepath.Join(src, "single.txt"),
+	})
+
+	found, err := store.Lookup(ctx, "deps", "go-mod:abc")
+	if err != nil {
+		t.Fatalf("lookup: %v", err)
+	}
+	if found.SHA256 != entry.SHA256 || found.Size != entry.Size {
+		t.Fatalf("unexpected entry %+v", found)
+	}
+
+	archive, err := Download(ctx, store, found, t.TempDir())
+	if err != nil {
+		t.Fatalf("download: %v", err)
+	}
+	defer archive.Close()
+
+	dest := t.TempDir()
+	// Restoring over read-only files from a previous restore must work.
+	writeTestFile(t, filepath.Join(dest, "mod", "example.com", "a.go"), "stale", 0o444)
+	if err := ExtractArchive(archive, map[string]string{
+		"dir":  filepath.Join(dest, "mod"),
+		"file": filepath.Join(dest, "restored.txt"),
+	}); err != nil {
+		t.Fatalf("extract: %v", err)
+	}
+	assertFile(t, filepath.Join(dest, "mod", "example.com", "a.go"), "package a")
+	assertFile(t, filepath.Join(dest, "restored.txt"), "one file")
+}
+
+func TestDownloadRejectsChecksumMismatch(t *testing.T) {
+	root := t.TempDir()
+	store, err := NewLocal(root)
+	if err != nil {
+		t.Fatalf("new local: %v", err)
+	}
+	src := t.TempDir()
+	writeTestFile(t, filepath.Join(src, "a.txt"), "content", 0o644)
+	entry := publishTestEntry(t, store, "deps", "key", map[string]string{"a": filepath.Join(src, "a.txt")})
+
+	blob := filepath.Join(root, "deps", "blobs", entry.SHA256+".tar.gz")
+	if err := os.Chmod(blob, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(blob, []byte("corrupted"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tmp := t.TempDir()
+	if _, err := Download(context.Background(), store, entry, tmp); err == nil {
+		t.Fatal("expected checksum mismatch")
+	}
+	if leftovers, _ := os.ReadDir(tmp); len(leftovers) != 0 {
+		t.Fatalf("expected temp archive to be removed, found %d files", len(leftovers))
+	}
+}
+
+func publishTestEntry(t *testing.T, store *Store, cacheType, key string, roots map[string]string) Entry {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := WriteArchive(&buf, roots); err != nil {
+		t.Fatalf("write archive: %v", err)
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	entry := Entry{Type: cacheType, Key: key, SHA256: hex.EncodeToString(sum[:]), Size: int64(buf.Len())}
+	if err := store.Publish(context.Background(), entry, bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	return entry
+}
+
+func writeTestFile(t *testing.T, path, content string, mode os.FileMode) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), mode); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func assertFile(t *testing.T, path, want string) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read %s: %v", path, err)
+	}
+	if string(data) != want {
+		t.Fatalf("%s: expected %q, got %q", path, want, data)
+	}
+}
+
+func TestLookupPrefixReturnsNewestMatch(t *testing.T) {
+	root := t.TempDir()
+	store, err := NewLocal(root)
+	if err != nil {
+		t.Fatalf("new local: %v", err)
+	}
+	src := t.TempDir()
+	writeTestFile(t, filepath.Join(src, "a.txt"), "content", 0o644)
+	roots := map[string]string{"a": filepath.Join(src, "a.txt")}
+
+	publishTestEntry(t, store, "deps", "go:deps:api:old", roots)
+	publishTestEntry(t, store, "deps", "go:deps:api:new", roots)
+	publishTestEntry(t, store, "deps", "go:deps:web:other", roots)
+
+	// Make the ordering independent of filesystem timestamp resolution.
+	past := time.Now().Add(-time.Hour)
+	oldManifest := filepath.Join(root, "deps", "entries", url.PathEscape("go:deps:api:old")+".json")
+	if err := os.Chtimes(oldManifest, past, past); err != nil {
+		t.Fatal(err)
+	}
+
+	entry, err := store.LookupPrefix(context.Background(), "deps", "go:deps:api:")
+	if err != nil {
+		t.Fatalf("lookup prefix: %v", err)
+	}
+	if entry.Key != "go:deps:api:new" {
+		t.Fatalf("expected newest entry, got %q", entry.Key)
+	}
+
+	if _, err := store.LookupPrefix(context.Background(), "deps", "go:deps:cli:"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected miss for unmatched prefix, got %v", err)
+	}
+}
+
+func TestGCEvictsLeastRecentlyUsed(t *testing.T) {
+	root := t.TempDir()
+	store, err := NewLocal(root)
+	if err != nil {
+		t.Fatalf("new local: %v", err)
+	}
+	src := t.TempDir()
+	entries := make(map[string]Entry)
+	for i, key := range []string{"a", "b", "c"} {
+		path := filepath.Join(src, key+".txt")
+		writeTestFile(t, path, strings.Repeat(key, 1000), 0o644)
+		entry := publishTestEntry(t, store, "deps", key, map[string]string{"f": path})
+		entries[key] = entry
+		used := time.Now().Add(-time.Duration(3-i) * time.Hour)
+		if err := os.Chtimes(filepath.Join(root, blobName("deps", entry.SHA256)), used, used); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Restoring "a" makes "b" the least recently used entry.
+	body, err := store.Open(context.Background(), entries["a"])
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	body.Close()
+
+	orphan := filepath.Join(root, "deps", "blobs", strings.Repeat("0", 64)+".tar.gz")
+	writeTestFile(t, orphan, "orphan", 0o644)
+	stale := time.Now().Add(-2 * gcGrace)
+	if err := os.Chtimes(orphan, stale, stale); err != nil {
+		t.Fatal(err)
+	}
+
+	before, err := GC(root, 0, nil)
+	if err != nil {
+		t.Fatalf("measure: %v", err)
+	}
+	if _, err := os.Stat(orphan); !os.IsNotExist(err) {
+		t.Fatalf("expected stale unreferenced archive to be removed, got %v", err)
+	}
+
+	stats, err := GC(root, before.RemainingBytes-1, nil)
+	if err != nil {
+		t.Fatalf("gc: %v", err)
+	}
+	if stats.EvictedEntries != 1 || stats.EvictedBytes != entries["b"].Size {
+		t.Fatalf("expected one eviction of %d bytes, got %+v", entries["b"].Size, stats)
+	}
+	if stats.RemainingBytes >= before.RemainingBytes {
+		t.Fatalf("expected remaining bytes below %d, got %d", before.RemainingBytes, stats.RemainingBytes)
+	}
+	if _, err := store.Lookup(context.Background(), "deps", "b"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected b to be evicted, got %v", err)
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, err := store.Lookup(context.Background(), "deps", key); err != nil {
+			t.Fatalf("expected %s to survive: %v", key, err)
+		}
+	}
+}
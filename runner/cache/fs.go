@@ -0,0 +1,91 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// fsObjects keeps cache objects in a local directory.
+type fsObjects struct {
+	root string
+}
+
+// NewLocal returns a backend storing archives under root.
+func NewLocal(root string) (*Store, error) {
+	if root == "" {
+		return nil, errors.New("cache root is required")
+	}
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, err
+	}
+	return &Store{objects: fsObjects{root: root}}, nil
+}
+
+func (o fsObjects) get(ctx context.Context, name string) (io.ReadCloser, error) {
+	file, err := os.Open(o.path(name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return file, err
+}
+
+func (o fsObjects) put(ctx context.Context, name string, body io.ReadSeeker, size int64) error {
+	dest := o.path(name)
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dest), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, body); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), dest)
+}
+
+func (o fsObjects) list(ctx context.Context, prefix string) ([]objectInfo, error) {
+	dir, base := path.Split(prefix)
+	entries, err := os.ReadDir(o.path(dir))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var objects []objectInfo
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasPrefix(entry.Name(), base) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		objects = append(objects, objectInfo{name: dir + entry.Name(), modTime: info.ModTime()})
+	}
+	return objects, nil
+}
+
+// touch records a use of name for LRU eviction.
+func (o fsObjects) touch(name string) {
+	now := time.Now()
+	_ = os.Chtimes(o.path(name), now, now)
+}
+
+func (o fsObjects) path(name string) string {
+	return filepath.Join(o.root, filepath.FromSlash(name))
+}
@@ -0,0 +1,157 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// gcGrace protects fresh files that are not referenced yet: archives whose
+// manifest is still being published and in-flight temp files.
+const gcGrace = time.Hour
+
+// LockFunc takes an exclusive lock on path and returns its release function.
+type LockFunc func(path string) (func(), error)
+
+// GCStats summarizes a garbage collection pass.
+type GCStats struct {
+	EvictedEntries int
+	EvictedBytes   int64
+	RemainingBytes int64
+}
+
+type gcBlob struct {
+	path      string
+	size      int64
+	lastUsed  time.Time
+	manifests []string
+}
+
+// GC evicts least-recently-used entries from a local cache root until it fits
+// in budget bytes; a non-positive budget disables eviction. An archive's
+// modification time records its last use (set on publish and on every
+// restore). Either way, temp files and unreferenced archives older than gcGrace
+// are removed. lock serializes passes across runner processes sharing root.
+func GC(root string, budget int64, lock LockFunc) (GCStats, error) {
+	var stats GCStats
+	if lock != nil {
+		unlock, err := lock(filepath.Join(root, ".gc.lock"))
+		if err != nil {
+			return stats, err
+		}
+		defer unlock()
+	}
+
+	blobs, other, err := scanCacheRoot(root)
+	if err != nil {
+		return stats, err
+	}
+
+	now := time.Now()
+	var total int64
+	live := make([]*gcBlob, 0, len(blobs))
+	for _, blob := range blobs {
+		if len(blob.manifests) == 0 && now.Sub(blob.lastUsed) > gcGrace {
+			if err := os.Remove(blob.path); err == nil {
+				stats.EvictedBytes += blob.size
+			}
+			continue
+		}
+		total += blob.size
+		live = append(live, blob)
+	}
+	total += other
+
+	sort.Slice(live, func(i, j int) bool {
+		return live[i].lastUsed.Before(live[j].lastUsed)
+	})
+	for _, blob := range live {
+		if budget <= 0 || total <= budget {
+			break
+		}
+		if len(blob.manifests) == 0 {
+			continue
+		}
+		// Drop manifests first so no reader is pointed at a missing archive.
+		for _, manifest := range blob.manifests {
+			if err := os.Remove(manifest); err == nil {
+				stats.EvictedEntries++
+			}
+		}
+		if err := os.Remove(blob.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		total -= blob.size
+		stats.EvictedBytes += blob.size
+	}
+
+	stats.RemainingBytes = total
+	return stats, nil
+}
+
+// scanCacheRoot indexes archives by path with the manifests that reference
+// them, removes stale temp files, and returns the size of everything else.
+func scanCacheRoot(root string) (map[string]*gcBlob, int64, error) {
+	blobs := make(map[string]*gcBlob)
+	var manifests []string
+	var other int64
+	now := time.Now()
+
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || d.Name() == ".gc.lock" {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		dir := filepath.Base(filepath.Dir(path))
+		switch {
+		case strings.HasPrefix(d.Name(), ".tmp-") || dir == "tmp":
+			if now.Sub(info.ModTime()) > gcGrace {
+				_ = os.Remove(path)
+				return nil
+			}
+			other += info.Size()
+		case dir == "blobs" && strings.HasSuffix(d.Name(), ".tar.gz"):
+			blobs[path] = &gcBlob{path: path, size: info.Size(), lastUsed: info.ModTime()}
+		case dir == "entries" && strings.HasSuffix(d.Name(), ".json"):
+			manifests = append(manifests, path)
+			other += info.Size()
+		default:
+			other += info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	for _, manifest := range manifests {
+		data, err := os.ReadFile(manifest)
+		if err != nil {
+			continue
+		}
+		var entry Entry
+		if json.Unmarshal(data, &entry) != nil || !validSHA256(entry.SHA256) {
+			continue
+		}
+		// entries/ and blobs/ are siblings under the type directory.
+		blobPath := filepath.Join(filepath.Dir(filepath.Dir(manifest)), "blobs", entry.SHA256+".tar.gz")
+		if blob, ok := blobs[blobPath]; ok {
+			blob.manifests = append(blob.manifests, manifest)
+		}
+	}
+	return blobs, other, nil
+}
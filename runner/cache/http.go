@@ -0,0 +1,82 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// httpTimeout bounds a single cache request, including archive transfers.
+const httpTimeout = 10 * time.Minute
+
+// httpObjects talks to a plain HTTP cache server: GET returns an object or
+// 404, PUT stores one. The server must replace objects atomically.
+type httpObjects struct {
+	base   *url.URL
+	client *http.Client
+}
+
+// NewHTTP returns a backend backed by an HTTP cache server. Credentials in
+// the URL are sent as basic auth.
+func NewHTTP(rawURL string) (*Store, error) {
+	base, err := url.Parse(strings.TrimRight(rawURL, "/"))
+	if err != nil {
+		return nil, err
+	}
+	return &Store{objects: httpObjects{base: base, client: &http.Client{Timeout: httpTimeout}}}, nil
+}
+
+func (o httpObjects) get(ctx context.Context, name string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, o.url(name), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		resp.Body.Close()
+		return nil, ErrNotFound
+	case resp.StatusCode != http.StatusOK:
+		resp.Body.Close()
+		return nil, fmt.Errorf("cache get %s: status %d", name, resp.StatusCode)
+	}
+	return resp.Body, nil
+}
+
+func (o httpObjects) put(ctx context.Context, name string, body io.ReadSeeker, size int64) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, o.url(name), body)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("cache put %s: status %d", name, resp.StatusCode)
+	}
+	return nil
+}
+
+// list is not part of the plain GET/PUT protocol, so restore keys only match
+// exact keys on HTTP backends.
+func (o httpObjects) list(ctx context.Context, prefix string) ([]objectInfo, error) {
+	return nil, errListUnsupported
+}
+
+func (o httpObjects) url(name string) string {
+	u := *o.base
+	u.Path = u.Path + "/" + name
+	u.RawPath = ""
+	return u.String()
+}
@@ -0,0 +1,41 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/izavyalov-dev/delta-ci/runner/artifacts"
+)
+
+// Open selects a backend by URL scheme. An empty URL uses localDir.
+//
+//	file:///var/cache/delta-ci (or a plain path)
+//	s3://bucket/prefix?region=&endpoint=&path_style=
+//	https://cache.example.com/delta-ci
+func Open(ctx context.Context, rawURL, localDir string) (*Store, error) {
+	if rawURL == "" {
+		return NewLocal(localDir)
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse cache url: %w", err)
+	}
+
+	switch u.Scheme {
+	case "":
+		return NewLocal(rawURL)
+	case "file":
+		return NewLocal(u.Path)
+	case "s3":
+		cfg, err := artifacts.S3ConfigFromURL(u)
+		if err != nil {
+			return nil, err
+		}
+		return NewS3(ctx, cfg)
+	case "http", "https":
+		return NewHTTP(rawURL)
+	default:
+		return nil, fmt.Errorf("unsupported cache scheme %q", u.Scheme)
+	}
+}
@@ -0,0 +1,87 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+
+	"github.com/izavyalov-dev/delta-ci/runner/artifacts"
+)
+
+// s3Objects keeps cache objects in an S3-compatible bucket. PutObject
+// replaces objects atomically, which gives publish its all-or-nothing semantics.
+type s3Objects struct {
+	client *s3.Client
+	bucket string
+	prefix string
+}
+
+// NewS3 returns a backend storing archives in an S3-compatible bucket.
+func NewS3(ctx context.Context, cfg artifacts.S3Config) (*Store, error) {
+	client, err := artifacts.NewS3Client(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &Store{objects: s3Objects{client: client, bucket: cfg.Bucket, prefix: cfg.Prefix}}, nil
+}
+
+func (o s3Objects) get(ctx context.Context, name string) (io.ReadCloser, error) {
+	key := o.key(name)
+	out, err := o.client.GetObject(ctx, &s3.GetObjectInput{Bucket: &o.bucket, Key: &key})
+	if err != nil {
+		var missing *types.NoSuchKey
+		if errors.As(err, &missing) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return out.Body, nil
+}
+
+func (o s3Objects) put(ctx context.Context, name string, body io.ReadSeeker, size int64) error {
+	key := o.key(name)
+	_, err := o.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:        &o.bucket,
+		Key:           &key,
+		Body:          body,
+		ContentLength: &size,
+	})
+	return err
+}
+
+func (o s3Objects) list(ctx context.Context, prefix string) ([]objectInfo, error) {
+	fullPrefix := o.key(prefix)
+	paginator := s3.NewListObjectsV2Paginator(o.client, &s3.ListObjectsV2Input{
+		Bucket: &o.bucket,
+		Prefix: &fullPrefix,
+	})
+	var objects []objectInfo
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, object := range page.Contents {
+			if object.Key == nil || object.LastModified == nil {
+				continue
+			}
+			objects = append(objects, objectInfo{
+				name:    strings.TrimPrefix(strings.TrimPrefix(*object.Key, o.prefix), "/"),
+				modTime: *object.LastModified,
+			})
+		}
+	}
+	return objects, nil
+}
+
+func (o s3Objects) key(name string) string {
+	if o.prefix == "" {
+		return name
+	}
+	return path.Join(o.prefix, name)
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/izavyalov-dev/delta-ci/protocol"
+	"github.com/izavyalov-dev/delta-ci/runner/cache"
+)
+
+// cacheUsage tracks a CacheSpec between restore and save.
+type cacheUsage struct {
+	spec protocol.CacheSpec
+	// targets maps archive root names to resolved paths in the workspace.
+	targets map[string]string
+	// exact is set when Key itself was restored; partial hits are saved
+	// under Key afterwards.
+	exact bool
+}
+
+// defaultCacheMaxBytes reads DELTA_CI_CACHE_MAX_BYTES, defaulting to 10 GiB.
+func defaultCacheMaxBytes() int64 {
+	if value := os.Getenv("DELTA_CI_CACHE_MAX_BYTES"); value != "" {
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return n
+		}
+	}
+	return 10 << 30
+}
+
+func defaultCacheDir() string {
+	if value := os.Getenv("DELTA_CI_CACHE_DIR"); value != "" {
+		return value
+	}
+	return filepath.Join(os.TempDir(), "delta-ci", "cache")
+}
+
+// restoreCaches looks up each spec in the backend and extracts hits into the
+// workspace. Cache failures are logged and treated as misses.
+func restoreCaches(ctx context.Context, backend cache.Backend, tmpDir, workdir string, specs []protocol.CacheSpec, logger *slog.Logger) ([]cacheUsage, []protocol.CacheEvent) {
+	if len(specs) == 0 || backend == nil {
+		return nil, nil
+	}
+
+	usages := make([]cacheUsage, 0, len(specs))
+	events := make([]protocol.CacheEvent, 0, len(specs))
+	for _, spec := range specs {
+		if spec.Key == "" || len(spec.Paths) == 0 {
+			continue
+		}
+
+		usage := cacheUsage{spec: spec, targets: make(map[string]string, len(spec.Paths))}
+		for _, path := range spec.Paths {
+			target, err := resolveCachePath(path, workdir)
+			if err != nil {
+				logger.Warn("cache path resolve failed", "event", "cache_warning", "path", path, "error", err)
+				continue
+			}
+			usage.targets[hashValue(path)] = target
+		}
+
+		event := protocol.CacheEvent{Type: spec.Type, Key: spec.Key, ReadOnly: spec.ReadOnly}
+		entry, match, err := lookupCache(ctx, backend, spec)
+		switch {
+		case err == nil:
+			if err := restoreCacheEntry(ctx, backend, entry, tmpDir, usage.targets); err != nil {
+				logger.Warn("cache restore failed", "event", "cache_warning", "type", spec.Type, "error", err)
+				break
+			}
+			usage.exact = match == protocol.CacheMatchExact
+			event.Hit = true
+			event.Match = match
+			event.MatchedKey = entry.Key
+			logger.Info("cache restored", "event", "cache_restored", "type", spec.Type, "match", match, "matched_key", entry.Key, "size_bytes", entry.Size)
+		case !errors.Is(err, cache.ErrNotFound):
+			logger.Warn("cache lookup failed", "event", "cache_warning", "type", spec.Type, "error", err)
+		}
+
+		events = append(events, event)
+		usages = append(usages, usage)
+	}
+	return usages, events
+}
+
+// lookupCache tries the exact key, then each restore-key prefix in order.
+func lookupCache(ctx context.Context, backend cache.Backend, spec protocol.CacheSpec) (cache.Entry, protocol.CacheMatch, error) {
+	entry, err := backend.Lookup(ctx, spec.Type, spec.Key)
+	if err == nil {
+		return entry, protocol.CacheMatchExact, nil
+	}
+	if !errors.Is(err, cache.ErrNotFound) {
+		return cache.Entry{}, "", err
+	}
+	for _, prefix := range spec.RestoreKeys {
+		if prefix == "" {
+			continue
+		}
+		entry, err := backend.LookupPrefix(ctx, spec.Type, prefix)
+		if err == nil {
+			return entry, protocol.CacheMatchPartial, nil
+		}
+		if !errors.Is(err, cache.ErrNotFound) {
+			return cache.Entry{}, "", err
+		}
+	}
+	return cache.Entry{}, "", cache.ErrNotFound
+}
+
+func restoreCacheEntry(ctx context.Context, backend cache.Backend, entry cache.Entry, tmpDir string, targets map[string]string) error {
+	archive, err := cache.Download(ctx, backend, entry, tmpDir)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(archive.Name())
+	defer archive.Close()
+	return cache.ExtractArchive(archive, targets)
+}
+
+// saveCaches publishes writable caches that missed or were restored from a
+// restore key. Keys are deterministic, so an exact hit already holds this
+// content.
+func saveCaches(ctx context.Context, backend cache.Backend, tmpDir string, usages []cacheUsage, logger *slog.Logger) {
+	for _, usage := range usages {
+		if usage.spec.ReadOnly || usage.exact {
+			continue
+		}
+		roots := make(map[string]string, len(usage.targets))
+		for name, target := range usage.targets {
+			if pathExists(target) {
+				roots[name] = target
+			}
+		}
+		if len(roots) == 0 {
+			continue
+		}
+		size, err := saveCacheEntry(ctx, backend, tmpDir, usage.spec, roots)
+		if err != nil {
+			logger.Warn("cache save failed", "event", "cache_warning", "type", usage.spec.Type, "error", err)
+			continue
+		}
+		logger.Info("cache saved", "event", "cache_saved", "type", usage.spec.Type, "size_bytes", size)
+	}
+}
+
+func saveCacheEntry(ctx context.Context, backend cache.Backend, tmpDir string, spec protocol.CacheSpec, roots map[string]string) (int64, error) {
+	if err := os.MkdirAll(tmpDir, 0o755); err != nil {
+		return 0, err
+	}
+	archive, err := os.CreateTemp(tmpDir, "save-*.tar.gz")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(archive.Name())
+	defer archive.Close()
+
+	hash := sha256.New()
+	if err := cache.WriteArchive(io.MultiWriter(archive, hash), roots); err != nil {
+		return 0, err
+	}
+	size, err := archive.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := archive.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	entry := cache.Entry{
+		Type:   spec.Type,
+		Key:    spec.Key,
+		SHA256: hex.EncodeToString(hash.Sum(nil)),
+		Size:   size,
+	}
+	return size, backend.Publish(ctx, entry, archive)
+}
+
+func resolveCachePath(path, workdir string) (string, error) {
+	if path == "" {
+		return "", errors.New("empty path")
+	}
+	if strings.HasPrefix(path, "~") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		path = filepath.Join(home, strings.TrimPrefix(path, "~"))
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(workdir, path)
+	}
+	return filepath.Clean(path), nil
+}
+
+func pathExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func hashValue(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
+
+// collectCache evicts least recently used cache entries beyond the size
+// budget and removes stale temp files and orphaned archives, which still
+// happens when eviction is disabled. The lock lets runners sharing a cache
+// directory take turns.
+func (r jobRunner) collectCache(logger *slog.Logger) {
+	if r.cacheRoot == "" {
+		return
+	}
+	stats, err := cache.GC(r.cacheRoot, r.cfg.cacheMaxBytes, lockFile)
+	if err != nil {
+		logger.Warn("cache gc failed", "event", "cache_gc_failed", "error", err)
+		return
+	}
+	r.metrics.ObserveCacheGC(stats.EvictedEntries, stats.EvictedBytes, stats.RemainingBytes)
+	logger.Info("cache gc", "event", "cache_gc",
+		"evicted_entries", stats.EvictedEntries,
+		"freed_bytes", stats.EvictedBytes,
+		"remaining_bytes", stats.RemainingBytes,
+		"max_bytes", r.cfg.cacheMaxBytes)
+}
@@ -0,0 +1,255 @@
+//go:build linux
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/izavyalov-dev/delta-ci/protocol"
+)
+
+const (
+	cgroupMount = "/sys/fs/cgroup"
+	// cpuPeriodMicros is the cpu.max period used to express fractional CPUs.
+	cpuPeriodMicros = 100000
+	// cgroupRemoveAttempts bounds the wait for killed processes to leave.
+	cgroupRemoveAttempts = 20
+	// runnerLeafCgroup holds the runner process itself when it has to vacate
+	// its own group so job groups can be created next to it.
+	runnerLeafCgroup = "delta-ci-runner"
+)
+
+// jobCgroup is a cgroup v2 child group holding every step of one job.
+type jobCgroup struct {
+	path string
+	dir  *os.File
+}
+
+// newJobCgroup creates a child group named name under parent (a path below
+// /sys/fs/cgroup, or the runner's own group when empty) and applies limits.
+func newJobCgroup(parent, name string, limits *protocol.ResourceLimits) (*jobCgroup, error) {
+	if _, err := os.Stat(filepath.Join(cgroupMount, "cgroup.controllers")); err != nil {
+		return nil, errors.New("cgroup v2 is not mounted at " + cgroupMount)
+	}
+	ownGroup := parent == ""
+	if ownGroup {
+		own, err := ownCgroup()
+		if err != nil {
+			return nil, err
+		}
+		parent = filepath.Join(cgroupMount, own)
+		if filepath.Base(own) == runnerLeafCgroup {
+			parent = filepath.Dir(parent)
+		}
+	}
+
+	// Controllers must be enabled on the parent for the child to get the
+	// interface files. A group holding processes cannot delegate them, so when
+	// using its own group the runner first moves itself into a leaf.
+	err := enableControllers(parent, "cpu", "memory", "pids")
+	if errors.Is(err, syscall.EBUSY) && ownGroup {
+		if err = moveSelfToLeaf(parent); err == nil {
+			err = enableControllers(parent, "cpu", "memory", "pids")
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("enable controllers on %s: %w", parent, err)
+	}
+
+	path := filepath.Join(parent, "delta-ci-"+cgroupName(name))
+	if err := os.Mkdir(path, 0o755); err != nil && !os.IsExist(err) {
+		return nil, err
+	}
+	group := &jobCgroup{path: path}
+	if err := group.setLimits(limits); err != nil {
+		group.remove()
+		return nil, err
+	}
+	dir, err := os.Open(path)
+	if err != nil {
+		group.remove()
+		return nil, err
+	}
+	group.dir = dir
+	return group, nil
+}
+
+func (c *jobCgroup) setLimits(limits *protocol.ResourceLimits) error {
+	if limits == nil {
+		return nil
+	}
+	if limits.CPUs > 0 {
+		quota := max(int64(limits.CPUs*cpuPeriodMicros), 1000)
+		if err := c.write("cpu.max", fmt.Sprintf("%d %d", quota, cpuPeriodMicros)); err != nil {
+			return err
+		}
+	}
+	if limits.MemoryBytes > 0 {
+		if err := c.write("memory.max", strconv.FormatInt(limits.MemoryBytes, 10)); err != nil {
+			return err
+		}
+		// Without this the limit only pushes the job into swap.
+		if err := c.write("memory.swap.max", "0"); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	if limits.Pids > 0 {
+		if err := c.write("pids.max", strconv.FormatInt(limits.Pids, 10)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// apply starts cmd directly inside the group, so every process it forks is
+// accounted from the first instruction.
+func (c *jobCgroup) apply(cmd *exec.Cmd) {
+	if c == nil || c.dir == nil {
+		return
+	}
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.UseCgroupFD = true
+	cmd.SysProcAttr.CgroupFD = int(c.dir.Fd())
+}
+
+// usage reads peak memory, CPU time and OOM kills for the group.
+func (c *jobCgroup) usage() (protocol.ResourceUsage, error) {
+	var usage protocol.ResourceUsage
+	stat, err := readKeyValues(filepath.Join(c.path, "cpu.stat"))
+	if err != nil {
+		return usage, err
+	}
+	usage.CPUSeconds = float64(stat["usage_usec"]) / 1e6
+
+	if peak, err := os.ReadFile(filepath.Join(c.path, "memory.peak")); err == nil {
+		usage.PeakMemoryBytes, _ = strconv.ParseInt(strings.TrimSpace(string(peak)), 10, 64)
+	}
+	events, err := readKeyValues(filepath.Join(c.path, "memory.events"))
+	if err != nil {
+		return usage, err
+	}
+	usage.OOMKilled = events["oom_kill"] > 0
+	return usage, nil
+}
+
+// remove kills anything left in the group and deletes it.
+func (c *jobCgroup) remove() error {
+	if c == nil {
+		return nil
+	}
+	if c.dir != nil {
+		c.dir.Close()
+	}
+	_ = c.write("cgroup.kill", "1")
+	var err error
+	for range cgroupRemoveAttempts {
+		if err = os.Remove(c.path); err == nil || os.IsNotExist(err) {
+			return nil
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return err
+}
+
+func (c *jobCgroup) write(file, value string) error {
+	return os.WriteFile(filepath.Join(c.path, file), []byte(value), 0o644)
+}
+
+func cgroupName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+}
+
+// ownCgroup returns the runner's cgroup v2 path from /proc/self/cgroup.
+func ownCgroup() (string, error) {
+	data, err := os.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return "", err
+	}
+	return parseCgroupV2Path(string(data))
+}
+
+func parseCgroupV2Path(content string) (string, error) {
+	for _, line := range strings.Split(content, "\n") {
+		if path, ok := strings.CutPrefix(line, "0::"); ok {
+			return path, nil
+		}
+	}
+	return "", errors.New("no cgroup v2 entry in /proc/self/cgroup")
+}
+
+func moveSelfToLeaf(parent string) error {
+	leaf := filepath.Join(parent, runnerLeafCgroup)
+	if err := os.Mkdir(leaf, 0o755); err != nil && !os.IsExist(err) {
+		return err
+	}
+	return os.WriteFile(filepath.Join(leaf, "cgroup.procs"), []byte(strconv.Itoa(os.Getpid())), 0o644)
+}
+
+func enableControllers(parent string, controllers ...string) error {
+	available, err := os.ReadFile(filepath.Join(parent, "cgroup.controllers"))
+	if err != nil {
+		return err
+	}
+	enabled := strings.Fields(string(available))
+	var request []string
+	for _, controller := range controllers {
+		for _, name := range enabled {
+			if name == controller {
+				request = append(request, "+"+controller)
+			}
+		}
+	}
+	if len(request) == 0 {
+		return nil
+	}
+	return os.WriteFile(filepath.Join(parent, "cgroup.subtree_control"), []byte(strings.Join(request, " ")), 0o644)
+}
+
+// readKeyValues parses flat-keyed cgroup files such as cpu.stat.
+func readKeyValues(path string) (map[string]int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	values := make(map[string]int64)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), " ")
+		if !ok {
+			continue
+		}
+		if n, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64); err == nil {
+			values[key] = n
+		}
+	}
+	return values, scanner.Err()
+}
+
+// maxRSSBytes returns the largest resident set of a waited process and its
+// reaped descendants. Linux reports ru_maxrss in KiB.
+func maxRSSBytes(state *os.ProcessState) int64 {
+	if rusage, ok := state.SysUsage().(*syscall.Rusage); ok {
+		return rusage.Maxrss * 1024
+	}
+	return 0
+}
@@ -0,0 +1,44 @@
+//go:build linux
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseCgroupV2Path(t *testing.T) {
+	path, err := parseCgroupV2Path("4:memory:/legacy\n0::/system.slice/delta-ci.service\n")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if path != "/system.slice/delta-ci.service" {
+		t.Fatalf("unexpected path %q", path)
+	}
+	if _, err := parseCgroupV2Path("4:memory:/legacy\n"); err == nil {
+		t.Fatal("expected error without a cgroup v2 entry")
+	}
+}
+
+func TestJobCgroupUsage(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"cpu.stat":      "usage_usec 2500000\nuser_usec 2000000\nsystem_usec 500000\n",
+		"memory.peak":   "268435456\n",
+		"memory.events": "low 0\nhigh 0\nmax 3\noom 1\noom_kill 1\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	usage, err := (&jobCgroup{path: dir}).usage()
+	if err != nil {
+		t.Fatalf("usage: %v", err)
+	}
+	if usage.CPUSeconds != 2.5 || usage.PeakMemoryBytes != 268435456 || !usage.OOMKilled {
+		t.Fatalf("unexpected usage %+v", usage)
+	}
+}
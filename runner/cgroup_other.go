@@ -0,0 +1,33 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+
+	"github.com/izavyalov-dev/delta-ci/protocol"
+)
+
+// jobCgroup is unavailable outside Linux; jobs run without limits and report
+// CPU time from rusage only.
+type jobCgroup struct{}
+
+func newJobCgroup(parent, name string, limits *protocol.ResourceLimits) (*jobCgroup, error) {
+	return nil, errors.New("cgroups are only supported on linux")
+}
+
+func (c *jobCgroup) apply(cmd *exec.Cmd) {}
+
+func (c *jobCgroup) usage() (protocol.ResourceUsage, error) {
+	return protocol.ResourceUsage{}, errors.New("cgroups are only supported on linux")
+}
+
+func (c *jobCgroup) remove() error {
+	return nil
+}
+
+func maxRSSBytes(state *os.ProcessState) int64 {
+	return 0
+}
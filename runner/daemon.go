@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/izavyalov-dev/delta-ci/internal/observability"
+	"github.com/izavyalov-dev/delta-ci/protocol"
+)
+
+// daemonErrorBackoff is the pause after a failed lease request.
+const daemonErrorBackoff = 5 * time.Second
+
+// runDaemon polls the orchestrator for leases and executes them in-process.
+// It only speaks the HTTP runner protocol, so data-plane hosts need no
+// database access. SIGINT/SIGTERM stop polling; a running job is finished and
+// reported before the daemon exits.
+func runDaemon(args []string) error {
+	flags := flag.NewFlagSet("daemon", flag.ExitOnError)
+	cfg := registerJobFlags(flags)
+	logDir := flags.String("log-dir", filepath.Join(os.TempDir(), "delta-ci", "logs"), "Directory for per-lease job logs")
+	wait := flags.Duration("wait", 20*time.Second, "Long-poll wait per lease request")
+	metricsAddr := flags.String("metrics-addr", os.Getenv("DELTA_CI_RUNNER_METRICS_ADDR"), "Address to serve Prometheus metrics on (disabled when empty)")
+	rawLabels := flags.String("labels", os.Getenv("DELTA_CI_RUNNER_LABELS"), "Comma-separated labels (key=value or bare) advertised in addition to os/arch")
+	runnerSecret := flags.String("runner-secret", os.Getenv("DELTA_CI_RUNNER_SECRET"), "Shared secret the orchestrator requires to request leases")
+	_ = flags.Parse(args)
+
+	if cfg.runnerID == "" {
+		return errors.New("runner-id is required")
+	}
+	if *runnerSecret == "" {
+		return errors.New("runner-secret or DELTA_CI_RUNNER_SECRET is required")
+	}
+	if err := os.MkdirAll(*logDir, 0o755); err != nil {
+		return err
+	}
+
+	logger := observability.NewLogger("runner")
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	runner, err := newJobRunner(ctx, *cfg, logger)
+	if err != nil {
+		return err
+	}
+	runner.client = runner.client.WithRunnerSecret(*runnerSecret)
+	if *metricsAddr != "" {
+		serveMetrics(ctx, *metricsAddr, logger)
+	}
+
+	labels := protocol.NormalizeLabels(append(protocol.HostLabels(), protocol.ParseLabels(*rawLabels)...))
+	logger.Info("runner daemon started", "event", "runner_started", "runner_id", cfg.runnerID, "orchestrator_url", cfg.orchestrator, "labels", labels)
+	for ctx.Err() == nil {
+		// Reports left over from failed deliveries or a previous process go
+		// out before new work is taken.
+		runner.flushOutbox(ctx)
+
+		lease, granted, err := runner.client.RequestLease(ctx, protocol.RequestLease{
+			Type:        "RequestLease",
+			RunnerID:    cfg.runnerID,
+			Labels:      labels,
+			Version:     observability.BuildVersion(),
+			WaitSeconds: int(wait.Seconds()),
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			logger.Warn("request lease failed", "event", "lease_request_failed", "error", err)
+			sleepContext(ctx, daemonErrorBackoff)
+			continue
+		}
+		if !granted {
+			continue
+		}
+
+		// The job runs on a fresh context so a shutdown signal lets it finish.
+		logPath := filepath.Join(*logDir, lease.LeaseID+".log")
+		if err := runner.run(context.Background(), lease, logPath); err != nil {
+			logger.Error("run lease", "event", "runner_error", "lease_id", lease.LeaseID, "error", err)
+		}
+	}
+	logger.Info("runner daemon stopped", "event", "runner_stopped")
+	return nil
+}
+
+// serveMetrics exposes /metrics until ctx is done.
+func serveMetrics(ctx context.Context, addr string, logger *slog.Logger) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", observability.MetricsHandler())
+	server := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Warn("metrics server failed", "event", "metrics_error", "error", err)
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		_ = server.Close()
+	}()
+}
+
+func sleepContext(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/izavyalov-dev/delta-ci/protocol"
+)
+
+// hostEnvKeys are passed through from the runner's environment so common
+// toolchains stay usable without leaking the rest of the host environment.
+var hostEnvKeys = []string{"PATH", "HOME", "TMPDIR", "LANG", "TZ"}
+
+// buildJobEnv assembles the environment for job steps. Job-provided values
+// override host passthrough values; the standard DELTA_CI_* variables always
+// win so jobs cannot spoof their own identity.
+func buildJobEnv(lease protocol.LeaseGranted, inheritHost bool) []string {
+	env := make(map[string]string)
+	if inheritHost {
+		for _, entry := range os.Environ() {
+			key, value, ok := strings.Cut(entry, "=")
+			if ok && key != "" {
+				env[key] = value
+			}
+		}
+	} else {
+		for _, key := range hostEnvKeys {
+			if value, ok := os.LookupEnv(key); ok {
+				env[key] = value
+			}
+		}
+	}
+
+	env["CI"] = "true"
+	for key, value := range lease.JobSpec.Env {
+		if key == "" || strings.Contains(key, "=") {
+			continue
+		}
+		env[key] = value
+	}
+
+	attempt := lease.AttemptNumber
+	if attempt <= 0 {
+		attempt = 1
+	}
+	env["DELTA_CI"] = "true"
+	env["DELTA_CI_RUN_ID"] = lease.RunID
+	env["DELTA_CI_JOB_ID"] = lease.JobID
+	env["DELTA_CI_JOB_NAME"] = lease.JobSpec.Name
+	env["DELTA_CI_COMMIT_SHA"] = lease.CommitSHA
+	env["DELTA_CI_REF"] = lease.Ref
+	env["DELTA_CI_ATTEMPT"] = strconv.Itoa(attempt)
+
+	keys := make([]string, 0, len(env))
+	for key := range env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	result := make([]string, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, key+"="+env[key])
+	}
+	return result
+}
+
+// resolveJobWorkdir resolves a JobSpec workdir relative to the checkout root
+// and rejects paths that escape it, including via symlinks.
+func resolveJobWorkdir(checkoutRoot, workdir string) (string, error) {
+	root, err := filepath.Abs(checkoutRoot)
+	if err != nil {
+		return "", err
+	}
+	root, err = filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", fmt.Errorf("resolve checkout root: %w", err)
+	}
+
+	if workdir == "" {
+		workdir = "."
+	}
+	if filepath.IsAbs(workdir) {
+		return "", fmt.Errorf("workdir %q must be relative to the checkout root", workdir)
+	}
+
+	target := filepath.Join(root, workdir)
+	if !withinRoot(root, target) {
+		return "", fmt.Errorf("workdir %q escapes the checkout root", workdir)
+	}
+
+	resolved, err := filepath.EvalSymlinks(target)
+	if err != nil {
+		return "", fmt.Errorf("resolve workdir %q: %w", workdir, err)
+	}
+	if !withinRoot(root, resolved) {
+		return "", fmt.Errorf("workdir %q escapes the checkout root", workdir)
+	}
+
+	info, err := os.Stat(resolved)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return "", errors.New("workdir is not a directory")
+	}
+	return resolved, nil
+}
+
+func withinRoot(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
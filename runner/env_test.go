@@ -0,0 +1,69 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/izavyalov-dev/delta-ci/protocol"
+)
+
+func TestResolveJobWorkdirRejectsEscapes(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "services", "api"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	outside := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(root, "link")); err != nil {
+		t.Fatalf("symlink: %v", err)
+	}
+
+	resolved, err := resolveJobWorkdir(root, "services/api")
+	if err != nil {
+		t.Fatalf("resolve workdir: %v", err)
+	}
+	if filepath.Base(resolved) != "api" {
+		t.Fatalf("unexpected workdir %s", resolved)
+	}
+
+	for _, workdir := range []string{"..", "../other", "/tmp", "link"} {
+		if _, err := resolveJobWorkdir(root, workdir); err == nil {
+			t.Fatalf("expected %q to be rejected", workdir)
+		}
+	}
+}
+
+func TestBuildJobEnvDoesNotLeakHost(t *testing.T) {
+	t.Setenv("DELTA_CI_TEST_SECRET", "leak")
+
+	env := buildJobEnv(protocol.LeaseGranted{
+		RunID:         "run-1",
+		JobID:         "job-1",
+		AttemptNumber: 2,
+		CommitSHA:     "abc123",
+		Ref:           "refs/heads/main",
+		JobSpec: protocol.JobSpec{
+			Env: map[string]string{
+				"FOO":             "bar",
+				"DELTA_CI_RUN_ID": "spoofed",
+			},
+		},
+	}, false)
+
+	for _, want := range []string{
+		"FOO=bar",
+		"DELTA_CI_RUN_ID=run-1",
+		"DELTA_CI_JOB_ID=job-1",
+		"DELTA_CI_COMMIT_SHA=abc123",
+		"DELTA_CI_REF=refs/heads/main",
+		"DELTA_CI_ATTEMPT=2",
+	} {
+		if !slices.Contains(env, want) {
+			t.Fatalf("expected %q in env %v", want, env)
+		}
+	}
+	if slices.Contains(env, "DELTA_CI_TEST_SECRET=leak") {
+		t.Fatal("host environment leaked into job env")
+	}
+}
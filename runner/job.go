@@ -0,0 +1,438 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/izavyalov-dev/delta-ci/internal/observability"
+	"github.com/izavyalov-dev/delta-ci/internal/redact"
+	"github.com/izavyalov-dev/delta-ci/protocol"
+	"github.com/izavyalov-dev/delta-ci/runner/artifacts"
+	"github.com/izavyalov-dev/delta-ci/runner/cache"
+	"github.com/izavyalov-dev/delta-ci/runner/transport"
+)
+
+// jobConfig holds the execution settings shared by one-shot and daemon mode.
+type jobConfig struct {
+	orchestrator  string
+	runnerID      string
+	workdir       string
+	artifactsURL  string
+	cacheDir      string
+	cacheURL      string
+	cacheMaxBytes int64
+	inheritEnv    bool
+	streamLogs    bool
+	workspaceRoot string
+	mirrorDir     string
+	cgroupParent  string
+	outboxDir     string
+}
+
+func registerJobFlags(flags *flag.FlagSet) *jobConfig {
+	cfg := &jobConfig{}
+	flags.StringVar(&cfg.orchestrator, "orchestrator", "http://localhost:8080", "Orchestrator base URL")
+	flags.StringVar(&cfg.runnerID, "runner-id", "", "Runner identity (required)")
+	flags.StringVar(&cfg.workdir, "workdir", ".", "Checkout root for leases without repo_url; JobSpec.Workdir is resolved relative to it")
+	flags.StringVar(&cfg.artifactsURL, "artifacts", os.Getenv("DELTA_CI_ARTIFACTS_URL"), "Artifact store URL (file:///path or s3://bucket/prefix?region=&endpoint=&path_style=)")
+	flags.StringVar(&cfg.cacheDir, "cache-dir", defaultCacheDir(), "Local cache directory; holds archives unless -cache-url is set")
+	flags.StringVar(&cfg.cacheURL, "cache-url", os.Getenv("DELTA_CI_CACHE_URL"), "Shared cache URL (file:///path, s3://bucket/prefix or https://host/path)")
+	flags.Int64Var(&cfg.cacheMaxBytes, "cache-max-bytes", defaultCacheMaxBytes(), "Size budget for a local cache; least recently used entries are evicted beyond it (0 disables eviction)")
+	flags.BoolVar(&cfg.inheritEnv, "inherit-env", false, "Pass the full host environment to job steps")
+	flags.BoolVar(&cfg.streamLogs, "stream-logs", true, "Stream job log chunks to the orchestrator while the job runs")
+	flags.StringVar(&cfg.workspaceRoot, "workspace-root", defaultWorkspaceRoot(), "Directory for per-attempt checkouts")
+	flags.StringVar(&cfg.mirrorDir, "mirror-dir", defaultMirrorDir(), "Directory for shared bare repository mirrors")
+	flags.StringVar(&cfg.outboxDir, "outbox-dir", defaultOutboxDir(), "Directory persisting Complete/CancelAck reports until the orchestrator accepts them")
+	flags.StringVar(&cfg.cgroupParent, "cgroup-parent", os.Getenv("DELTA_CI_CGROUP_PARENT"), "Delegated cgroup v2 directory for per-job groups (default: the runner's own cgroup)")
+	return cfg
+}
+
+// jobRunner executes leases against the orchestrator's runner protocol.
+type jobRunner struct {
+	cfg    jobConfig
+	client *transport.HTTPClient
+	store  artifacts.ArtifactStore
+	caches cache.Backend
+	outbox outbox
+	// cacheRoot is set when caches live on a local filesystem we can GC.
+	cacheRoot string
+	metrics   *observability.RunnerMetrics
+	logger    *slog.Logger
+	// interrupt cancels the running job like an orchestrator cancel would.
+	interrupt <-chan os.Signal
+}
+
+func newJobRunner(ctx context.Context, cfg jobConfig, logger *slog.Logger) (jobRunner, error) {
+	runner := jobRunner{
+		cfg:     cfg,
+		client:  transport.NewHTTPClient(cfg.orchestrator),
+		outbox:  outbox{dir: cfg.outboxDir},
+		metrics: observability.NewRunnerMetrics(nil),
+		logger:  logger,
+	}
+	if cfg.artifactsURL != "" {
+		store, err := artifacts.Open(ctx, cfg.artifactsURL)
+		if err != nil {
+			return jobRunner{}, fmt.Errorf("open artifact store: %w", err)
+		}
+		runner.store = store
+	}
+	caches, err := cache.Open(ctx, cfg.cacheURL, cfg.cacheDir)
+	if err != nil {
+		return jobRunner{}, fmt.Errorf("open cache: %w", err)
+	}
+	runner.caches = caches
+	if root, ok := caches.LocalRoot(); ok {
+		runner.cacheRoot = root
+	}
+	runner.collectCache(logger)
+	return runner, nil
+}
+
+// run executes a single lease end to end: ack, heartbeats, checkout, steps and
+// the final Complete or CancelAck. Job failures are reported to the
+// orchestrator; the returned error only covers protocol failures.
+func (r jobRunner) run(ctx context.Context, lease protocol.LeaseGranted, logPath string) error {
+	logger := r.logger
+	logger = observability.WithRun(logger, lease.RunID)
+	logger = observability.WithJob(logger, lease.JobID)
+	logger = observability.WithLease(logger, lease.LeaseID)
+
+	logWriter, err := os.Create(filepath.Clean(logPath))
+	if err != nil {
+		return fmt.Errorf("open log file: %w", err)
+	}
+	defer logWriter.Close()
+	// Every write goes through jobLog so step offsets and progress match the file.
+	jobLog := newCountingWriter(logWriter)
+	progress := newJobProgress(jobLog)
+	progress.setStatus("starting")
+
+	client := r.client.WithLeaseToken(lease.LeaseToken)
+	runnerID := r.cfg.runnerID
+	mask := redact.ForJobSpec(lease.JobSpec)
+	runCtx, cancelRun := context.WithCancel(ctx)
+	defer cancelRun()
+
+	ack := protocol.AckLease{
+		Type:       "AckLease",
+		JobID:      lease.JobID,
+		LeaseID:    lease.LeaseID,
+		RunnerID:   runnerID,
+		AcceptedAt: time.Now().UTC(),
+	}
+	if err := client.AckLease(ctx, ack); err != nil {
+		return fmt.Errorf("ack lease: %w", err)
+	}
+	logger.Info("lease acknowledged", "event", "lease_acknowledged")
+	// Runs after the final report so eviction never delays it.
+	defer r.collectCache(logger)
+
+	heartbeatInterval := time.Duration(lease.HeartbeatIntervalSeconds) * time.Second
+	if heartbeatInterval <= 0 {
+		heartbeatInterval = 20 * time.Second
+	}
+
+	// leaseExpiry tracks when the orchestrator will expire the lease; queued
+	// final reports are useless after it.
+	var leaseExpiry atomic.Int64
+	leaseTTL := time.Duration(lease.LeaseTTLSeconds) * time.Second
+	leaseExpiry.Store(time.Now().Add(leaseTTL).UnixNano())
+
+	var killGrace atomic.Int64
+	killGrace.Store(int64(defaultKillGrace))
+
+	var cancelOnce sync.Once
+	cancelSignal := make(chan struct{})
+	signalCancel := func() {
+		cancelOnce.Do(func() {
+			close(cancelSignal)
+			cancelRun()
+		})
+	}
+
+	go func() {
+		select {
+		case <-r.interrupt:
+			logger.Warn("interrupted; canceling job", "event", "job_interrupted")
+			signalCancel()
+		case <-runCtx.Done():
+		}
+	}()
+
+	sendHeartbeat := func(ts time.Time) error {
+		ack, err := client.Heartbeat(ctx, protocol.Heartbeat{
+			Type:     "Heartbeat",
+			LeaseID:  lease.LeaseID,
+			RunnerID: runnerID,
+			TS:       ts,
+			Progress: progress.snapshot(),
+		})
+		if err != nil {
+			return err
+		}
+		client.SetLeaseToken(ack.LeaseToken)
+		if ack.ExtendLease && ack.NewLeaseTTLSeconds > 0 {
+			leaseExpiry.Store(ts.Add(time.Duration(ack.NewLeaseTTLSeconds) * time.Second).UnixNano())
+		} else {
+			leaseExpiry.Store(ts.Add(leaseTTL).UnixNano())
+		}
+		if ack.CancelRequested {
+			if ack.CancelDeadlineSeconds > 0 {
+				killGrace.Store(int64(time.Duration(ack.CancelDeadlineSeconds) * time.Second))
+			}
+			signalCancel()
+		}
+		return nil
+	}
+
+	start := time.Now().UTC()
+	if err := sendHeartbeat(start); err != nil {
+		return fmt.Errorf("first heartbeat: %w", err)
+	}
+	logger.Debug("heartbeat sent", "event", "lease_heartbeat")
+
+	var streamer *logStreamer
+	if r.cfg.streamLogs {
+		streamer = startLogStreamer(client, lease, runnerID, logPath, logger)
+	}
+
+	hbDone := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(heartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-hbDone:
+				return
+			case <-ticker.C:
+				if err := sendHeartbeat(time.Now().UTC()); err != nil {
+					logger.Error("heartbeat failed", "event", "runner_error", "error", err)
+					signalCancel()
+					return
+				}
+			}
+		}
+	}()
+
+	execCtx := runCtx
+	if lease.MaxRuntimeSeconds > 0 {
+		var cancelExec context.CancelFunc
+		execCtx, cancelExec = context.WithTimeout(runCtx, time.Duration(lease.MaxRuntimeSeconds)*time.Second)
+		defer cancelExec()
+	}
+
+	checkoutRoot := r.cfg.workdir
+	workspaces := workspaceManager{root: r.cfg.workspaceRoot, mirrorDir: r.cfg.mirrorDir}
+	var workspaceDir string
+	var setupErr error
+	if lease.RepoURL != "" {
+		progress.setStatus("checking out repository")
+		workspaceDir, setupErr = workspaces.prepare(execCtx, lease)
+		checkoutRoot = workspaceDir
+		if setupErr == nil {
+			logger.Info("workspace ready", "event", "workspace_ready", "commit_sha", lease.CommitSHA, "path", workspaceDir)
+		}
+	}
+	var runWorkdir string
+	if setupErr == nil {
+		runWorkdir, setupErr = resolveJobWorkdir(checkoutRoot, lease.JobSpec.Workdir)
+	}
+
+	var cacheUsages []cacheUsage
+	var cacheEvents []protocol.CacheEvent
+	var stepResults []protocol.StepResult
+	var declaredArtifacts []protocol.ArtifactRef
+	var testResults []protocol.TestResult
+	var resources *protocol.ResourceUsage
+	var runnerErr error
+	if setupErr != nil {
+		logger.Error("job setup failed", "event", "runner_error", "workdir", lease.JobSpec.Workdir, "error", mask.String(setupErr.Error()))
+		fmt.Fprintf(jobLog, "delta-ci: job setup failed: %s\n", mask.String(setupErr.Error()))
+	} else {
+		if len(lease.JobSpec.Caches) > 0 {
+			progress.setStatus("restoring caches")
+		}
+		cacheUsages, cacheEvents = restoreCaches(ctx, r.caches, r.cacheTempDir(), runWorkdir, lease.JobSpec.Caches, logger)
+		cgroup, err := newJobCgroup(r.cfg.cgroupParent, lease.LeaseID, lease.JobSpec.Resources)
+		if err != nil {
+			if lease.JobSpec.Resources != nil {
+				logger.Warn("resource limits not enforced", "event", "runner_warning", "error", err)
+				fmt.Fprintf(jobLog, "delta-ci: resource limits not enforced: %s\n", err)
+			} else {
+				logger.Debug("cgroup unavailable", "event", "runner_warning", "error", err)
+			}
+		}
+		executor := stepExecutor{
+			dir:  runWorkdir,
+			env:  buildJobEnv(lease, r.cfg.inheritEnv),
+			log:  jobLog,
+			mask: mask,
+			grace: func() time.Duration {
+				return time.Duration(killGrace.Load())
+			},
+			cgroup:   cgroup,
+			usage:    &processUsage{},
+			progress: progress,
+		}
+		stepResults, runnerErr = executor.run(execCtx, lease.JobSpec.Steps)
+		resources = jobResourceUsage(cgroup, executor.usage, logger)
+		if err := cgroup.remove(); err != nil {
+			logger.Warn("remove cgroup", "event", "runner_warning", "error", err)
+		}
+		// Artifacts are collected after passing, failing and timed-out runs,
+		// but not after cancellation.
+		if runCtx.Err() == nil {
+			progress.setStatus("collecting artifacts")
+			declaredArtifacts = r.collectArtifacts(ctx, lease, runWorkdir, logger)
+			testResults = collectTestResults(logPath, runWorkdir, lease.JobSpec.Artifacts, mask, logger)
+		}
+	}
+	close(hbDone)
+	finished := time.Now().UTC()
+
+	canceled := false
+	select {
+	case <-cancelSignal:
+		canceled = true
+	default:
+	}
+
+	timedOut := !canceled && errors.Is(execCtx.Err(), context.DeadlineExceeded)
+
+	status := protocol.CompleteStatusSucceeded
+	exit := 0
+	summary := "succeeded"
+	switch {
+	case timedOut:
+		status = protocol.CompleteStatusTimedOut
+		exit = timeoutExitCode
+		summary = fmt.Sprintf("timed out after max runtime of %ds", lease.MaxRuntimeSeconds)
+		logger.Warn("job timed out", "event", "job_timed_out", "max_runtime_seconds", lease.MaxRuntimeSeconds)
+	case setupErr != nil && !canceled:
+		status = protocol.CompleteStatusFailed
+		exit = 1
+		summary = "job setup failed: " + setupErr.Error()
+	case runnerErr != nil && !canceled:
+		status = protocol.CompleteStatusFailed
+		exit = exitCode(runnerErr)
+		summary = runnerErr.Error()
+	}
+	if resources != nil && resources.OOMKilled && status == protocol.CompleteStatusFailed {
+		summary += " (out of memory: killed by the OOM killer)"
+	}
+	summary = mask.String(summary)
+	if status == protocol.CompleteStatusSucceeded {
+		saveCaches(ctx, r.caches, r.cacheTempDir(), cacheUsages, logger)
+	}
+	if err := workspaces.cleanup(workspaceDir); err != nil {
+		logger.Warn("remove workspace", "event", "runner_warning", "path", workspaceDir, "error", err)
+	}
+
+	if err := logWriter.Sync(); err != nil {
+		logger.Warn("sync log file", "event", "runner_warning", "error", err)
+	}
+	if streamer != nil {
+		streamer.stop()
+	}
+
+	var artifactsList []protocol.ArtifactRef
+	if r.store != nil {
+		ref, err := r.uploadLog(ctx, lease, logPath)
+		if err != nil {
+			logger.Warn("upload log", "event", "artifact_upload_failed", "error", err)
+		} else {
+			artifactsList = append(artifactsList, ref)
+			logger.Info("log uploaded", "event", "artifact_uploaded", "uri", ref.URI)
+		}
+	}
+	artifactsList = append(artifactsList, declaredArtifacts...)
+
+	if canceled {
+		if summary == "succeeded" {
+			summary = "canceled"
+		}
+		cancelAck := protocol.CancelAck{
+			Type:        "CancelAck",
+			LeaseID:     lease.LeaseID,
+			RunnerID:    runnerID,
+			FinalStatus: protocol.CancelFinalStatusCanceled,
+			TS:          finished,
+			Summary:     summary,
+			Artifacts:   artifactsList,
+		}
+		if err := r.deliver(ctx, outboxEntry{
+			LeaseID:   lease.LeaseID,
+			RunnerID:  runnerID,
+			ExpiresAt: time.Unix(0, leaseExpiry.Load()),
+			Token:     client.LeaseToken(),
+			CancelAck: &cancelAck,
+		}, logger); err != nil {
+			return fmt.Errorf("cancel ack: %w", err)
+		}
+		logger.Info("job canceled", "event", "job_canceled")
+		return nil
+	}
+
+	complete := protocol.Complete{
+		Type:       "Complete",
+		LeaseID:    lease.LeaseID,
+		RunnerID:   runnerID,
+		Status:     status,
+		ExitCode:   exit,
+		FinishedAt: finished,
+		Summary:    summary,
+		Artifacts:  artifactsList,
+		Caches:     cacheEvents,
+		Steps:      stepResults,
+		Tests:      testResults,
+		Resources:  resources,
+	}
+	if err := r.deliver(ctx, outboxEntry{
+		LeaseID:   lease.LeaseID,
+		RunnerID:  runnerID,
+		ExpiresAt: time.Unix(0, leaseExpiry.Load()),
+		Token:     client.LeaseToken(),
+		Complete:  &complete,
+	}, logger); err != nil {
+		return fmt.Errorf("complete: %w", err)
+	}
+	logger.Info("job completed", "event", "job_completed", "status", status, "exit_code", exit)
+	return nil
+}
+
+// cacheTempDir holds archives while they are downloaded or built.
+func (r jobRunner) cacheTempDir() string {
+	return filepath.Join(r.cfg.cacheDir, "tmp")
+}
+
+// jobResourceUsage prefers cgroup accounting, which also sees processes that
+// outlived their step, and falls back to rusage of the step processes.
+func jobResourceUsage(cgroup *jobCgroup, fallback *processUsage, logger *slog.Logger) *protocol.ResourceUsage {
+	if cgroup != nil {
+		usage, err := cgroup.usage()
+		if err == nil {
+			if usage.PeakMemoryBytes == 0 {
+				usage.PeakMemoryBytes = fallback.peakRSS
+			}
+			return &usage
+		}
+		logger.Warn("read cgroup usage", "event", "runner_warning", "error", err)
+	}
+	return &protocol.ResourceUsage{
+		PeakMemoryBytes: fallback.peakRSS,
+		CPUSeconds:      fallback.cpu.Seconds(),
+	}
+}
+
+func (r jobRunner) uploadLog(ctx context.Context, lease protocol.LeaseGranted, logPath string) (protocol.ArtifactRef, error) {
+	return r.uploadFile(ctx, lease, logPath, "log.txt", "log", 0)
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/izavyalov-dev/delta-ci/protocol"
+	"github.com/izavyalov-dev/delta-ci/runner/artifacts"
+)
+
+// defaultArtifactMaxBytes caps a declared artifact file when the spec sets no limit.
+const defaultArtifactMaxBytes = 100 << 20
+
+// collectArtifacts uploads files matching the job's declared artifact globs.
+// Failures are logged and skipped so they never change the job outcome.
+func (r jobRunner) collectArtifacts(ctx context.Context, lease protocol.LeaseGranted, workdir string, logger *slog.Logger) []protocol.ArtifactRef {
+	specs := lease.JobSpec.Artifacts
+	if len(specs) == 0 {
+		return nil
+	}
+	if r.store == nil {
+		logger.Warn("artifacts declared without an artifact store", "event", "artifact_upload_failed")
+		return nil
+	}
+
+	var refs []protocol.ArtifactRef
+	uploaded := make(map[string]bool)
+	for _, spec := range specs {
+		if spec.Type == "" || spec.Path == "" {
+			continue
+		}
+		matches, err := matchArtifactFiles(workdir, spec.Path)
+		if err != nil {
+			logger.Warn("artifact glob failed", "event", "artifact_upload_failed", "type", spec.Type, "path", spec.Path, "error", err)
+			continue
+		}
+		if len(matches) == 0 {
+			logger.Info("no files matched artifact glob", "event", "artifact_no_match", "type", spec.Type, "path", spec.Path)
+			continue
+		}
+
+		limit := spec.MaxBytes
+		if limit <= 0 {
+			limit = defaultArtifactMaxBytes
+		}
+		for _, rel := range matches {
+			name := path.Join(spec.Type, rel)
+			if uploaded[name] {
+				continue
+			}
+			ref, err := r.uploadFile(ctx, lease, filepath.Join(workdir, filepath.FromSlash(rel)), name, spec.Type, limit)
+			if err != nil {
+				logger.Warn("upload artifact", "event", "artifact_upload_failed", "type", spec.Type, "file", rel, "error", err)
+				continue
+			}
+			uploaded[name] = true
+			refs = append(refs, ref)
+			logger.Info("artifact uploaded", "event", "artifact_uploaded", "type", spec.Type, "file", rel, "uri", ref.URI, "size_bytes", ref.SizeBytes)
+		}
+	}
+	return refs
+}
+
+// uploadFile stores a single file and returns its reference with size and sha256.
+func (r jobRunner) uploadFile(ctx context.Context, lease protocol.LeaseGranted, filePath, name, artifactType string, limit int64) (protocol.ArtifactRef, error) {
+	file, err := os.Open(filepath.Clean(filePath))
+	if err != nil {
+		return protocol.ArtifactRef{}, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return protocol.ArtifactRef{}, err
+	}
+	if limit > 0 && info.Size() > limit {
+		return protocol.ArtifactRef{}, fmt.Errorf("size %d exceeds limit of %d bytes", info.Size(), limit)
+	}
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return protocol.ArtifactRef{}, err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return protocol.ArtifactRef{}, err
+	}
+
+	uri, err := r.store.Put(ctx, artifacts.Object{
+		RunID:   lease.RunID,
+		JobID:   lease.JobID,
+		Attempt: lease.AttemptNumber,
+		Name:    name,
+	}, io.LimitReader(file, size), "")
+	if err != nil {
+		return protocol.ArtifactRef{}, err
+	}
+	return protocol.ArtifactRef{
+		Type:      artifactType,
+		URI:       uri,
+		SizeBytes: size,
+		SHA256:    hex.EncodeToString(hash.Sum(nil)),
+	}, nil
+}
+
+// matchArtifactFiles returns regular files under root whose slash-separated
+// relative path matches pattern. Symlinks are not followed, so artifacts
+// cannot reach outside the workspace.
+func matchArtifactFiles(root, pattern string) ([]string, error) {
+	pattern = strings.TrimPrefix(path.Clean(filepath.ToSlash(pattern)), "./")
+	if path.IsAbs(pattern) || pattern == ".." || strings.HasPrefix(pattern, "../") {
+		return nil, errors.New("artifact path must be relative to the workdir")
+	}
+	if _, err := path.Match(strings.ReplaceAll(pattern, "**", "*"), ""); err != nil {
+		return nil, err
+	}
+
+	var matches []string
+	err := filepath.WalkDir(root, func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, filePath)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if matchGlob(strings.Split(pattern, "/"), strings.Split(rel, "/")) {
+			matches = append(matches, rel)
+		}
+		return nil
+	})
+	return matches, err
+}
+
+// matchGlob matches path segments against pattern segments, where a "**"
+// segment matches zero or more path segments.
+func matchGlob(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchGlob(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
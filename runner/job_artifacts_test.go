@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/izavyalov-dev/delta-ci/protocol"
+	"github.com/izavyalov-dev/delta-ci/runner/artifacts"
+)
+
+func TestMatchGlob(t *testing.T) {
+	cases := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"**/TestResults/*.trx", "TestResults/a.trx", true},
+		{"**/TestResults/*.trx", "src/app/TestResults/a.trx", true},
+		{"**/TestResults/*.trx", "src/TestResults/nested/a.trx", false},
+		{"reports/*.xml", "reports/junit.xml", true},
+		{"reports/*.xml", "reports/sub/junit.xml", false},
+		{"reports/**", "reports/sub/junit.xml", true},
+		{"*.txt", "notes.md", false},
+	}
+	for _, tc := range cases {
+		got := matchGlob(strings.Split(tc.pattern, "/"), strings.Split(tc.name, "/"))
+		if got != tc.want {
+			t.Errorf("matchGlob(%q, %q) = %v, want %v", tc.pattern, tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestCollectArtifacts(t *testing.T) {
+	workdir := t.TempDir()
+	writeFile(t, filepath.Join(workdir, "reports", "unit.xml"), "<testsuite/>")
+	writeFile(t, filepath.Join(workdir, "reports", "nested", "it.xml"), "<testsuite name=\"it\"/>")
+	writeFile(t, filepath.Join(workdir, "reports", "big.xml"), strings.Repeat("x", 64))
+	writeFile(t, filepath.Join(workdir, "main.go"), "package main")
+
+	storeRoot := t.TempDir()
+	store, err := artifacts.NewFileStore(storeRoot)
+	if err != nil {
+		t.Fatalf("store: %v", err)
+	}
+	runner := jobRunner{store: store}
+	lease := protocol.LeaseGranted{
+		RunID:         "run1",
+		JobID:         "job1",
+		AttemptNumber: 2,
+		JobSpec: protocol.JobSpec{
+			Artifacts: []protocol.ArtifactSpec{
+				{Type: "junit", Path: "reports/**/*.xml", MaxBytes: 32},
+				{Type: "bin", Path: "../outside"},
+			},
+		},
+	}
+
+	refs := runner.collectArtifacts(context.Background(), lease, workdir, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if len(refs) != 2 {
+		t.Fatalf("expected 2 artifacts, got %+v", refs)
+	}
+
+	sum := sha256.Sum256([]byte("<testsuite/>"))
+	var found bool
+	for _, ref := range refs {
+		if ref.Type != "junit" {
+			t.Fatalf("unexpected artifact type %q", ref.Type)
+		}
+		if strings.HasSuffix(ref.URI, "/runs/run1/jobs/job1/attempts/2/junit/reports/unit.xml") {
+			found = true
+			if ref.SizeBytes != int64(len("<testsuite/>")) || ref.SHA256 != hex.EncodeToString(sum[:]) {
+				t.Fatalf("unexpected size or checksum: %+v", ref)
+			}
+		}
+	}
+	if !found {
+		t.Fatalf("expected unit.xml artifact, got %+v", refs)
+	}
+	if _, err := os.Stat(filepath.Join(storeRoot, "runs", "run1", "jobs", "job1", "attempts", "2", "junit", "reports", "big.xml")); !os.IsNotExist(err) {
+		t.Fatal("expected oversized artifact to be skipped")
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
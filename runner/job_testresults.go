@@ -0,0 +1,78 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/izavyalov-dev/delta-ci/internal/redact"
+	"github.com/izavyalov-dev/delta-ci/protocol"
+	"github.com/izavyalov-dev/delta-ci/runner/testresults"
+)
+
+// maxReportedTests bounds the test results attached to Complete; failures
+// are kept first so they are never dropped in favour of passing tests.
+const maxReportedTests = 5000
+
+// collectTestResults parses `go test -json` events from the job log and any
+// declared junit artifacts in the workdir.
+func collectTestResults(logPath, workdir string, specs []protocol.ArtifactSpec, mask *redact.Redactor, logger *slog.Logger) []protocol.TestResult {
+	var results []protocol.TestResult
+
+	if file, err := os.Open(filepath.Clean(logPath)); err == nil {
+		parsed, err := testresults.ParseGoTestJSON(file)
+		file.Close()
+		if err != nil {
+			logger.Warn("parse go test output", "event", "test_results_failed", "error", err)
+		}
+		results = append(results, parsed...)
+	}
+
+	for _, spec := range specs {
+		if !strings.EqualFold(spec.Type, "junit") || spec.Path == "" {
+			continue
+		}
+		matches, err := matchArtifactFiles(workdir, spec.Path)
+		if err != nil {
+			continue
+		}
+		for _, rel := range matches {
+			file, err := os.Open(filepath.Join(workdir, filepath.FromSlash(rel)))
+			if err != nil {
+				logger.Warn("open junit report", "event", "test_results_failed", "file", rel, "error", err)
+				continue
+			}
+			parsed, err := testresults.ParseJUnit(file)
+			file.Close()
+			if err != nil {
+				logger.Warn("parse junit report", "event", "test_results_failed", "file", rel, "error", err)
+				continue
+			}
+			results = append(results, parsed...)
+		}
+	}
+
+	for i := range results {
+		results[i].Output = mask.String(results[i].Output)
+	}
+	return limitTestResults(results, maxReportedTests)
+}
+
+func limitTestResults(results []protocol.TestResult, limit int) []protocol.TestResult {
+	if len(results) <= limit {
+		return results
+	}
+	kept := make([]protocol.TestResult, 0, limit)
+	for _, result := range results {
+		if result.Status == protocol.TestStatusFailed && len(kept) < limit {
+			kept = append(kept, result)
+		}
+	}
+	for _, result := range results {
+		if result.Status != protocol.TestStatusFailed && len(kept) < limit {
+			kept = append(kept, result)
+		}
+	}
+	return kept
+}
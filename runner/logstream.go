@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/izavyalov-dev/delta-ci/protocol"
+)
+
+const (
+	// logStreamInterval is how often new log output is shipped.
+	logStreamInterval = time.Second
+	// logStreamChunkBytes bounds a single LogChunk payload.
+	logStreamChunkBytes = 64 << 10
+	// logStreamFinalAttempts bounds retries while flushing at job end.
+	logStreamFinalAttempts = 3
+)
+
+type logChunkSender interface {
+	SendLogChunk(ctx context.Context, chunk protocol.LogChunk) error
+}
+
+// logStreamer tails the job log file and ships new output to the orchestrator.
+// A chunk is resent with the same sequence number until it is accepted, so
+// retries never duplicate or reorder output.
+type logStreamer struct {
+	sender   logChunkSender
+	leaseID  string
+	runnerID string
+	path     string
+	logger   *slog.Logger
+
+	offset  int64
+	seq     int64
+	pending *protocol.LogChunk
+
+	stopCh chan struct{}
+	done   chan struct{}
+}
+
+func startLogStreamer(sender logChunkSender, lease protocol.LeaseGranted, runnerID, path string, logger *slog.Logger) *logStreamer {
+	s := &logStreamer{
+		sender:   sender,
+		leaseID:  lease.LeaseID,
+		runnerID: runnerID,
+		path:     path,
+		logger:   logger,
+		stopCh:   make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go s.loop()
+	return s
+}
+
+// stop ships the remaining output and waits for the streamer to exit.
+func (s *logStreamer) stop() {
+	close(s.stopCh)
+	<-s.done
+}
+
+func (s *logStreamer) loop() {
+	defer close(s.done)
+	ticker := time.NewTicker(logStreamInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.flush(); err != nil {
+				s.logger.Debug("log stream deferred", "event", "log_stream_retry", "error", err)
+			}
+		case <-s.stopCh:
+			for attempt := 0; attempt < logStreamFinalAttempts; attempt++ {
+				err := s.flush()
+				if err == nil {
+					return
+				}
+				s.logger.Warn("log stream flush failed", "event", "log_stream_failed", "error", err)
+			}
+			return
+		}
+	}
+}
+
+// flush sends chunks until the file has been shipped up to its current end.
+func (s *logStreamer) flush() error {
+	for {
+		if s.pending == nil {
+			data, err := s.read()
+			if err != nil {
+				return err
+			}
+			if len(data) == 0 {
+				return nil
+			}
+			s.pending = &protocol.LogChunk{
+				Type:     "LogChunk",
+				LeaseID:  s.leaseID,
+				RunnerID: s.runnerID,
+				Seq:      s.seq,
+				Offset:   s.offset,
+				Data:     data,
+			}
+		}
+
+		s.pending.TS = time.Now().UTC()
+		if err := s.sender.SendLogChunk(context.Background(), *s.pending); err != nil {
+			return err
+		}
+		s.offset += int64(len(s.pending.Data))
+		s.seq++
+		s.pending = nil
+	}
+}
+
+func (s *logStreamer) read() ([]byte, error) {
+	f, err := os.Open(s.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	buf := make([]byte, logStreamChunkBytes)
+	n, err := f.ReadAt(buf, s.offset)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	return buf[:n], nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/izavyalov-dev/delta-ci/internal/observability"
+	"github.com/izavyalov-dev/delta-ci/protocol"
+)
+
+type flakySender struct {
+	failNext bool
+	chunks   []protocol.LogChunk
+}
+
+func (f *flakySender) SendLogChunk(ctx context.Context, chunk protocol.LogChunk) error {
+	f.chunks = append(f.chunks, chunk)
+	if f.failNext {
+		f.failNext = false
+		return errors.New("transient")
+	}
+	return nil
+}
+
+func TestLogStreamerResendsChunkWithSameSeq(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "job.log")
+	if err := os.WriteFile(path, []byte("hello "), 0o644); err != nil {
+		t.Fatalf("write log: %v", err)
+	}
+
+	sender := &flakySender{failNext: true}
+	streamer := &logStreamer{sender: sender, leaseID: "lease-1", runnerID: "runner-1", path: path, logger: observability.NewLogger("test")}
+
+	if err := streamer.flush(); err == nil {
+		t.Fatal("expected first flush to fail")
+	}
+	// Output written after a failed send must not change the retried chunk.
+	if err := os.WriteFile(path, []byte("hello world"), 0o644); err != nil {
+		t.Fatalf("write log: %v", err)
+	}
+	if err := streamer.flush(); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+
+	if len(sender.chunks) != 3 {
+		t.Fatalf("expected 3 sends, got %d", len(sender.chunks))
+	}
+	first, retry, next := sender.chunks[0], sender.chunks[1], sender.chunks[2]
+	if retry.Seq != first.Seq || !bytes.Equal(retry.Data, first.Data) {
+		t.Fatalf("retry changed chunk: %+v vs %+v", retry, first)
+	}
+	if next.Seq != 1 || next.Offset != 6 || string(next.Data) != "world" {
+		t.Fatalf("unexpected follow-up chunk %+v", next)
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/izavyalov-dev/delta-ci/protocol"
+	"github.com/izavyalov-dev/delta-ci/runner/transport"
+)
+
+// errReportQueued means a final report could not be delivered yet and waits in
+// the outbox.
+var errReportQueued = errors.New("final report queued for resend")
+
+// outboxRetryInterval paces resends while draining the outbox.
+const outboxRetryInterval = 5 * time.Second
+
+func defaultOutboxDir() string {
+	if value := os.Getenv("DELTA_CI_OUTBOX_DIR"); value != "" {
+		return value
+	}
+	return filepath.Join(os.TempDir(), "delta-ci", "outbox")
+}
+
+// outboxEntry is a persisted Complete or CancelAck with the lease token to
+// send it with. It is resent until the orchestrator accepts it, rejects it as
+// stale, or the lease expires.
+type outboxEntry struct {
+	LeaseID   string              `json:"lease_id"`
+	RunnerID  string              `json:"runner_id"`
+	ExpiresAt time.Time           `json:"expires_at"`
+	Token     string              `json:"token,omitempty"`
+	Complete  *protocol.Complete  `json:"complete,omitempty"`
+	CancelAck *protocol.CancelAck `json:"cancel_ack,omitempty"`
+}
+
+// outbox stores one file per lease, so a report survives a runner restart.
+type outbox struct {
+	dir string
+}
+
+func (o outbox) put(entry outboxEntry) error {
+	if err := os.MkdirAll(o.dir, 0o700); err != nil {
+		return err
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(o.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), o.path(entry.LeaseID)); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+func (o outbox) get(leaseID string) (outboxEntry, error) {
+	data, err := os.ReadFile(o.path(leaseID))
+	if err != nil {
+		return outboxEntry{}, err
+	}
+	var entry outboxEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return outboxEntry{}, err
+	}
+	return entry, nil
+}
+
+func (o outbox) remove(leaseID string) {
+	_ = os.Remove(o.path(leaseID))
+}
+
+// pending lists stored entries; unreadable files are skipped.
+func (o outbox) pending() ([]outboxEntry, error) {
+	files, err := os.ReadDir(o.dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var entries []outboxEntry
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(o.dir, file.Name()))
+		if err != nil {
+			continue
+		}
+		var entry outboxEntry
+		if json.Unmarshal(data, &entry) != nil || entry.LeaseID == "" {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func (o outbox) path(leaseID string) string {
+	return filepath.Join(o.dir, url.PathEscape(leaseID)+".json")
+}
+
+// deliver persists a final report and sends it. On failure the report stays
+// in the outbox and errReportQueued is returned, unless the lease is stale.
+func (r jobRunner) deliver(ctx context.Context, entry outboxEntry, logger *slog.Logger) error {
+	if err := r.outbox.put(entry); err != nil {
+		logger.Warn("persist final report", "event", "outbox_error", "error", err)
+	}
+	err := r.send(ctx, entry)
+	if err == nil || errors.Is(err, transport.ErrStaleLease) {
+		r.outbox.remove(entry.LeaseID)
+		return err
+	}
+	return fmt.Errorf("%w: %w", errReportQueued, err)
+}
+
+func (r jobRunner) send(ctx context.Context, entry outboxEntry) error {
+	client := r.client.WithLeaseToken(entry.Token)
+	if entry.CancelAck != nil {
+		return client.CancelAck(ctx, *entry.CancelAck)
+	}
+	if entry.Complete != nil {
+		return client.Complete(ctx, *entry.Complete)
+	}
+	return errors.New("empty outbox entry")
+}
+
+// flushOutbox resends this runner's pending reports and returns how many are
+// still waiting. Reports for expired or stale leases are dropped.
+func (r jobRunner) flushOutbox(ctx context.Context) int {
+	entries, err := r.outbox.pending()
+	if err != nil {
+		r.logger.Warn("read outbox", "event", "outbox_error", "error", err)
+		return 0
+	}
+	remaining := 0
+	for _, entry := range entries {
+		if entry.RunnerID != r.cfg.runnerID {
+			continue
+		}
+		logger := r.logger.With("lease_id", entry.LeaseID)
+		if time.Now().After(entry.ExpiresAt) {
+			logger.Warn("lease expired before final report was delivered", "event", "outbox_expired")
+			r.outbox.remove(entry.LeaseID)
+			continue
+		}
+		err := r.send(ctx, entry)
+		switch {
+		case err == nil:
+			logger.Info("final report delivered", "event", "outbox_delivered")
+			r.outbox.remove(entry.LeaseID)
+		case errors.Is(err, transport.ErrStaleLease):
+			logger.Warn("final report rejected for stale lease", "event", "outbox_stale")
+			r.outbox.remove(entry.LeaseID)
+		default:
+			logger.Warn("resend final report", "event", "outbox_retry", "error", err)
+			remaining++
+		}
+	}
+	return remaining
+}
+
+// redeliver resends one lease's queued report until it is accepted. It fails
+// when the lease expires, the report is rejected as stale, or ctx is done.
+func (r jobRunner) redeliver(ctx context.Context, leaseID string) error {
+	for {
+		entry, err := r.outbox.get(leaseID)
+		if err != nil {
+			return err
+		}
+		if time.Now().After(entry.ExpiresAt) {
+			r.outbox.remove(leaseID)
+			return errors.New("lease expired before final report was delivered")
+		}
+		err = r.send(ctx, entry)
+		if err == nil || errors.Is(err, transport.ErrStaleLease) {
+			r.outbox.remove(leaseID)
+			return err
+		}
+		r.logger.Warn("resend final report", "event", "outbox_retry", "lease_id", leaseID, "error", err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(outboxRetryInterval):
+		}
+	}
+}
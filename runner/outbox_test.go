@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/izavyalov-dev/delta-ci/internal/observability"
+	"github.com/izavyalov-dev/delta-ci/protocol"
+	"github.com/izavyalov-dev/delta-ci/runner/transport"
+)
+
+func TestFlushOutboxResendsLiveReports(t *testing.T) {
+	var sent []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sent = append(sent, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	box := outbox{dir: t.TempDir()}
+	runner := jobRunner{
+		cfg:    jobConfig{runnerID: "runner-1"},
+		client: transport.NewHTTPClient(srv.URL),
+		outbox: box,
+		logger: observability.NewLogger("test"),
+	}
+	future := time.Now().Add(time.Hour)
+	entries := []outboxEntry{
+		{LeaseID: "live", RunnerID: "runner-1", ExpiresAt: future, Complete: &protocol.Complete{Type: "Complete", LeaseID: "live"}},
+		{LeaseID: "expired", RunnerID: "runner-1", ExpiresAt: time.Now().Add(-time.Minute), Complete: &protocol.Complete{Type: "Complete", LeaseID: "expired"}},
+		{LeaseID: "other", RunnerID: "runner-2", ExpiresAt: future, CancelAck: &protocol.CancelAck{Type: "CancelAck", LeaseID: "other"}},
+	}
+	for _, entry := range entries {
+		if err := box.put(entry); err != nil {
+			t.Fatalf("put: %v", err)
+		}
+	}
+
+	if remaining := runner.flushOutbox(context.Background()); remaining != 0 {
+		t.Fatalf("expected nothing left to resend, got %d", remaining)
+	}
+	if len(sent) != 1 || sent[0] != "/api/v1/internal/complete" {
+		t.Fatalf("expected only the live report to be sent, got %v", sent)
+	}
+	for leaseID, wantKept := range map[string]bool{"live": false, "expired": false, "other": true} {
+		_, err := os.Stat(box.path(leaseID))
+		if kept := err == nil; kept != wantKept {
+			t.Fatalf("%s: expected kept=%v, got %v", leaseID, wantKept, err)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/izavyalov-dev/delta-ci/protocol"
+)
+
+// maxProgressText bounds the step name and status line sent in heartbeats.
+const maxProgressText = 200
+
+// jobProgress is the live job state reported with each heartbeat.
+type jobProgress struct {
+	mu        sync.Mutex
+	started   time.Time
+	log       *countingWriter
+	stepIndex int
+	stepCount int
+	stepName  string
+	status    string
+}
+
+func newJobProgress(log *countingWriter) *jobProgress {
+	return &jobProgress{started: time.Now(), log: log, stepIndex: -1}
+}
+
+// setStatus replaces the free-form status line.
+func (p *jobProgress) setStatus(status string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.status = status
+}
+
+// startStep records that step index of count is running.
+func (p *jobProgress) startStep(index, count int, name string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stepIndex = index
+	p.stepCount = count
+	p.stepName = name
+	p.status = "running step"
+}
+
+func (p *jobProgress) snapshot() *protocol.JobProgress {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return &protocol.JobProgress{
+		StepIndex:      p.stepIndex,
+		StepCount:      p.stepCount,
+		StepName:       truncateText(p.stepName, maxProgressText),
+		ElapsedSeconds: int64(time.Since(p.started).Seconds()),
+		LogBytes:       p.log.Count(),
+		Status:         truncateText(p.status, maxProgressText),
+	}
+}
+
+func truncateText(value string, limit int) string {
+	if len(value) <= limit {
+		return value
+	}
+	cut := limit
+	// Avoid splitting a UTF-8 sequence.
+	for cut > 0 && value[cut]&0xC0 == 0x80 {
+		cut--
+	}
+	return value[:cut] + "..."
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"sync/atomic"
+	"time"
+
+	"github.com/izavyalov-dev/delta-ci/internal/redact"
+	"github.com/izavyalov-dev/delta-ci/protocol"
+)
+
+// countingWriter tracks the number of bytes written to the job log so step
+// output can be located by offset.
+type countingWriter struct {
+	w io.Writer
+	n atomic.Int64
+}
+
+func newCountingWriter(w io.Writer) *countingWriter {
+	return &countingWriter{w: w}
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n.Add(int64(n))
+	return n, err
+}
+
+// Count returns the number of bytes written so far.
+func (c *countingWriter) Count() int64 {
+	return c.n.Load()
+}
+
+// defaultKillGrace is how long a step gets between SIGTERM and SIGKILL when
+// the orchestrator has not provided a cancel deadline.
+const defaultKillGrace = 10 * time.Second
+
+// pipeWaitDelay bounds how long a finished step waits for its output pipes.
+const pipeWaitDelay = 5 * time.Second
+
+// timeoutExitCode mirrors the coreutils timeout(1) convention.
+const timeoutExitCode = 124
+
+// stepExecutor runs JobSpec steps as shell commands in their own process groups.
+type stepExecutor struct {
+	dir string
+	env []string
+	log *countingWriter
+	// mask redacts secrets from step output before it reaches the log.
+	mask *redact.Redactor
+	// grace returns the delay between SIGTERM and SIGKILL once the context ends.
+	grace func() time.Duration
+	// cgroup, when set, contains every step's process group.
+	cgroup *jobCgroup
+	// usage accumulates CPU time and peak RSS of finished steps.
+	usage *processUsage
+	// progress, when set, is told which step is running.
+	progress *jobProgress
+}
+
+// processUsage is the rusage-based fallback for resource reporting when the
+// job does not run in a cgroup.
+type processUsage struct {
+	cpu     time.Duration
+	peakRSS int64
+}
+
+func (u *processUsage) add(state *os.ProcessState) {
+	if u == nil || state == nil {
+		return
+	}
+	u.cpu += state.UserTime() + state.SystemTime()
+	u.peakRSS = max(u.peakRSS, maxRSSBytes(state))
+}
+
+// run executes steps in order and stops at the first failing step.
+// Every step gets a result; steps that never ran are reported as SKIPPED.
+// The returned error is the failing step's error, or nil when all steps succeeded.
+func (e stepExecutor) run(ctx context.Context, steps []string) ([]protocol.StepResult, error) {
+	if len(steps) == 0 {
+		steps = []string{"echo \"no steps provided\""}
+	}
+
+	results := make([]protocol.StepResult, len(steps))
+	for i, step := range steps {
+		results[i] = protocol.StepResult{
+			Index:     i,
+			Command:   step,
+			Status:    protocol.StepStatusSkipped,
+			LogOffset: e.log.Count(),
+		}
+	}
+
+	var stepErr error
+	for i, step := range steps {
+		if ctx.Err() != nil {
+			break
+		}
+
+		result := &results[i]
+		e.progress.startStep(i, len(steps), step)
+		started := time.Now().UTC()
+		result.StartedAt = &started
+		result.LogOffset = e.log.Count()
+
+		err := e.runStep(ctx, step)
+
+		finished := time.Now().UTC()
+		result.FinishedAt = &finished
+		result.LogBytes = e.log.Count() - result.LogOffset
+
+		switch {
+		case err == nil:
+			result.Status = protocol.StepStatusSucceeded
+			continue
+		case errors.Is(ctx.Err(), context.DeadlineExceeded):
+			result.Status = protocol.StepStatusTimedOut
+			result.ExitCode = timeoutExitCode
+		case ctx.Err() != nil:
+			result.Status = protocol.StepStatusCanceled
+			result.ExitCode = exitCode(err)
+		default:
+			result.Status = protocol.StepStatusFailed
+			result.ExitCode = exitCode(err)
+		}
+		stepErr = err
+		break
+	}
+
+	end := e.log.Count()
+	for i := range results {
+		if results[i].Status == protocol.StepStatusSkipped {
+			results[i].LogOffset = end
+		}
+	}
+	return results, stepErr
+}
+
+// runStep runs a single step. When ctx ends the step's process group receives
+// SIGTERM, followed by SIGKILL once the grace period elapses.
+func (e stepExecutor) runStep(ctx context.Context, step string) error {
+	cmd := exec.Command("sh", "-c", step)
+	cmd.Dir = e.dir
+	cmd.Env = e.env
+	out := redact.NewWriter(e.log, e.mask)
+	cmd.Stdout = out
+	cmd.Stderr = out
+	// Background processes that outlive the step may hold the log pipe open;
+	// stop waiting for them shortly after the step itself exits.
+	cmd.WaitDelay = pipeWaitDelay
+	configureProcessGroup(cmd)
+	e.cgroup.apply(cmd)
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		select {
+		case <-done:
+			return
+		case <-ctx.Done():
+		}
+		_ = terminateProcessGroup(cmd)
+		timer := time.NewTimer(e.killGrace())
+		defer timer.Stop()
+		select {
+		case <-done:
+		case <-timer.C:
+			_ = killProcessGroup(cmd)
+		}
+	}()
+
+	err := cmd.Wait()
+	close(done)
+	<-stopped
+	e.usage.add(cmd.ProcessState)
+	if flushErr := out.Flush(); flushErr != nil && err == nil {
+		err = flushErr
+	}
+	if errors.Is(err, exec.ErrWaitDelay) && cmd.ProcessState != nil && cmd.ProcessState.Success() {
+		return nil
+	}
+	return err
+}
+
+func (e stepExecutor) killGrace() time.Duration {
+	if e.grace == nil {
+		return defaultKillGrace
+	}
+	if grace := e.grace(); grace > 0 {
+		return grace
+	}
+	return defaultKillGrace
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/izavyalov-dev/delta-ci/internal/redact"
+	"github.com/izavyalov-dev/delta-ci/protocol"
+)
+
+func TestRunStepsStopsAtFirstFailure(t *testing.T) {
+	var buf bytes.Buffer
+	log := newCountingWriter(&buf)
+
+	executor := stepExecutor{dir: t.TempDir(), log: log}
+	results, err := executor.run(context.Background(), []string{
+		"printf one",
+		"printf two; exit 3",
+		"printf three",
+	})
+	if err == nil {
+		t.Fatal("expected failing step error")
+	}
+	if len(results) != 3 {
+		t.Fatalf("expected 3 step results, got %d", len(results))
+	}
+
+	wantStatus := []protocol.StepStatus{
+		protocol.StepStatusSucceeded,
+		protocol.StepStatusFailed,
+		protocol.StepStatusSkipped,
+	}
+	for i, want := range wantStatus {
+		if results[i].Status != want {
+			t.Fatalf("step %d: expected %s, got %s", i, want, results[i].Status)
+		}
+	}
+	if results[1].ExitCode != 3 {
+		t.Fatalf("expected exit code 3, got %d", results[1].ExitCode)
+	}
+	if results[2].StartedAt != nil {
+		t.Fatal("expected skipped step to have no start time")
+	}
+
+	out := buf.String()
+	if out != "onetwo" {
+		t.Fatalf("unexpected log output %q", out)
+	}
+	second := out[results[1].LogOffset : results[1].LogOffset+results[1].LogBytes]
+	if second != "two" {
+		t.Fatalf("expected second step log %q, got %q", "two", second)
+	}
+}
+
+func TestRunStepsMasksSecrets(t *testing.T) {
+	var buf bytes.Buffer
+	executor := stepExecutor{
+		dir:  t.TempDir(),
+		env:  []string{"TOKEN=tok-123456"},
+		log:  newCountingWriter(&buf),
+		mask: redact.New([]string{"tok-123456"}),
+	}
+	if _, err := executor.run(context.Background(), []string{`printf 'token=%s' "$TOKEN"`}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if got := buf.String(); got != "token=***" {
+		t.Fatalf("expected masked output, got %q", got)
+	}
+}
+
+func TestRunStepsTimeoutKillsProcessGroup(t *testing.T) {
+	var buf bytes.Buffer
+	executor := stepExecutor{
+		dir: t.TempDir(),
+		log: newCountingWriter(&buf),
+		grace: func() time.Duration {
+			return 200 * time.Millisecond
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+
+	started := time.Now()
+	// The trap keeps the shell alive on SIGTERM, so only SIGKILL to the whole
+	// group (including the backgrounded sleep) lets the step finish.
+	results, err := executor.run(ctx, []string{"trap '' TERM; sleep 30 & wait"})
+	if err == nil {
+		t.Fatal("expected timed out step error")
+	}
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Fatalf("step was not killed promptly (%s)", elapsed)
+	}
+	if results[0].Status != protocol.StepStatusTimedOut {
+		t.Fatalf("expected TIMED_OUT, got %s", results[0].Status)
+	}
+	if results[0].ExitCode != timeoutExitCode {
+		t.Fatalf("expected exit code %d, got %d", timeoutExitCode, results[0].ExitCode)
+	}
+}
+
+func TestRunStepsRecordsProcessUsage(t *testing.T) {
+	var buf bytes.Buffer
+	usage := &processUsage{}
+	executor := stepExecutor{dir: t.TempDir(), log: newCountingWriter(&buf), usage: usage}
+	if _, err := executor.run(context.Background(), []string{"i=0; while [ $i -lt 20000 ]; do i=$((i+1)); done"}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if usage.cpu <= 0 {
+		t.Fatalf("expected cpu time to be recorded, got %s", usage.cpu)
+	}
+}
+
+func TestRunStepsReportsProgress(t *testing.T) {
+	var buf bytes.Buffer
+	log := newCountingWriter(&buf)
+	progress := newJobProgress(log)
+	executor := stepExecutor{dir: t.TempDir(), log: log, progress: progress}
+	if _, err := executor.run(context.Background(), []string{"printf one", "printf two"}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	got := progress.snapshot()
+	if got.StepIndex != 1 || got.StepCount != 2 || got.StepName != "printf two" {
+		t.Fatalf("unexpected step progress %+v", got)
+	}
+	if got.LogBytes != int64(len("onetwo")) {
+		t.Fatalf("expected %d log bytes, got %d", len("onetwo"), got.LogBytes)
+	}
+}
@@ -0,0 +1,126 @@
+// Package testresults parses test reports into per-test results.
+package testresults
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/izavyalov-dev/delta-ci/protocol"
+)
+
+// MaxOutputBytes bounds the output excerpt kept for a failed test.
+const MaxOutputBytes = 4 << 10
+
+// goTestEvent mirrors the events emitted by `go test -json` (test2json).
+type goTestEvent struct {
+	Time    time.Time
+	Action  string
+	Package string
+	Test    string
+	Elapsed float64
+	Output  string
+}
+
+type goTestKey struct {
+	pkg  string
+	test string
+}
+
+// ParseGoTestJSON scans r for `go test -json` events and returns one result
+// per finished test. Lines that are not test2json events are ignored, so the
+// combined job log can be passed directly.
+func ParseGoTestJSON(r io.Reader) ([]protocol.TestResult, error) {
+	reader := bufio.NewReader(r)
+	outputs := make(map[goTestKey]*strings.Builder)
+	failedTests := make(map[string]bool)
+	var results []protocol.TestResult
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if event, ok := decodeGoTestEvent(line); ok {
+			key := goTestKey{pkg: event.Package, test: event.Test}
+			switch event.Action {
+			case "output":
+				buf := outputs[key]
+				if buf == nil {
+					buf = &strings.Builder{}
+					outputs[key] = buf
+				}
+				buf.WriteString(event.Output)
+			case "pass", "fail", "skip":
+				status := goTestStatus(event.Action)
+				output := ""
+				if buf := outputs[key]; buf != nil {
+					if status == protocol.TestStatusFailed {
+						output = Excerpt(buf.String())
+					}
+					delete(outputs, key)
+				}
+				if event.Test == "" {
+					// A failing package without failing tests is a build
+					// error, panic or TestMain failure; report it on its own.
+					if status != protocol.TestStatusFailed || failedTests[event.Package] {
+						continue
+					}
+				} else if status == protocol.TestStatusFailed {
+					failedTests[event.Package] = true
+				}
+				results = append(results, protocol.TestResult{
+					Package:    event.Package,
+					Name:       event.Test,
+					Status:     status,
+					DurationMs: int64(event.Elapsed * 1000),
+					Output:     output,
+				})
+			}
+		}
+		if err == io.EOF {
+			return results, nil
+		}
+		if err != nil {
+			return results, err
+		}
+	}
+}
+
+func decodeGoTestEvent(line []byte) (goTestEvent, bool) {
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 || line[0] != '{' {
+		return goTestEvent{}, false
+	}
+	var event goTestEvent
+	if err := json.Unmarshal(line, &event); err != nil {
+		return goTestEvent{}, false
+	}
+	if event.Action == "" || event.Package == "" {
+		return goTestEvent{}, false
+	}
+	return event, true
+}
+
+func goTestStatus(action string) protocol.TestStatus {
+	switch action {
+	case "pass":
+		return protocol.TestStatusPassed
+	case "skip":
+		return protocol.TestStatusSkipped
+	default:
+		return protocol.TestStatusFailed
+	}
+}
+
+// Excerpt keeps the tail of a test's output, where failures are reported.
+func Excerpt(output string) string {
+	if len(output) <= MaxOutputBytes {
+		return output
+	}
+	cut := len(output) - MaxOutputBytes
+	for cut < len(output) && output[cut]&0xC0 == 0x80 {
+		cut++
+	}
+	return "..." + output[cut:]
+}
@@ -0,0 +1,100 @@
+package testresults
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/izavyalov-dev/delta-ci/protocol"
+)
+
+type junitSuite struct {
+	Name   string       `xml:"name,attr"`
+	Suites []junitSuite `xml:"testsuite"`
+	Cases  []junitCase  `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure"`
+	Error     *junitMessage `xml:"error"`
+	Skipped   *junitMessage `xml:"skipped"`
+	SystemOut string        `xml:"system-out"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// ParseJUnit reads a JUnit XML report rooted at <testsuites> or <testsuite>.
+func ParseJUnit(r io.Reader) ([]protocol.TestResult, error) {
+	var root struct {
+		XMLName xml.Name
+		junitSuite
+	}
+	if err := xml.NewDecoder(r).Decode(&root); err != nil {
+		return nil, err
+	}
+	switch root.XMLName.Local {
+	case "testsuites", "testsuite":
+	default:
+		return nil, errors.New("not a junit report: root element " + root.XMLName.Local)
+	}
+
+	var results []protocol.TestResult
+	collectJUnitSuite(root.junitSuite, &results)
+	return results, nil
+}
+
+func collectJUnitSuite(suite junitSuite, results *[]protocol.TestResult) {
+	for _, tc := range suite.Cases {
+		pkg := tc.Classname
+		if pkg == "" {
+			pkg = suite.Name
+		}
+		result := protocol.TestResult{
+			Package:    pkg,
+			Name:       tc.Name,
+			Status:     protocol.TestStatusPassed,
+			DurationMs: parseJUnitDuration(tc.Time),
+		}
+		switch {
+		case tc.Failure != nil || tc.Error != nil:
+			msg := tc.Failure
+			if msg == nil {
+				msg = tc.Error
+			}
+			result.Status = protocol.TestStatusFailed
+			result.Output = Excerpt(joinNonEmpty(msg.Message, msg.Text, tc.SystemOut))
+		case tc.Skipped != nil:
+			result.Status = protocol.TestStatusSkipped
+		}
+		*results = append(*results, result)
+	}
+	for _, nested := range suite.Suites {
+		collectJUnitSuite(nested, results)
+	}
+}
+
+func parseJUnitDuration(value string) int64 {
+	seconds, err := strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(value), ",", ""), 64)
+	if err != nil {
+		return 0
+	}
+	return int64(seconds * 1000)
+}
+
+func joinNonEmpty(values ...string) string {
+	parts := make([]string, 0, len(values))
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			parts = append(parts, value)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
@@ -0,0 +1,100 @@
+package testresults
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/izavyalov-dev/delta-ci/protocol"
+)
+
+func TestParseGoTestJSON(t *testing.T) {
+	log := strings.Join([]string{
+		"$ go test -json ./...",
+		`{"Action":"run","Package":"example.com/a","Test":"TestOK"}`,
+		`{"Action":"output","Package":"example.com/a","Test":"TestOK","Output":"=== RUN   TestOK\n"}`,
+		`{"Action":"pass","Package":"example.com/a","Test":"TestOK","Elapsed":0.01}`,
+		`{"Action":"run","Package":"example.com/a","Test":"TestBad"}`,
+		`{"Action":"output","Package":"example.com/a","Test":"TestBad","Output":"    a_test.go:12: want 1, got 2\n"}`,
+		`{"Action":"fail","Package":"example.com/a","Test":"TestBad","Elapsed":1.5}`,
+		`{"Action":"skip","Package":"example.com/a","Test":"TestSkip"}`,
+		`{"Action":"fail","Package":"example.com/a","Elapsed":1.6}`,
+		`{"Action":"output","Package":"example.com/b","Output":"# example.com/b\nb.go:3:1: syntax error\n"}`,
+		`{"Action":"fail","Package":"example.com/b","Elapsed":0}`,
+		"not json",
+	}, "\n")
+
+	results, err := ParseGoTestJSON(strings.NewReader(log))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(results) != 4 {
+		t.Fatalf("expected 4 results, got %+v", results)
+	}
+
+	bad := results[1]
+	if bad.Name != "TestBad" || bad.Status != protocol.TestStatusFailed || bad.DurationMs != 1500 {
+		t.Fatalf("unexpected failed test %+v", bad)
+	}
+	if !strings.Contains(bad.Output, "want 1, got 2") {
+		t.Fatalf("expected failure output excerpt, got %q", bad.Output)
+	}
+	if results[0].Output != "" {
+		t.Fatalf("expected no output for passing test, got %q", results[0].Output)
+	}
+	if results[2].Status != protocol.TestStatusSkipped {
+		t.Fatalf("expected skipped test, got %+v", results[2])
+	}
+
+	pkg := results[3]
+	if pkg.Package != "example.com/b" || pkg.Name != "" || pkg.Status != protocol.TestStatusFailed || !strings.Contains(pkg.Output, "syntax error") {
+		t.Fatalf("expected package-level failure, got %+v", pkg)
+	}
+}
+
+func TestParseJUnit(t *testing.T) {
+	report := `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites>
+  <testsuite name="suite">
+    <testcase classname="pkg.Calc" name="adds" time="0.25"/>
+    <testcase classname="pkg.Calc" name="divides" time="1.5">
+      <failure message="expected 2">assertion failed at Calc.java:10</failure>
+    </testcase>
+    <testcase name="ignored"><skipped/></testcase>
+    <testsuite name="nested">
+      <testcase classname="pkg.IO" name="reads"><error message="boom"/></testcase>
+    </testsuite>
+  </testsuite>
+</testsuites>`
+
+	results, err := ParseJUnit(strings.NewReader(report))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(results) != 4 {
+		t.Fatalf("expected 4 results, got %+v", results)
+	}
+	if results[0].Status != protocol.TestStatusPassed || results[0].DurationMs != 250 {
+		t.Fatalf("unexpected passing test %+v", results[0])
+	}
+	if results[1].Status != protocol.TestStatusFailed || !strings.Contains(results[1].Output, "Calc.java:10") {
+		t.Fatalf("unexpected failed test %+v", results[1])
+	}
+	if results[2].Status != protocol.TestStatusSkipped || results[2].Package != "suite" {
+		t.Fatalf("unexpected skipped test %+v", results[2])
+	}
+	if results[3].Status != protocol.TestStatusFailed || results[3].Package != "pkg.IO" {
+		t.Fatalf("unexpected errored test %+v", results[3])
+	}
+
+	if _, err := ParseJUnit(strings.NewReader("<html/>")); err == nil {
+		t.Fatal("expected error for non-junit document")
+	}
+}
+
+func TestExcerptKeepsTail(t *testing.T) {
+	output := strings.Repeat("a", MaxOutputBytes) + "FAIL here"
+	got := Excerpt(output)
+	if !strings.HasSuffix(got, "FAIL here") || len(got) > MaxOutputBytes+3 {
+		t.Fatalf("unexpected excerpt length %d", len(got))
+	}
+}
@@ -4,27 +4,125 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math/rand/v2"
 	"net/http"
+	"sync/atomic"
 	"time"
 
 	"github.com/izavyalov-dev/delta-ci/protocol"
 )
 
+// requestTimeout bounds regular protocol calls. Long-poll lease requests add
+// their wait time on top of it.
+const requestTimeout = 15 * time.Second
+
+// ErrStaleLease reports a 409 response: the lease is no longer held by this
+// runner, so retrying the message cannot succeed.
+var ErrStaleLease = errors.New("stale lease")
+
+// StatusError is returned for non-2xx responses.
+type StatusError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status %s", e.Status)
+}
+
+func (e *StatusError) Is(target error) bool {
+	return target == ErrStaleLease && e.StatusCode == http.StatusConflict
+}
+
+// retryPolicy bounds retries of transient failures: network errors, 429 and
+// 5xx responses. Delays double from baseDelay up to maxDelay with jitter.
+type retryPolicy struct {
+	attempts  int
+	baseDelay time.Duration
+	maxDelay  time.Duration
+}
+
+var defaultRetryPolicy = retryPolicy{attempts: 5, baseDelay: 500 * time.Millisecond, maxDelay: 10 * time.Second}
+
 type HTTPClient struct {
 	baseURL string
 	client  *http.Client
+	// longPoll has no client timeout; RequestLease bounds it with a context.
+	longPoll *http.Client
+	retry    retryPolicy
+	// token is the lease token sent as a bearer credential, if any.
+	token *atomic.Pointer[string]
+	// runnerSecret authenticates requests that carry no lease token, i.e.
+	// RequestLease.
+	runnerSecret string
 }
 
 func NewHTTPClient(baseURL string) *HTTPClient {
 	return &HTTPClient{
 		baseURL: baseURL,
 		client: &http.Client{
-			Timeout: 15 * time.Second,
+			Timeout: requestTimeout,
 		},
+		longPoll: &http.Client{},
+		retry:    defaultRetryPolicy,
 	}
 }
 
+// WithLeaseToken returns a client that authenticates as the holder of one
+// lease. The copy shares connections with c.
+func (c *HTTPClient) WithLeaseToken(token string) *HTTPClient {
+	clone := *c
+	clone.token = &atomic.Pointer[string]{}
+	clone.token.Store(&token)
+	return &clone
+}
+
+// WithRunnerSecret returns a client that presents secret, the orchestrator's
+// shared runner secret, when requesting leases. The copy shares connections
+// with c.
+func (c *HTTPClient) WithRunnerSecret(secret string) *HTTPClient {
+	clone := *c
+	clone.runnerSecret = secret
+	return &clone
+}
+
+// SetLeaseToken replaces the token of a lease client, e.g. with the refreshed
+// token from a HeartbeatAck. Empty tokens are ignored.
+func (c *HTTPClient) SetLeaseToken(token string) {
+	if c.token != nil && token != "" {
+		c.token.Store(&token)
+	}
+}
+
+// LeaseToken returns the current lease token.
+func (c *HTTPClient) LeaseToken() string {
+	if c.token == nil {
+		return ""
+	}
+	return *c.token.Load()
+}
+
+// RequestLease long-polls the orchestrator for work. The boolean result is
+// false when the wait elapsed without a lease becoming available. It is not
+// retried; callers poll again.
+func (c *HTTPClient) RequestLease(ctx context.Context, req protocol.RequestLease) (protocol.LeaseGranted, bool, error) {
+	wait := time.Duration(req.WaitSeconds) * time.Second
+	ctx, cancel := context.WithTimeout(ctx, wait+requestTimeout)
+	defer cancel()
+
+	var lease protocol.LeaseGranted
+	status, err := c.do(ctx, c.longPoll, "/api/v1/internal/request-lease", req, &lease)
+	if err != nil {
+		return protocol.LeaseGranted{}, false, err
+	}
+	if status == http.StatusNoContent {
+		return protocol.LeaseGranted{}, false, nil
+	}
+	return lease, true, nil
+}
+
 func (c *HTTPClient) AckLease(ctx context.Context, ack protocol.AckLease) error {
 	return c.post(ctx, "/api/v1/internal/ack-lease", ack, nil)
 }
@@ -45,29 +143,88 @@ func (c *HTTPClient) CancelAck(ctx context.Context, cancel protocol.CancelAck) e
 	return c.post(ctx, "/api/v1/internal/cancel-ack", cancel, nil)
 }
 
+func (c *HTTPClient) SendLogChunk(ctx context.Context, chunk protocol.LogChunk) error {
+	return c.post(ctx, "/api/v1/internal/log", chunk, nil)
+}
+
+// post sends a protocol message, retrying transient failures. 409 and other
+// 4xx responses are returned immediately; a retry after a lost response can
+// therefore surface ErrStaleLease for a message that was already applied.
 func (c *HTTPClient) post(ctx context.Context, path string, payload any, out any) error {
+	var err error
+	for attempt := 0; ; attempt++ {
+		_, err = c.do(ctx, c.client, path, payload, out)
+		if err == nil || !retryable(err) || attempt+1 >= c.retry.attempts || ctx.Err() != nil {
+			return err
+		}
+		timer := time.NewTimer(c.retry.backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff returns the jittered delay before retry attempt+1, in [d/2, d).
+func (p retryPolicy) backoff(attempt int) time.Duration {
+	delay := p.baseDelay << attempt
+	if delay <= 0 || delay > p.maxDelay {
+		delay = p.maxDelay
+	}
+	if delay <= 1 {
+		return delay
+	}
+	half := delay / 2
+	return half + rand.N(half)
+}
+
+func retryable(err error) bool {
+	var status *StatusError
+	if errors.As(err, &status) {
+		return status.StatusCode >= 500 || status.StatusCode == http.StatusTooManyRequests
+	}
+	var network *networkError
+	return errors.As(err, &network)
+}
+
+// networkError marks failures to get a response at all.
+type networkError struct {
+	err error
+}
+
+func (e *networkError) Error() string { return e.err.Error() }
+func (e *networkError) Unwrap() error { return e.err }
+
+func (c *HTTPClient) do(ctx context.Context, client *http.Client, path string, payload any, out any) (int, error) {
 	body, err := json.Marshal(payload)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+path, bytes.NewReader(body))
 	if err != nil {
-		return err
+		return 0, err
 	}
 	req.Header.Set("Content-Type", "application/json")
+	if token := c.LeaseToken(); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	} else if c.runnerSecret != "" {
+		req.Header.Set("Authorization", "Bearer "+c.runnerSecret)
+	}
 
-	resp, err := c.client.Do(req)
+	resp, err := client.Do(req)
 	if err != nil {
-		return err
+		return 0, &networkError{err: err}
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 300 {
-		return fmt.Errorf("unexpected status %s", resp.Status)
+		return resp.StatusCode, &StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
 	}
-	if out == nil {
-		return nil
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return resp.StatusCode, nil
 	}
-	return json.NewDecoder(resp.Body).Decode(out)
+	return resp.StatusCode, json.NewDecoder(resp.Body).Decode(out)
 }
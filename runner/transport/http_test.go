@@ -2,6 +2,7 @@ package transport
 
 import (
 	"context"
+	"errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
@@ -13,8 +14,10 @@ import (
 
 func TestHTTPClientPostsJSON(t *testing.T) {
 	var received []byte
+	var authorization string
 	srv := mustTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer r.Body.Close()
+		authorization = r.Header.Get("Authorization")
 		var err error
 		received, err = io.ReadAll(r.Body)
 		if err != nil {
@@ -26,7 +29,7 @@ func TestHTTPClientPostsJSON(t *testing.T) {
 	}
 	defer srv.Close()
 
-	client := NewHTTPClient(srv.URL)
+	client := NewHTTPClient(srv.URL).WithLeaseToken("token-1")
 	err := client.AckLease(context.Background(), protocol.AckLease{
 		Type:       "AckLease",
 		JobID:      "job",
@@ -41,6 +44,9 @@ func TestHTTPClientPostsJSON(t *testing.T) {
 	if len(received) == 0 {
 		t.Fatal("expected payload to be sent")
 	}
+	if authorization != "Bearer token-1" {
+		t.Fatalf("expected lease token, got %q", authorization)
+	}
 }
 
 // mustTestServer starts a test server or skips if the sandbox disallows listening.
@@ -53,3 +59,88 @@ func mustTestServer(t *testing.T, handler http.Handler) *httptest.Server {
 	}()
 	return httptest.NewServer(handler)
 }
+
+func TestHTTPClientRequestLease(t *testing.T) {
+	granted := false
+	srv := mustTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/internal/request-lease" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer runner-secret" {
+			t.Errorf("unexpected authorization %q", got)
+		}
+		if !granted {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"type":"LeaseGranted","lease_id":"lease-1","job_id":"job-1"}`)
+	}))
+	if srv == nil {
+		return
+	}
+	defer srv.Close()
+
+	client := NewHTTPClient(srv.URL).WithRunnerSecret("runner-secret")
+	req := protocol.RequestLease{Type: "RequestLease", RunnerID: "runner", WaitSeconds: 1}
+
+	if _, ok, err := client.RequestLease(context.Background(), req); err != nil || ok {
+		t.Fatalf("expected no lease, got ok=%v err=%v", ok, err)
+	}
+
+	granted = true
+	lease, ok, err := client.RequestLease(context.Background(), req)
+	if err != nil || !ok {
+		t.Fatalf("expected lease, got ok=%v err=%v", ok, err)
+	}
+	if lease.LeaseID != "lease-1" {
+		t.Fatalf("unexpected lease %+v", lease)
+	}
+}
+
+func TestHTTPClientRetriesServerErrors(t *testing.T) {
+	calls := 0
+	srv := mustTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	if srv == nil {
+		return
+	}
+	defer srv.Close()
+
+	client := NewHTTPClient(srv.URL)
+	client.retry = retryPolicy{attempts: 5, baseDelay: time.Millisecond, maxDelay: 5 * time.Millisecond}
+	if err := client.Complete(context.Background(), protocol.Complete{Type: "Complete", LeaseID: "lease"}); err != nil {
+		t.Fatalf("complete: %v", err)
+	}
+	if calls != 3 {
+		t.Fatalf("expected 3 calls, got %d", calls)
+	}
+}
+
+func TestHTTPClientStaleLeaseIsTerminal(t *testing.T) {
+	calls := 0
+	srv := mustTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusConflict)
+	}))
+	if srv == nil {
+		return
+	}
+	defer srv.Close()
+
+	client := NewHTTPClient(srv.URL)
+	client.retry = retryPolicy{attempts: 5, baseDelay: time.Millisecond, maxDelay: 5 * time.Millisecond}
+	err := client.Complete(context.Background(), protocol.Complete{Type: "Complete", LeaseID: "lease"})
+	if !errors.Is(err, ErrStaleLease) {
+		t.Fatalf("expected ErrStaleLease, got %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected a single call, got %d", calls)
+	}
+}
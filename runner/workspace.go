@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/izavyalov-dev/delta-ci/protocol"
+)
+
+// workspaceManager checks out leased commits into fresh per-attempt
+// workspaces. Objects are fetched into a shared bare mirror per repository so
+// repeated checkouts only transfer what is new.
+type workspaceManager struct {
+	root      string
+	mirrorDir string
+}
+
+func defaultWorkspaceRoot() string {
+	return filepath.Join(os.TempDir(), "delta-ci", "workspaces")
+}
+
+func defaultMirrorDir() string {
+	return filepath.Join(os.TempDir(), "delta-ci", "mirrors")
+}
+
+// prepare creates a workspace for the lease and checks out its commit in a
+// detached HEAD. The caller owns the returned directory and must remove it
+// with cleanup.
+func (m workspaceManager) prepare(ctx context.Context, lease protocol.LeaseGranted) (string, error) {
+	if lease.RepoURL == "" {
+		return "", errors.New("lease has no repo_url")
+	}
+	if lease.CommitSHA == "" || strings.HasPrefix(lease.CommitSHA, "-") {
+		return "", fmt.Errorf("invalid commit sha %q", lease.CommitSHA)
+	}
+
+	mirror, err := m.syncMirror(ctx, lease.RepoURL, lease.CommitSHA)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(m.root, 0o755); err != nil {
+		return "", fmt.Errorf("create workspace root: %w", err)
+	}
+	dir, err := os.MkdirTemp(m.root, "attempt-")
+	if err != nil {
+		return "", fmt.Errorf("create workspace: %w", err)
+	}
+
+	// --shared borrows objects from the mirror instead of copying them.
+	if err := runGit(ctx, "", "clone", "--quiet", "--no-checkout", "--shared", "--", mirror, dir); err != nil {
+		_ = os.RemoveAll(dir)
+		return "", fmt.Errorf("clone workspace: %w", err)
+	}
+	if err := runGit(ctx, dir, "checkout", "--quiet", "--detach", lease.CommitSHA); err != nil {
+		_ = os.RemoveAll(dir)
+		return "", fmt.Errorf("checkout %s: %w", lease.CommitSHA, err)
+	}
+	return dir, nil
+}
+
+// cleanup removes a workspace created by prepare.
+func (m workspaceManager) cleanup(dir string) error {
+	if dir == "" {
+		return nil
+	}
+	return os.RemoveAll(dir)
+}
+
+// syncMirror makes sure the bare mirror for repoURL contains commit and
+// returns its path. Access to each mirror is serialized with a lock file so
+// concurrent runners on one host do not fetch into it at the same time.
+func (m workspaceManager) syncMirror(ctx context.Context, repoURL, commit string) (string, error) {
+	if err := os.MkdirAll(m.mirrorDir, 0o755); err != nil {
+		return "", fmt.Errorf("create mirror dir: %w", err)
+	}
+	mirror := filepath.Join(m.mirrorDir, hashValue(repoURL)+".git")
+
+	unlock, err := lockFile(mirror + ".lock")
+	if err != nil {
+		return "", fmt.Errorf("lock mirror: %w", err)
+	}
+	defer unlock()
+
+	if !pathExists(mirror) {
+		if err := runGit(ctx, "", "clone", "--quiet", "--mirror", "--", repoURL, mirror); err != nil {
+			_ = os.RemoveAll(mirror)
+			return "", fmt.Errorf("create mirror: %w", err)
+		}
+	}
+	if hasCommit(ctx, mirror, commit) {
+		return mirror, nil
+	}
+
+	if err := runGit(ctx, mirror, "fetch", "--quiet", "--prune", "origin"); err != nil {
+		return "", fmt.Errorf("update mirror: %w", err)
+	}
+	if hasCommit(ctx, mirror, commit) {
+		return mirror, nil
+	}
+
+	// The commit may not be reachable from any advertised ref (for example a
+	// force-pushed branch); ask for it directly.
+	if err := runGit(ctx, mirror, "fetch", "--quiet", "origin", commit); err != nil {
+		return "", fmt.Errorf("fetch commit %s: %w", commit, err)
+	}
+	if !hasCommit(ctx, mirror, commit) {
+		return "", fmt.Errorf("commit %s not found in repository", commit)
+	}
+	return mirror, nil
+}
+
+func hasCommit(ctx context.Context, repo, commit string) bool {
+	return runGit(ctx, repo, "cat-file", "-e", commit+"^{commit}") == nil
+}
+
+// runGit runs git non-interactively and folds its output into the error.
+func runGit(ctx context.Context, dir string, args ...string) error {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(out.String()); msg != "" {
+			return fmt.Errorf("git %s: %w: %s", args[0], err, msg)
+		}
+		return fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/izavyalov-dev/delta-ci/protocol"
+)
+
+func TestWorkspaceChecksOutLeasedCommit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	repo := t.TempDir()
+	gitCmd(t, repo, "init", "--quiet")
+	first := commitFile(t, repo, "version.txt", "one")
+	second := commitFile(t, repo, "version.txt", "two")
+
+	base := t.TempDir()
+	manager := workspaceManager{
+		root:      filepath.Join(base, "workspaces"),
+		mirrorDir: filepath.Join(base, "mirrors"),
+	}
+	lease := protocol.LeaseGranted{RepoURL: "file://" + repo, CommitSHA: first}
+
+	dir, err := manager.prepare(context.Background(), lease)
+	if err != nil {
+		t.Fatalf("prepare: %v", err)
+	}
+	assertFile(t, filepath.Join(dir, "version.txt"), "one")
+	if head := gitCmd(t, dir, "rev-parse", "HEAD"); head != first {
+		t.Fatalf("expected HEAD %s, got %s", first, head)
+	}
+	if err := manager.cleanup(dir); err != nil {
+		t.Fatalf("cleanup: %v", err)
+	}
+	if pathExists(dir) {
+		t.Fatal("expected workspace to be removed")
+	}
+
+	// A commit created after the mirror exists must be fetched into it.
+	third := commitFile(t, repo, "version.txt", "three")
+	for _, commit := range []string{second, third} {
+		lease.CommitSHA = commit
+		dir, err := manager.prepare(context.Background(), lease)
+		if err != nil {
+			t.Fatalf("prepare %s: %v", commit, err)
+		}
+		if head := gitCmd(t, dir, "rev-parse", "HEAD"); head != commit {
+			t.Fatalf("expected HEAD %s, got %s", commit, head)
+		}
+		_ = manager.cleanup(dir)
+	}
+
+	mirrors, err := os.ReadDir(manager.mirrorDir)
+	if err != nil {
+		t.Fatalf("read mirrors: %v", err)
+	}
+	var count int
+	for _, entry := range mirrors {
+		if entry.IsDir() {
+			count++
+		}
+	}
+	if count != 1 {
+		t.Fatalf("expected a single reused mirror, got %d", count)
+	}
+
+	lease.CommitSHA = strings.Repeat("0", 40)
+	if _, err := manager.prepare(context.Background(), lease); err == nil {
+		t.Fatal("expected unknown commit to fail")
+	}
+}
+
+func commitFile(t *testing.T, repo, name, content string) string {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(repo, name), []byte(content), 0o644); err != nil {
+		t.Fatalf("write %s: %v", name, err)
+	}
+	gitCmd(t, repo, "add", name)
+	gitCmd(t, repo, "-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "--quiet", "-m", content)
+	return gitCmd(t, repo, "rev-parse", "HEAD")
+}
+
+func gitCmd(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %v: %s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func assertFile(t *testing.T, path, want string) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read %s: %v", path, err)
+	}
+	if string(data) != want {
+		t.Fatalf("expected %s to contain %q, got %q", path, want, data)
+	}
+}
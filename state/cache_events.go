@@ -12,7 +12,10 @@ type CacheEvent struct {
 	CacheType    string
 	CacheKey     string
 	Hit          bool
-	ReadOnly     bool
+	// Match is "exact" or "partial" for hits; MatchedKey is the restored key.
+	Match      string
+	MatchedKey string
+	ReadOnly   bool
 }
 
 func (s *Store) RecordCacheEvents(ctx context.Context, attemptID string, events []CacheEvent) error {
@@ -31,9 +34,9 @@ func (s *Store) RecordCacheEvents(ctx context.Context, attemptID string, events
 				continue
 			}
 			if _, err := tx.ExecContext(ctx, `
-INSERT INTO job_cache_events (job_attempt_id, cache_type, cache_key, cache_hit, read_only)
-VALUES ($1, $2, $3, $4, $5)
-`, attemptID, cacheType, cacheKey, event.Hit, event.ReadOnly); err != nil {
+INSERT INTO job_cache_events (job_attempt_id, cache_type, cache_key, cache_hit, match_kind, matched_key, read_only)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+`, attemptID, cacheType, cacheKey, event.Hit, event.Match, event.MatchedKey, event.ReadOnly); err != nil {
 				return err
 			}
 		}
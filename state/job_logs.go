@@ -0,0 +1,95 @@
+package state
+
+import (
+	"context"
+	"errors"
+)
+
+// LogChunk is a contiguous slice of a job attempt's combined log.
+type LogChunk struct {
+	JobAttemptID string
+	Seq          int64
+	Offset       int64
+	Data         []byte
+}
+
+// AppendLogChunk stores a streamed log chunk. Chunks are keyed by sequence
+// number, so a retried chunk is ignored.
+func (s *Store) AppendLogChunk(ctx context.Context, chunk LogChunk) error {
+	if chunk.JobAttemptID == "" {
+		return errors.New("attempt id required")
+	}
+	if chunk.Seq < 0 || chunk.Offset < 0 {
+		return errors.New("log chunk seq and offset must be non-negative")
+	}
+
+	_, err := s.db.ExecContext(ctx, `
+INSERT INTO job_log_chunks (job_attempt_id, seq, byte_offset, data)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (job_attempt_id, seq) DO NOTHING
+`, chunk.JobAttemptID, chunk.Seq, chunk.Offset, chunk.Data)
+	return err
+}
+
+// ReadJobLog returns up to length bytes of an attempt's streamed log starting
+// at offset, together with the resolved start offset and the number of bytes
+// received so far. A negative offset
+// reads the last -offset bytes; a non-positive length reads to the end. Reading
+// stops early at a gap left by a chunk that has not arrived yet.
+func (s *Store) ReadJobLog(ctx context.Context, attemptID string, offset, length int64) ([]byte, int64, int64, error) {
+	var size int64
+	if err := s.db.QueryRowContext(ctx, `
+SELECT COALESCE(MAX(byte_offset + octet_length(data)), 0)
+FROM job_log_chunks
+WHERE job_attempt_id = $1
+`, attemptID).Scan(&size); err != nil {
+		return nil, 0, 0, err
+	}
+
+	if offset < 0 {
+		offset = max(size+offset, 0)
+	}
+	end := size
+	if length > 0 && offset+length < end {
+		end = offset + length
+	}
+	if offset >= end {
+		return nil, offset, size, nil
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+SELECT byte_offset, data
+FROM job_log_chunks
+WHERE job_attempt_id = $1
+  AND byte_offset + octet_length(data) > $2
+  AND byte_offset < $3
+ORDER BY byte_offset ASC, seq ASC
+`, attemptID, offset, end)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	defer rows.Close()
+
+	data := make([]byte, 0, end-offset)
+	pos := offset
+	for rows.Next() {
+		var chunkOffset int64
+		var chunk []byte
+		if err := rows.Scan(&chunkOffset, &chunk); err != nil {
+			return nil, 0, 0, err
+		}
+		if chunkOffset > pos {
+			break
+		}
+		chunkEnd := min(chunkOffset+int64(len(chunk)), end)
+		if chunkEnd <= pos {
+			continue
+		}
+		data = append(data, chunk[pos-chunkOffset:chunkEnd-chunkOffset]...)
+		pos = chunkEnd
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, 0, err
+	}
+	return data, offset, size, nil
+}
@@ -0,0 +1,81 @@
+package state
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// JobStep captures the execution result of a single step within a job attempt.
+type JobStep struct {
+	JobAttemptID string     `json:"job_attempt_id"`
+	Index        int        `json:"index"`
+	Command      string     `json:"command"`
+	Status       string     `json:"status"`
+	ExitCode     int        `json:"exit_code"`
+	StartedAt    *time.Time `json:"started_at,omitempty"`
+	FinishedAt   *time.Time `json:"finished_at,omitempty"`
+	LogOffset    int64      `json:"log_offset"`
+	LogBytes     int64      `json:"log_bytes"`
+}
+
+// RecordJobSteps persists step results for a job attempt.
+func (s *Store) RecordJobSteps(ctx context.Context, attemptID string, steps []JobStep) error {
+	if attemptID == "" {
+		return errors.New("attempt id required")
+	}
+	if len(steps) == 0 {
+		return nil
+	}
+
+	return s.withTx(ctx, func(tx *sql.Tx) error {
+		for _, step := range steps {
+			if step.Status == "" {
+				return errors.New("step status required")
+			}
+			if _, err := tx.ExecContext(ctx, `
+INSERT INTO job_attempt_steps (job_attempt_id, step_index, command, status, exit_code, started_at, finished_at, log_offset, log_bytes)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+ON CONFLICT (job_attempt_id, step_index) DO NOTHING
+`, attemptID, step.Index, step.Command, step.Status, step.ExitCode, step.StartedAt, step.FinishedAt, step.LogOffset, step.LogBytes); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ListJobStepsByJob returns step results for a job across attempts.
+func (s *Store) ListJobStepsByJob(ctx context.Context, jobID string) ([]JobStep, error) {
+	rows, err := s.db.QueryContext(ctx, `
+SELECT st.job_attempt_id, st.step_index, st.command, st.status, st.exit_code, st.started_at, st.finished_at, st.log_offset, st.log_bytes
+FROM job_attempt_steps st
+JOIN job_attempts ja ON ja.id = st.job_attempt_id
+WHERE ja.job_id = $1
+ORDER BY ja.attempt_number ASC, st.step_index ASC
+`, jobID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var steps []JobStep
+	for rows.Next() {
+		var step JobStep
+		var startedAt sql.NullTime
+		var finishedAt sql.NullTime
+		if err := rows.Scan(&step.JobAttemptID, &step.Index, &step.Command, &step.Status, &step.ExitCode, &startedAt, &finishedAt, &step.LogOffset, &step.LogBytes); err != nil {
+			return nil, err
+		}
+		if startedAt.Valid {
+			step.StartedAt = &startedAt.Time
+		}
+		if finishedAt.Valid {
+			step.FinishedAt = &finishedAt.Time
+		}
+		steps = append(steps, step)
+	}
+
+	return steps, rows.Err()
+}
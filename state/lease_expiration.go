@@ -4,14 +4,37 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"time"
 )
 
 // ErrNoExpiredLeases signals there are no leases ready to expire.
 var ErrNoExpiredLeases = errors.New("state: no expired leases")
 
+// ExpiredLease identifies a lease expired by ExpireLeases and the runner that
+// held it.
+type ExpiredLease struct {
+	LeaseID   string
+	AttemptID string
+	RunnerID  string
+}
+
+// leaseRelease is a locked lease row with its attempt and job, as needed to
+// end the lease and requeue the work.
+type leaseRelease struct {
+	leaseID      string
+	runnerID     sql.NullString
+	attemptID    string
+	jobID        string
+	leaseState   LeaseState
+	attemptState JobState
+	jobState     JobState
+}
+
 // ExpireLeases finds expired leases and requeues their attempts when allowed.
-func (s *Store) ExpireLeases(ctx context.Context, now time.Time, limit int) (int, error) {
+// The holding runner is charged with the expiry and, if it was busy with the
+// lease, marked OFFLINE until it polls again.
+func (s *Store) ExpireLeases(ctx context.Context, now time.Time, limit int) ([]ExpiredLease, error) {
 	if limit <= 0 {
 		limit = 10
 	}
@@ -19,18 +42,13 @@ func (s *Store) ExpireLeases(ctx context.Context, now time.Time, limit int) (int
 		now = time.Now().UTC()
 	}
 
-	processed := 0
-	for processed < limit {
+	var expired []ExpiredLease
+	for len(expired) < limit {
+		var lease ExpiredLease
 		err := s.withTx(ctx, func(tx *sql.Tx) error {
-			var leaseID string
-			var attemptID string
-			var jobID string
-			var leaseState LeaseState
-			var attemptState JobState
-			var jobState JobState
-
+			var rel leaseRelease
 			row := tx.QueryRowContext(ctx, `
-SELECT l.id, l.job_attempt_id, l.state, a.state, a.job_id, j.state
+SELECT l.id, l.runner_id, l.job_attempt_id, l.state, a.state, a.job_id, j.state
 FROM leases l
 JOIN job_attempts a ON a.id = l.job_attempt_id
 JOIN jobs j ON j.id = a.job_id
@@ -42,91 +60,160 @@ FOR UPDATE SKIP LOCKED
 LIMIT 1
 `, now)
 
-			if err := row.Scan(&leaseID, &attemptID, &leaseState, &attemptState, &jobID, &jobState); err != nil {
+			if err := row.Scan(&rel.leaseID, &rel.runnerID, &rel.attemptID, &rel.leaseState, &rel.attemptState, &rel.jobID, &rel.jobState); err != nil {
 				if errors.Is(err, sql.ErrNoRows) {
 					return ErrNoExpiredLeases
 				}
 				return err
 			}
 
-			if err := validateLeaseTransition(leaseID, leaseState, LeaseStateExpired); err != nil {
+			if err := releaseLease(ctx, tx, rel, LeaseStateExpired, now); err != nil {
 				return err
 			}
 
-			attemptCanQueue := true
-			if err := validateJobTransition(attemptID, attemptState, JobStateQueued); err != nil {
-				if IsTransitionError(err) {
-					attemptCanQueue = false
-				} else {
+			if rel.runnerID.Valid {
+				if _, err := tx.ExecContext(ctx, `
+UPDATE runners
+SET expired_leases = expired_leases + 1,
+    state = CASE WHEN state = 'BUSY' AND current_lease_id = $2 THEN 'OFFLINE' ELSE state END,
+    current_lease_id = CASE WHEN current_lease_id = $2 THEN NULL ELSE current_lease_id END,
+    updated_at = NOW()
+WHERE id = $1
+`, rel.runnerID.String, rel.leaseID); err != nil {
 					return err
 				}
 			}
 
-			jobCanQueue := true
-			if err := validateJobTransition(jobID, jobState, JobStateQueued); err != nil {
-				if IsTransitionError(err) {
-					jobCanQueue = false
-				} else {
-					return err
-				}
+			lease = ExpiredLease{LeaseID: rel.leaseID, AttemptID: rel.attemptID, RunnerID: rel.runnerID.String}
+			return nil
+		})
+
+		if err != nil {
+			if errors.Is(err, ErrNoExpiredLeases) {
+				break
+			}
+			return expired, err
+		}
+
+		expired = append(expired, lease)
+	}
+
+	return expired, nil
+}
+
+// RevokeLease ends a GRANTED or ACTIVE lease on behalf of its holder, e.g. a
+// worker shutting down, and requeues the attempt right away instead of
+// waiting for the lease to expire. The runner is freed, not charged.
+func (s *Store) RevokeLease(ctx context.Context, leaseID string, now time.Time) error {
+	if now.IsZero() {
+		now = time.Now().UTC()
+	}
+
+	return s.withTx(ctx, func(tx *sql.Tx) error {
+		var rel leaseRelease
+		row := tx.QueryRowContext(ctx, `
+SELECT l.id, l.runner_id, l.job_attempt_id, l.state, a.state, a.job_id, j.state
+FROM leases l
+JOIN job_attempts a ON a.id = l.job_attempt_id
+JOIN jobs j ON j.id = a.job_id
+WHERE l.id = $1
+FOR UPDATE
+`, leaseID)
+		if err := row.Scan(&rel.leaseID, &rel.runnerID, &rel.attemptID, &rel.leaseState, &rel.attemptState, &rel.jobID, &rel.jobState); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("%w: lease %s", ErrNotFound, leaseID)
 			}
+			return err
+		}
 
+		if err := releaseLease(ctx, tx, rel, LeaseStateRevoked, now); err != nil {
+			return err
+		}
+
+		if rel.runnerID.Valid {
 			if _, err := tx.ExecContext(ctx, `
-UPDATE leases
-SET state = $2,
+UPDATE runners
+SET current_lease_id = NULL,
+    state = CASE WHEN state = 'BUSY' THEN 'IDLE' ELSE state END,
     updated_at = NOW()
 WHERE id = $1
-`, leaseID, LeaseStateExpired); err != nil {
+  AND current_lease_id = $2
+`, rel.runnerID.String, rel.leaseID); err != nil {
 				return err
 			}
+		}
+		return nil
+	})
+}
 
-			if attemptCanQueue {
-				if _, err := tx.ExecContext(ctx, `
+// releaseLease moves a locked lease to next and requeues its attempt and job
+// where the state machine allows it.
+func releaseLease(ctx context.Context, tx *sql.Tx, rel leaseRelease, next LeaseState, now time.Time) error {
+	if err := validateLeaseTransition(rel.leaseID, rel.leaseState, next); err != nil {
+		return err
+	}
+
+	attemptCanQueue := true
+	if err := validateJobTransition(rel.attemptID, rel.attemptState, JobStateQueued); err != nil {
+		if IsTransitionError(err) {
+			attemptCanQueue = false
+		} else {
+			return err
+		}
+	}
+
+	jobCanQueue := true
+	if err := validateJobTransition(rel.jobID, rel.jobState, JobStateQueued); err != nil {
+		if IsTransitionError(err) {
+			jobCanQueue = false
+		} else {
+			return err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+UPDATE leases
+SET state = $2,
+    updated_at = NOW()
+WHERE id = $1
+`, rel.leaseID, next); err != nil {
+		return err
+	}
+
+	if attemptCanQueue {
+		if _, err := tx.ExecContext(ctx, `
 UPDATE job_attempts
 SET state = $2,
     updated_at = NOW()
 WHERE id = $1
-`, attemptID, JobStateQueued); err != nil {
-					return err
-				}
-			}
+`, rel.attemptID, JobStateQueued); err != nil {
+			return err
+		}
+	}
 
-			if jobCanQueue {
-				if _, err := tx.ExecContext(ctx, `
+	if jobCanQueue {
+		if _, err := tx.ExecContext(ctx, `
 UPDATE jobs
 SET state = $2,
     updated_at = NOW()
 WHERE id = $1
-`, jobID, JobStateQueued); err != nil {
-					return err
-				}
-			}
+`, rel.jobID, JobStateQueued); err != nil {
+			return err
+		}
+	}
 
-			if attemptCanQueue {
-				if _, err := tx.ExecContext(ctx, `
+	if attemptCanQueue {
+		if _, err := tx.ExecContext(ctx, `
 INSERT INTO job_queue (attempt_id, available_at)
 VALUES ($1, $2)
 ON CONFLICT (attempt_id) DO UPDATE
 SET available_at = EXCLUDED.available_at,
     inflight_until = NULL,
     updated_at = NOW()
-`, attemptID, now); err != nil {
-					return err
-				}
-			}
-
-			return nil
-		})
-
-		if err != nil {
-			if errors.Is(err, ErrNoExpiredLeases) {
-				break
-			}
-			return processed, err
+`, rel.attemptID, now); err != nil {
+			return err
 		}
-
-		processed++
 	}
 
-	return processed, nil
+	return nil
 }
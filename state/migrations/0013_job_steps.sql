@@ -0,0 +1,20 @@
+-- Per-step execution results for job attempts
+CREATE TABLE job_attempt_steps (
+    job_attempt_id TEXT NOT NULL REFERENCES job_attempts(id) ON DELETE CASCADE,
+    step_index INTEGER NOT NULL,
+    command TEXT NOT NULL,
+    status TEXT NOT NULL,
+    exit_code INTEGER NOT NULL DEFAULT 0,
+    started_at TIMESTAMPTZ,
+    finished_at TIMESTAMPTZ,
+    log_offset BIGINT NOT NULL DEFAULT 0,
+    log_bytes BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (job_attempt_id, step_index),
+    CONSTRAINT job_attempt_steps_index_check CHECK (step_index >= 0)
+);
+
+ALTER TABLE job_attempt_steps
+    ADD CONSTRAINT job_attempt_steps_status_check CHECK (
+        status IN ('SUCCEEDED', 'FAILED', 'CANCELED', 'SKIPPED')
+    );
@@ -0,0 +1,7 @@
+ALTER TABLE job_attempt_steps
+    DROP CONSTRAINT job_attempt_steps_status_check;
+
+ALTER TABLE job_attempt_steps
+    ADD CONSTRAINT job_attempt_steps_status_check CHECK (
+        status IN ('SUCCEEDED', 'FAILED', 'CANCELED', 'TIMED_OUT', 'SKIPPED')
+    );
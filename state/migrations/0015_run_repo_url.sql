@@ -0,0 +1,2 @@
+ALTER TABLE runs
+    ADD COLUMN repo_url TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,13 @@
+-- Streamed log chunks for job attempts, keyed by runner sequence number
+CREATE TABLE job_log_chunks (
+    job_attempt_id TEXT NOT NULL REFERENCES job_attempts(id) ON DELETE CASCADE,
+    seq BIGINT NOT NULL,
+    byte_offset BIGINT NOT NULL,
+    data BYTEA NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (job_attempt_id, seq),
+    CONSTRAINT job_log_chunks_seq_check CHECK (seq >= 0),
+    CONSTRAINT job_log_chunks_offset_check CHECK (byte_offset >= 0)
+);
+
+CREATE INDEX job_log_chunks_offset_idx ON job_log_chunks (job_attempt_id, byte_offset);
@@ -0,0 +1,4 @@
+-- Size and checksum for uploaded artifact files
+ALTER TABLE job_artifacts
+    ADD COLUMN size_bytes BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN sha256 TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,14 @@
+-- Per-test results parsed from go test -json output and JUnit reports
+CREATE TABLE job_test_results (
+    id BIGSERIAL PRIMARY KEY,
+    job_attempt_id TEXT NOT NULL REFERENCES job_attempts(id) ON DELETE CASCADE,
+    package TEXT NOT NULL DEFAULT '',
+    name TEXT NOT NULL,
+    status TEXT NOT NULL,
+    duration_ms BIGINT NOT NULL DEFAULT 0,
+    output TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT job_test_results_status_check CHECK (status IN ('PASSED', 'FAILED', 'SKIPPED'))
+);
+
+CREATE INDEX job_test_results_attempt_status_idx ON job_test_results (job_attempt_id, status);
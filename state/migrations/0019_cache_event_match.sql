@@ -0,0 +1,7 @@
+-- Exact vs. restore-key (partial) cache hits
+ALTER TABLE job_cache_events
+    ADD COLUMN match_kind TEXT NOT NULL DEFAULT '',
+    ADD COLUMN matched_key TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE job_cache_events
+    ADD CONSTRAINT job_cache_events_match_kind_check CHECK (match_kind IN ('', 'exact', 'partial'));
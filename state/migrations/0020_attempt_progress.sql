@@ -0,0 +1,6 @@
+-- Latest heartbeat progress per attempt, and the progress last sent to VCS
+ALTER TABLE job_attempts
+    ADD COLUMN progress JSONB;
+
+ALTER TABLE vcs_status_reports
+    ADD COLUMN last_progress TEXT NOT NULL DEFAULT '';
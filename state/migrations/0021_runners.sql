@@ -0,0 +1,10 @@
+-- Runners seen polling for work and the labels they advertise
+CREATE TABLE runners (
+    id TEXT PRIMARY KEY,
+    labels JSONB NOT NULL DEFAULT '[]',
+    last_seen_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX runners_last_seen_idx ON runners (last_seen_at);
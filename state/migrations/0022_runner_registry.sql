@@ -0,0 +1,17 @@
+-- Runner inventory: reported version, lifecycle state, current lease and
+-- leases lost to expiry
+ALTER TABLE runners
+    ADD COLUMN version TEXT NOT NULL DEFAULT '',
+    ADD COLUMN state TEXT NOT NULL DEFAULT 'IDLE',
+    ADD COLUMN current_lease_id TEXT,
+    ADD COLUMN expired_leases INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE runners
+    ADD CONSTRAINT runners_state_check CHECK (
+        state IN (
+            'IDLE',
+            'BUSY',
+            'DRAINING',
+            'OFFLINE'
+        )
+    );
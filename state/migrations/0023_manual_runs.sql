@@ -0,0 +1,13 @@
+-- Manual runs: run parameters and API request idempotency mapping
+ALTER TABLE runs
+    ADD COLUMN full_run BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN parameters JSONB NOT NULL DEFAULT '{}'::jsonb;
+
+CREATE TABLE run_requests (
+    run_id TEXT PRIMARY KEY REFERENCES runs(id) ON DELETE CASCADE,
+    idempotency_key TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX run_requests_idempotency_key_idx ON run_requests(idempotency_key)
+    WHERE idempotency_key IS NOT NULL;
@@ -0,0 +1,6 @@
+-- Run listing: newest-first keyset pagination with common filters
+CREATE INDEX runs_created_at_id_idx ON runs(created_at DESC, id DESC);
+CREATE INDEX runs_repo_ref_created_at_idx ON runs(repo_id, ref, created_at DESC, id DESC);
+CREATE INDEX runs_commit_sha_idx ON runs(commit_sha);
+CREATE INDEX runs_state_created_at_idx ON runs(state, created_at DESC, id DESC);
+CREATE INDEX run_triggers_pr_number_idx ON run_triggers(pr_number) WHERE pr_number IS NOT NULL;
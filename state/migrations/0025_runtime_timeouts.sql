@@ -0,0 +1,5 @@
+-- Job and run timeouts enforced by the orchestrator sweeper
+ALTER TABLE job_attempts ADD COLUMN timeout_requested_at TIMESTAMPTZ;
+ALTER TABLE runs ADD COLUMN timeout_requested_at TIMESTAMPTZ;
+CREATE INDEX job_attempts_active_started_at_idx ON job_attempts(started_at) WHERE state IN ('STARTING', 'RUNNING', 'CANCEL_REQUESTED');
+CREATE INDEX runs_active_created_at_idx ON runs(created_at) WHERE state IN ('QUEUED', 'RUNNING', 'CANCEL_REQUESTED');
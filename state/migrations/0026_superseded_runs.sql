@@ -0,0 +1,2 @@
+-- Runs canceled because a newer commit for the same PR or branch started
+ALTER TABLE runs ADD COLUMN superseded_by TEXT REFERENCES runs(id);
@@ -0,0 +1,2 @@
+-- Fingerprint of the manual run request body stored with its idempotency key
+ALTER TABLE run_requests ADD COLUMN request_hash TEXT;
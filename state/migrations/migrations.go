@@ -44,6 +44,51 @@ var cacheEvents string
 //go:embed 0012_explainability.sql
 var explainability string
 
+//go:embed 0013_job_steps.sql
+var jobSteps string
+
+//go:embed 0014_step_timeouts.sql
+var stepTimeouts string
+
+//go:embed 0015_run_repo_url.sql
+var runRepoURL string
+
+//go:embed 0016_job_log_chunks.sql
+var jobLogChunks string
+
+//go:embed 0017_artifact_metadata.sql
+var artifactMetadata string
+
+//go:embed 0018_test_results.sql
+var testResults string
+
+//go:embed 0019_cache_event_match.sql
+var cacheEventMatch string
+
+//go:embed 0020_attempt_progress.sql
+var attemptProgress string
+
+//go:embed 0021_runners.sql
+var runners string
+
+//go:embed 0022_runner_registry.sql
+var runnerRegistry string
+
+//go:embed 0023_manual_runs.sql
+var manualRuns string
+
+//go:embed 0024_run_listing.sql
+var runListing string
+
+//go:embed 0025_runtime_timeouts.sql
+var runtimeTimeouts string
+
+//go:embed 0026_superseded_runs.sql
+var supersededRuns string
+
+//go:embed 0027_run_request_hash.sql
+var runRequestHash string
+
 // All lists migrations in application order.
 var All = []Migration{
 	{ID: "0001_initial", Script: initial},
@@ -58,4 +103,19 @@ var All = []Migration{
 	{ID: "0010_recipes", Script: recipes},
 	{ID: "0011_cache_events", Script: cacheEvents},
 	{ID: "0012_explainability", Script: explainability},
+	{ID: "0013_job_steps", Script: jobSteps},
+	{ID: "0014_step_timeouts", Script: stepTimeouts},
+	{ID: "0015_run_repo_url", Script: runRepoURL},
+	{ID: "0016_job_log_chunks", Script: jobLogChunks},
+	{ID: "0017_artifact_metadata", Script: artifactMetadata},
+	{ID: "0018_test_results", Script: testResults},
+	{ID: "0019_cache_event_match", Script: cacheEventMatch},
+	{ID: "0020_attempt_progress", Script: attemptProgress},
+	{ID: "0021_runners", Script: runners},
+	{ID: "0022_runner_registry", Script: runnerRegistry},
+	{ID: "0023_manual_runs", Script: manualRuns},
+	{ID: "0024_run_listing", Script: runListing},
+	{ID: "0025_runtime_timeouts", Script: runtimeTimeouts},
+	{ID: "0026_superseded_runs", Script: supersededRuns},
+	{ID: "0027_run_request_hash", Script: runRequestHash},
 }
@@ -3,6 +3,7 @@ package state
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"time"
@@ -13,12 +14,16 @@ func (s *Store) CreateRun(ctx context.Context, run Run) (Run, error) {
 	if run.State == "" {
 		run.State = RunStateCreated
 	}
+	parameters, err := encodeRunParameters(run.Parameters)
+	if err != nil {
+		return Run{}, err
+	}
 
-	err := s.db.QueryRowContext(ctx, `
-INSERT INTO runs (id, repo_id, ref, commit_sha, state)
-VALUES ($1, $2, $3, $4, $5)
+	err = s.db.QueryRowContext(ctx, `
+INSERT INTO runs (id, repo_id, repo_url, ref, commit_sha, full_run, parameters, state)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 RETURNING created_at, updated_at
-`, run.ID, run.RepoID, run.Ref, run.CommitSHA, run.State).Scan(&run.CreatedAt, &run.UpdatedAt)
+`, run.ID, run.RepoID, run.RepoURL, run.Ref, run.CommitSHA, run.FullRun, parameters, run.State).Scan(&run.CreatedAt, &run.UpdatedAt)
 	if err != nil {
 		return Run{}, err
 	}
@@ -29,20 +34,40 @@ RETURNING created_at, updated_at
 // GetRun returns a single run by ID.
 func (s *Store) GetRun(ctx context.Context, runID string) (Run, error) {
 	var run Run
+	var parameters []byte
+	var timeoutRequestedAt sql.NullTime
+	var supersededBy sql.NullString
 	err := s.db.QueryRowContext(ctx, `
-SELECT id, repo_id, ref, commit_sha, state, created_at, updated_at
+SELECT id, repo_id, repo_url, ref, commit_sha, full_run, parameters, state, timeout_requested_at, superseded_by, created_at, updated_at
 FROM runs
 WHERE id = $1
-`, runID).Scan(&run.ID, &run.RepoID, &run.Ref, &run.CommitSHA, &run.State, &run.CreatedAt, &run.UpdatedAt)
+`, runID).Scan(&run.ID, &run.RepoID, &run.RepoURL, &run.Ref, &run.CommitSHA, &run.FullRun, &parameters, &run.State, &timeoutRequestedAt, &supersededBy, &run.CreatedAt, &run.UpdatedAt)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return Run{}, fmt.Errorf("%w: run %s", ErrNotFound, runID)
 		}
 		return Run{}, err
 	}
+	if err := json.Unmarshal(parameters, &run.Parameters); err != nil {
+		return Run{}, fmt.Errorf("decode run parameters: %w", err)
+	}
+	if timeoutRequestedAt.Valid {
+		run.TimeoutRequestedAt = &timeoutRequestedAt.Time
+	}
+	if supersededBy.Valid {
+		run.SupersededBy = &supersededBy.String
+	}
 	return run, nil
 }
 
+// encodeRunParameters stores nil parameters as an empty object.
+func encodeRunParameters(parameters map[string]string) ([]byte, error) {
+	if parameters == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(parameters)
+}
+
 // GetJob returns a single job by ID.
 func (s *Store) GetJob(ctx context.Context, jobID string) (Job, error) {
 	var job Job
@@ -146,7 +171,7 @@ WHERE id = $1
 // ListJobAttempts returns all attempts for a job ordered by attempt_number.
 func (s *Store) ListJobAttempts(ctx context.Context, jobID string) ([]JobAttempt, error) {
 	rows, err := s.db.QueryContext(ctx, `
-SELECT id, job_id, attempt_number, state, lease_id, created_at, updated_at, started_at, completed_at
+SELECT id, job_id, attempt_number, state, lease_id, created_at, updated_at, started_at, completed_at, timeout_requested_at, progress
 FROM job_attempts
 WHERE job_id = $1
 ORDER BY attempt_number ASC
@@ -162,7 +187,9 @@ ORDER BY attempt_number ASC
 		var leaseID sql.NullString
 		var startedAt sql.NullTime
 		var completedAt sql.NullTime
-		if err := rows.Scan(&attempt.ID, &attempt.JobID, &attempt.AttemptNumber, &attempt.State, &leaseID, &attempt.CreatedAt, &attempt.UpdatedAt, &startedAt, &completedAt); err != nil {
+		var timeoutRequestedAt sql.NullTime
+		var progress []byte
+		if err := rows.Scan(&attempt.ID, &attempt.JobID, &attempt.AttemptNumber, &attempt.State, &leaseID, &attempt.CreatedAt, &attempt.UpdatedAt, &startedAt, &completedAt, &timeoutRequestedAt, &progress); err != nil {
 			return nil, err
 		}
 		if leaseID.Valid {
@@ -174,6 +201,12 @@ ORDER BY attempt_number ASC
 		if completedAt.Valid {
 			attempt.CompletedAt = &completedAt.Time
 		}
+		if timeoutRequestedAt.Valid {
+			attempt.TimeoutRequestedAt = &timeoutRequestedAt.Time
+		}
+		if err := decodeProgress(progress, &attempt); err != nil {
+			return nil, err
+		}
 		attempts = append(attempts, attempt)
 	}
 
@@ -183,7 +216,7 @@ ORDER BY attempt_number ASC
 // GetJobAttempt returns a single attempt by ID.
 func (s *Store) GetJobAttempt(ctx context.Context, attemptID string) (JobAttempt, error) {
 	row := s.db.QueryRowContext(ctx, `
-SELECT id, job_id, attempt_number, state, lease_id, created_at, updated_at, started_at, completed_at
+SELECT id, job_id, attempt_number, state, lease_id, created_at, updated_at, started_at, completed_at, timeout_requested_at, progress
 FROM job_attempts
 WHERE id = $1
 `, attemptID)
@@ -192,7 +225,9 @@ WHERE id = $1
 	var leaseID sql.NullString
 	var startedAt sql.NullTime
 	var completedAt sql.NullTime
-	if err := row.Scan(&attempt.ID, &attempt.JobID, &attempt.AttemptNumber, &attempt.State, &leaseID, &attempt.CreatedAt, &attempt.UpdatedAt, &startedAt, &completedAt); err != nil {
+	var timeoutRequestedAt sql.NullTime
+	var progress []byte
+	if err := row.Scan(&attempt.ID, &attempt.JobID, &attempt.AttemptNumber, &attempt.State, &leaseID, &attempt.CreatedAt, &attempt.UpdatedAt, &startedAt, &completedAt, &timeoutRequestedAt, &progress); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return JobAttempt{}, fmt.Errorf("%w: job attempt %s", ErrNotFound, attemptID)
 		}
@@ -207,13 +242,19 @@ WHERE id = $1
 	if completedAt.Valid {
 		attempt.CompletedAt = &completedAt.Time
 	}
+	if timeoutRequestedAt.Valid {
+		attempt.TimeoutRequestedAt = &timeoutRequestedAt.Time
+	}
+	if err := decodeProgress(progress, &attempt); err != nil {
+		return JobAttempt{}, err
+	}
 	return attempt, nil
 }
 
 // GetLatestJobAttempt returns the most recent attempt for a job.
 func (s *Store) GetLatestJobAttempt(ctx context.Context, jobID string) (JobAttempt, error) {
 	row := s.db.QueryRowContext(ctx, `
-SELECT id, job_id, attempt_number, state, lease_id, created_at, updated_at, started_at, completed_at
+SELECT id, job_id, attempt_number, state, lease_id, created_at, updated_at, started_at, completed_at, timeout_requested_at, progress
 FROM job_attempts
 WHERE job_id = $1
 ORDER BY attempt_number DESC
@@ -224,7 +265,9 @@ LIMIT 1
 	var leaseID sql.NullString
 	var startedAt sql.NullTime
 	var completedAt sql.NullTime
-	if err := row.Scan(&attempt.ID, &attempt.JobID, &attempt.AttemptNumber, &attempt.State, &leaseID, &attempt.CreatedAt, &attempt.UpdatedAt, &startedAt, &completedAt); err != nil {
+	var timeoutRequestedAt sql.NullTime
+	var progress []byte
+	if err := row.Scan(&attempt.ID, &attempt.JobID, &attempt.AttemptNumber, &attempt.State, &leaseID, &attempt.CreatedAt, &attempt.UpdatedAt, &startedAt, &completedAt, &timeoutRequestedAt, &progress); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return JobAttempt{}, fmt.Errorf("%w: job attempt %s", ErrNotFound, jobID)
 		}
@@ -239,6 +282,12 @@ LIMIT 1
 	if completedAt.Valid {
 		attempt.CompletedAt = &completedAt.Time
 	}
+	if timeoutRequestedAt.Valid {
+		attempt.TimeoutRequestedAt = &timeoutRequestedAt.Time
+	}
+	if err := decodeProgress(progress, &attempt); err != nil {
+		return JobAttempt{}, err
+	}
 	return attempt, nil
 }
 
@@ -321,6 +370,32 @@ WHERE id = $1
 	return lease, nil
 }
 
+// UpdateJobAttemptProgress stores the latest heartbeat progress.
+func (s *Store) UpdateJobAttemptProgress(ctx context.Context, attemptID string, progress JobProgress) error {
+	encoded, err := json.Marshal(progress)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, `
+UPDATE job_attempts
+SET progress = $2
+WHERE id = $1
+`, attemptID, encoded)
+	return err
+}
+
+func decodeProgress(raw []byte, attempt *JobAttempt) error {
+	if len(raw) == 0 {
+		return nil
+	}
+	var progress JobProgress
+	if err := json.Unmarshal(raw, &progress); err != nil {
+		return err
+	}
+	attempt.Progress = &progress
+	return nil
+}
+
 // MarkJobAttemptStarted sets the started_at timestamp.
 func (s *Store) MarkJobAttemptStarted(ctx context.Context, attemptID string, started time.Time) error {
 	_, err := s.db.ExecContext(ctx, `
@@ -356,10 +431,10 @@ func (s *Store) RecordArtifacts(ctx context.Context, attemptID string, refs []Ar
 				return errors.New("artifact refs require type and uri")
 			}
 			if _, err := tx.ExecContext(ctx, `
-INSERT INTO job_artifacts (job_attempt_id, artifact_type, uri)
-VALUES ($1, $2, $3)
+INSERT INTO job_artifacts (job_attempt_id, artifact_type, uri, size_bytes, sha256)
+VALUES ($1, $2, $3, $4, $5)
 ON CONFLICT (job_attempt_id, uri) DO NOTHING
-`, attemptID, ref.Type, ref.URI); err != nil {
+`, attemptID, ref.Type, ref.URI, ref.SizeBytes, ref.SHA256); err != nil {
 				return err
 			}
 		}
@@ -370,7 +445,7 @@ ON CONFLICT (job_attempt_id, uri) DO NOTHING
 // ListArtifactsByJob returns all artifact references for a job across attempts.
 func (s *Store) ListArtifactsByJob(ctx context.Context, jobID string) ([]Artifact, error) {
 	rows, err := s.db.QueryContext(ctx, `
-SELECT a.id, a.job_attempt_id, a.artifact_type, a.uri, a.created_at
+SELECT a.id, a.job_attempt_id, a.artifact_type, a.uri, a.size_bytes, a.sha256, a.created_at
 FROM job_artifacts a
 JOIN job_attempts ja ON ja.id = a.job_attempt_id
 WHERE ja.job_id = $1
@@ -384,7 +459,7 @@ ORDER BY a.created_at ASC, a.id ASC
 	var artifacts []Artifact
 	for rows.Next() {
 		var artifact Artifact
-		if err := rows.Scan(&artifact.ID, &artifact.JobAttemptID, &artifact.Type, &artifact.URI, &artifact.CreatedAt); err != nil {
+		if err := rows.Scan(&artifact.ID, &artifact.JobAttemptID, &artifact.Type, &artifact.URI, &artifact.SizeBytes, &artifact.SHA256, &artifact.CreatedAt); err != nil {
 			return nil, err
 		}
 		artifacts = append(artifacts, artifact)
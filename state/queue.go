@@ -3,6 +3,7 @@ package state
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"time"
@@ -32,7 +33,16 @@ DO UPDATE SET available_at = EXCLUDED.available_at,
 }
 
 // DequeueJobAttempt returns the next available attempt ID and bumps its visibility window.
-func (s *Store) DequeueJobAttempt(ctx context.Context, now time.Time, visibilityTimeout time.Duration) (string, error) {
+// Only attempts whose job spec runs_on labels are all contained in labels are
+// considered.
+func (s *Store) DequeueJobAttempt(ctx context.Context, now time.Time, visibilityTimeout time.Duration, labels []string) (string, error) {
+	if labels == nil {
+		labels = []string{}
+	}
+	labelsJSON, err := json.Marshal(labels)
+	if err != nil {
+		return "", err
+	}
 	if now.IsZero() {
 		now = time.Now().UTC()
 	}
@@ -55,7 +65,7 @@ WHERE q.attempt_id = a.id
 	}
 
 	var attemptID string
-	err := s.withTx(ctx, func(tx *sql.Tx) error {
+	err = s.withTx(ctx, func(tx *sql.Tx) error {
 		row := tx.QueryRowContext(ctx, `
 SELECT q.attempt_id
 FROM job_queue q
@@ -66,10 +76,11 @@ WHERE q.available_at <= $1
   AND (q.inflight_until IS NULL OR q.inflight_until <= $1)
   AND a.state = 'QUEUED'
   AND r.state NOT IN ('SUCCESS', 'FAILED', 'CANCELED', 'TIMEOUT', 'REPORTED', 'PLAN_FAILED', 'CANCEL_REQUESTED')
+  AND COALESCE((SELECT js.spec_json->'runs_on' FROM job_specs js WHERE js.job_id = j.id), '[]'::jsonb) <@ $2::jsonb
 ORDER BY q.available_at ASC, q.attempt_id ASC
 FOR UPDATE SKIP LOCKED
 LIMIT 1
-`, now)
+`, now, string(labelsJSON))
 
 		if err := row.Scan(&attemptID); err != nil {
 			if errors.Is(err, sql.ErrNoRows) {
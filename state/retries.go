@@ -0,0 +1,84 @@
+package state
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// FinishJobAttempt moves a failed attempt to final (FAILED or TIMED_OUT) and
+// its job along with it. When next is set and the run is still QUEUED or
+// RUNNING, the job goes straight back to QUEUED with next inserted as its new
+// QUEUED attempt instead. Both happen in one transaction holding the run row,
+// so no reader sees the job failed while its retry is pending and no retry is
+// queued under a run that already finished. It reports whether next was
+// queued.
+func (s *Store) FinishJobAttempt(ctx context.Context, attemptID string, final JobState, next *JobAttempt) (bool, error) {
+	var retried bool
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		retried, err = finishJobAttempt(ctx, tx, attemptID, final, next)
+		return err
+	})
+	return retried, err
+}
+
+func finishJobAttempt(ctx context.Context, tx *sql.Tx, attemptID string, final JobState, next *JobAttempt) (bool, error) {
+	var jobID string
+	var attemptState, jobState JobState
+	var runState RunState
+	if err := tx.QueryRowContext(ctx, `
+SELECT a.job_id, a.state, j.state, r.state
+FROM job_attempts a
+JOIN jobs j ON j.id = a.job_id
+JOIN runs r ON r.id = j.run_id
+WHERE a.id = $1
+FOR UPDATE
+`, attemptID).Scan(&jobID, &attemptState, &jobState, &runState); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, fmt.Errorf("%w: job attempt %s", ErrNotFound, attemptID)
+		}
+		return false, err
+	}
+	if err := validateJobTransition(attemptID, attemptState, final); err != nil {
+		return false, err
+	}
+	if err := validateJobTransition(jobID, jobState, final); err != nil {
+		return false, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE job_attempts SET state = $2, updated_at = NOW() WHERE id = $1`, attemptID, final); err != nil {
+		return false, err
+	}
+
+	retry := next != nil && (runState == RunStateQueued || runState == RunStateRunning)
+	jobNext := final
+	if retry {
+		if err := validateJobTransition(jobID, final, JobStateQueued); err != nil {
+			return false, err
+		}
+		jobNext = JobStateQueued
+		next.JobID = jobID
+		next.State = JobStateQueued
+		if err := tx.QueryRowContext(ctx, `
+INSERT INTO job_attempts (id, job_id, attempt_number, state)
+VALUES ($1, $2, $3, $4)
+RETURNING created_at, updated_at
+`, next.ID, next.JobID, next.AttemptNumber, next.State).Scan(&next.CreatedAt, &next.UpdatedAt); err != nil {
+			return false, err
+		}
+		if _, err := tx.ExecContext(ctx, `
+UPDATE jobs
+SET attempt_count = GREATEST(attempt_count, $2), updated_at = NOW()
+WHERE id = $1
+`, jobID, next.AttemptNumber); err != nil {
+			return false, err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE jobs SET state = $2, updated_at = NOW() WHERE id = $1`, jobID, jobNext); err != nil {
+		return false, err
+	}
+	return retry, nil
+}
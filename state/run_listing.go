@@ -0,0 +1,161 @@
+package state
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// RunFilter narrows ListRuns. Zero values do not filter.
+type RunFilter struct {
+	RepoID        string
+	Ref           string
+	CommitSHA     string
+	State         RunState
+	PRNumber      int
+	TriggerType   string
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	// After continues a listing below the given (created_at, id) position.
+	After *RunCursor
+}
+
+// RunCursor is a position in the newest-first run listing.
+type RunCursor struct {
+	CreatedAt time.Time
+	ID        string
+}
+
+// ListRuns returns runs matching filter, newest first, ordered by
+// (created_at, id) so pages stay stable while new runs arrive.
+func (s *Store) ListRuns(ctx context.Context, filter RunFilter, limit int) ([]RunSummary, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+
+	var conds []string
+	var args []any
+	arg := func(value any) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if filter.RepoID != "" {
+		conds = append(conds, "r.repo_id = "+arg(filter.RepoID))
+	}
+	if filter.Ref != "" {
+		conds = append(conds, "r.ref = "+arg(filter.Ref))
+	}
+	if filter.CommitSHA != "" {
+		conds = append(conds, "r.commit_sha = "+arg(filter.CommitSHA))
+	}
+	if filter.State != "" {
+		conds = append(conds, "r.state = "+arg(string(filter.State)))
+	}
+	if filter.PRNumber > 0 {
+		conds = append(conds, "t.pr_number = "+arg(filter.PRNumber))
+	}
+	if filter.TriggerType != "" {
+		conds = append(conds, runTriggerTypeExpr+" = "+arg(filter.TriggerType))
+	}
+	if !filter.CreatedAfter.IsZero() {
+		conds = append(conds, "r.created_at >= "+arg(filter.CreatedAfter))
+	}
+	if !filter.CreatedBefore.IsZero() {
+		conds = append(conds, "r.created_at < "+arg(filter.CreatedBefore))
+	}
+	if filter.After != nil {
+		conds = append(conds, fmt.Sprintf("(r.created_at, r.id) < (%s, %s)", arg(filter.After.CreatedAt), arg(filter.After.ID)))
+	}
+
+	where := ""
+	if len(conds) > 0 {
+		where = "WHERE " + strings.Join(conds, "\n  AND ")
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+SELECT r.id, r.repo_id, r.repo_url, r.ref, r.commit_sha, r.full_run, r.parameters, r.state, r.timeout_requested_at, r.superseded_by, r.created_at, r.updated_at,
+       `+runTriggerTypeExpr+`, t.pr_number,
+       jc.total, jc.pending, jc.running, jc.succeeded, jc.failed, jc.canceled
+FROM runs r
+LEFT JOIN run_triggers t ON t.run_id = r.id
+LEFT JOIN run_reruns rr ON rr.new_run_id = r.id
+LEFT JOIN run_requests rq ON rq.run_id = r.id
+CROSS JOIN LATERAL (
+  SELECT COUNT(*) AS total,
+         COUNT(*) FILTER (WHERE j.state IN ('CREATED', 'QUEUED')) AS pending,
+         COUNT(*) FILTER (WHERE j.state IN ('LEASED', 'STARTING', 'RUNNING', 'UPLOADING', 'CANCEL_REQUESTED')) AS running,
+         COUNT(*) FILTER (WHERE j.state = 'SUCCEEDED') AS succeeded,
+         COUNT(*) FILTER (WHERE j.state IN ('FAILED', 'TIMED_OUT', 'STALE')) AS failed,
+         COUNT(*) FILTER (WHERE j.state = 'CANCELED') AS canceled
+  FROM jobs j
+  WHERE j.run_id = r.id
+) jc
+`+where+`
+ORDER BY r.created_at DESC, r.id DESC
+LIMIT `+arg(limit), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var runs []RunSummary
+	for rows.Next() {
+		var summary RunSummary
+		var parameters []byte
+		var prNumber sql.NullInt64
+		var timeoutRequestedAt sql.NullTime
+		var supersededBy sql.NullString
+		if err := rows.Scan(
+			&summary.ID,
+			&summary.RepoID,
+			&summary.RepoURL,
+			&summary.Ref,
+			&summary.CommitSHA,
+			&summary.FullRun,
+			&parameters,
+			&summary.State,
+			&timeoutRequestedAt,
+			&supersededBy,
+			&summary.CreatedAt,
+			&summary.UpdatedAt,
+			&summary.TriggerType,
+			&prNumber,
+			&summary.JobCounts.Total,
+			&summary.JobCounts.Pending,
+			&summary.JobCounts.Running,
+			&summary.JobCounts.Succeeded,
+			&summary.JobCounts.Failed,
+			&summary.JobCounts.Canceled,
+		); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(parameters, &summary.Parameters); err != nil {
+			return nil, fmt.Errorf("decode run parameters: %w", err)
+		}
+		if timeoutRequestedAt.Valid {
+			summary.TimeoutRequestedAt = &timeoutRequestedAt.Time
+		}
+		if supersededBy.Valid {
+			summary.SupersededBy = &supersededBy.String
+		}
+		if prNumber.Valid {
+			value := int(prNumber.Int64)
+			summary.PRNumber = &value
+		}
+		runs = append(runs, summary)
+	}
+	return runs, rows.Err()
+}
+
+// runTriggerTypeExpr derives how a run was created: the webhook event type
+// ("push", "pull_request"), "rerun", "manual" (POST /api/v1/runs) or "other".
+const runTriggerTypeExpr = `CASE
+         WHEN t.run_id IS NOT NULL THEN t.event_type
+         WHEN rr.new_run_id IS NOT NULL THEN 'rerun'
+         WHEN rq.run_id IS NOT NULL THEN 'manual'
+         ELSE 'other'
+       END`
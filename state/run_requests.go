@@ -0,0 +1,122 @@
+package state
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrDuplicateRunRequest indicates a run request idempotency key already exists.
+	ErrDuplicateRunRequest = errors.New("state: duplicate run request")
+	// ErrIdempotencyKeyReused indicates an idempotency key was sent again with a
+	// different request body.
+	ErrIdempotencyKeyReused = errors.New("state: idempotency key reused with a different request")
+)
+
+// CreateRunWithRequest creates a manually requested run. A non-empty
+// idempotency key returns the run created earlier with the same key instead,
+// provided the request matches; a different request fails with
+// ErrIdempotencyKeyReused.
+func (s *Store) CreateRunWithRequest(ctx context.Context, run Run, idempotencyKey string) (Run, bool, error) {
+	if run.State == "" {
+		run.State = RunStateCreated
+	}
+	parameters, err := encodeRunParameters(run.Parameters)
+	if err != nil {
+		return Run{}, false, err
+	}
+	requestHash, err := runRequestHash(run)
+	if err != nil {
+		return Run{}, false, err
+	}
+
+	var key any
+	if idempotencyKey != "" {
+		key = idempotencyKey
+	}
+
+	err = s.withTx(ctx, func(tx *sql.Tx) error {
+		if err := tx.QueryRowContext(ctx, `
+INSERT INTO runs (id, repo_id, repo_url, ref, commit_sha, full_run, parameters, state)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+RETURNING created_at, updated_at
+`, run.ID, run.RepoID, run.RepoURL, run.Ref, run.CommitSHA, run.FullRun, parameters, run.State).Scan(&run.CreatedAt, &run.UpdatedAt); err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, `
+INSERT INTO run_requests (run_id, idempotency_key, request_hash)
+VALUES ($1, $2, $3)
+`, run.ID, key, requestHash); err != nil {
+			if isUniqueViolation(err) {
+				return ErrDuplicateRunRequest
+			}
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, ErrDuplicateRunRequest) {
+			existing, existingHash, err := s.getRunRequestByKey(ctx, idempotencyKey)
+			if err != nil {
+				return Run{}, false, err
+			}
+			// Requests stored before hashes were recorded are trusted.
+			if existingHash.Valid && existingHash.String != requestHash {
+				return Run{}, false, fmt.Errorf("%w: %s", ErrIdempotencyKeyReused, idempotencyKey)
+			}
+			existingRun, err := s.GetRun(ctx, existing)
+			if err != nil {
+				return Run{}, false, err
+			}
+			return existingRun, false, nil
+		}
+		return Run{}, false, err
+	}
+
+	return run, true, nil
+}
+
+func (s *Store) getRunRequestByKey(ctx context.Context, idempotencyKey string) (string, sql.NullString, error) {
+	var runID string
+	var requestHash sql.NullString
+	err := s.db.QueryRowContext(ctx, `
+SELECT run_id, request_hash
+FROM run_requests
+WHERE idempotency_key = $1
+`, idempotencyKey).Scan(&runID, &requestHash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", sql.NullString{}, fmt.Errorf("%w: run request %s", ErrNotFound, idempotencyKey)
+		}
+		return "", sql.NullString{}, err
+	}
+	return runID, requestHash, nil
+}
+
+// runRequestHash fingerprints the fields of a manual run request that decide
+// which run it creates.
+func runRequestHash(run Run) (string, error) {
+	parameters := run.Parameters
+	if parameters == nil {
+		parameters = map[string]string{}
+	}
+	data, err := json.Marshal(struct {
+		RepoID     string            `json:"repo_id"`
+		RepoURL    string            `json:"repo_url"`
+		Ref        string            `json:"ref"`
+		CommitSHA  string            `json:"commit_sha"`
+		FullRun    bool              `json:"full_run"`
+		Parameters map[string]string `json:"parameters"`
+	}{run.RepoID, run.RepoURL, run.Ref, run.CommitSHA, run.FullRun, parameters})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -19,12 +19,17 @@ func (s *Store) CreateRunWithRerun(ctx context.Context, run Run, originalRunID,
 		return Run{}, false, errors.New("original_run_id and idempotency_key required")
 	}
 
-	err := s.withTx(ctx, func(tx *sql.Tx) error {
+	parameters, err := encodeRunParameters(run.Parameters)
+	if err != nil {
+		return Run{}, false, err
+	}
+
+	err = s.withTx(ctx, func(tx *sql.Tx) error {
 		if err := tx.QueryRowContext(ctx, `
-INSERT INTO runs (id, repo_id, ref, commit_sha, state)
-VALUES ($1, $2, $3, $4, $5)
+INSERT INTO runs (id, repo_id, repo_url, ref, commit_sha, full_run, parameters, state)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 RETURNING created_at, updated_at
-`, run.ID, run.RepoID, run.Ref, run.CommitSHA, run.State).Scan(&run.CreatedAt, &run.UpdatedAt); err != nil {
+`, run.ID, run.RepoID, run.RepoURL, run.Ref, run.CommitSHA, run.FullRun, parameters, run.State).Scan(&run.CreatedAt, &run.UpdatedAt); err != nil {
 			return err
 		}
 
@@ -0,0 +1,248 @@
+package state
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// UnroutableAttempt is a queued attempt whose runs_on labels no active runner has.
+type UnroutableAttempt struct {
+	AttemptID string
+	JobID     string
+	RunID     string
+	RunsOn    []string
+}
+
+const runnerColumns = `id, version, labels, state, current_lease_id, expired_leases, last_seen_at, created_at, updated_at`
+
+// RegisterRunner records that a runner polled for work, creating it on first
+// contact. An OFFLINE runner that polls again comes back IDLE; a DRAINING one
+// stays draining.
+func (s *Store) RegisterRunner(ctx context.Context, runnerID, version string, labels []string, seenAt time.Time) (Runner, error) {
+	if runnerID == "" {
+		return Runner{}, errors.New("runner id required")
+	}
+	if seenAt.IsZero() {
+		seenAt = time.Now().UTC()
+	}
+	if labels == nil {
+		labels = []string{}
+	}
+	labelsJSON, err := json.Marshal(labels)
+	if err != nil {
+		return Runner{}, err
+	}
+
+	row := s.db.QueryRowContext(ctx, `
+INSERT INTO runners (id, version, labels, last_seen_at)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (id)
+DO UPDATE SET version = EXCLUDED.version,
+              labels = EXCLUDED.labels,
+              last_seen_at = GREATEST(runners.last_seen_at, EXCLUDED.last_seen_at),
+              state = CASE WHEN runners.state = 'OFFLINE' THEN 'IDLE' ELSE runners.state END,
+              updated_at = NOW()
+RETURNING `+runnerColumns, runnerID, version, labelsJSON, seenAt)
+	return scanRunner(row)
+}
+
+// GetRunner returns a registered runner.
+func (s *Store) GetRunner(ctx context.Context, runnerID string) (Runner, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT `+runnerColumns+` FROM runners WHERE id = $1`, runnerID)
+	runner, err := scanRunner(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Runner{}, fmt.Errorf("%w: runner %s", ErrNotFound, runnerID)
+	}
+	return runner, err
+}
+
+// ListRunners returns all registered runners ordered by ID.
+func (s *Store) ListRunners(ctx context.Context) ([]Runner, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+runnerColumns+` FROM runners ORDER BY id ASC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var runners []Runner
+	for rows.Next() {
+		runner, err := scanRunner(rows)
+		if err != nil {
+			return nil, err
+		}
+		runners = append(runners, runner)
+	}
+	return runners, rows.Err()
+}
+
+// SetRunnerState moves a runner to DRAINING or OFFLINE. The current lease is
+// kept; callers taking a runner offline revoke it with RevokeLease.
+func (s *Store) SetRunnerState(ctx context.Context, runnerID string, next RunnerState) (Runner, error) {
+	switch next {
+	case RunnerStateDraining, RunnerStateOffline:
+	default:
+		return Runner{}, UnknownStateError{Entity: "runner", State: string(next)}
+	}
+
+	row := s.db.QueryRowContext(ctx, `
+UPDATE runners
+SET state = $2,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING `+runnerColumns, runnerID, string(next))
+	runner, err := scanRunner(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Runner{}, fmt.Errorf("%w: runner %s", ErrNotFound, runnerID)
+	}
+	return runner, err
+}
+
+// AssignRunnerLease marks a runner BUSY with leaseID. Draining runners keep
+// their state. Unregistered runners are ignored.
+func (s *Store) AssignRunnerLease(ctx context.Context, runnerID, leaseID string, seenAt time.Time) error {
+	_, err := s.db.ExecContext(ctx, `
+UPDATE runners
+SET current_lease_id = $2,
+    state = CASE WHEN state = 'DRAINING' THEN state ELSE 'BUSY' END,
+    last_seen_at = GREATEST(last_seen_at, $3),
+    updated_at = NOW()
+WHERE id = $1
+`, runnerID, leaseID, seenAt)
+	return err
+}
+
+// MarkRunnerSeen bumps a runner's last-seen time, e.g. on heartbeat.
+func (s *Store) MarkRunnerSeen(ctx context.Context, runnerID string, seenAt time.Time) error {
+	_, err := s.db.ExecContext(ctx, `
+UPDATE runners
+SET last_seen_at = GREATEST(last_seen_at, $2),
+    updated_at = NOW()
+WHERE id = $1
+`, runnerID, seenAt)
+	return err
+}
+
+// ReleaseRunnerLease clears leaseID from its runner once the lease finishes,
+// returning a BUSY runner to IDLE.
+func (s *Store) ReleaseRunnerLease(ctx context.Context, runnerID, leaseID string) error {
+	_, err := s.db.ExecContext(ctx, `
+UPDATE runners
+SET current_lease_id = NULL,
+    state = CASE WHEN state = 'BUSY' THEN 'IDLE' ELSE state END,
+    updated_at = NOW()
+WHERE id = $1
+  AND current_lease_id = $2
+`, runnerID, leaseID)
+	return err
+}
+
+// MarkSilentRunnersOffline marks runners not seen since seenBefore OFFLINE and
+// returns their IDs.
+func (s *Store) MarkSilentRunnersOffline(ctx context.Context, seenBefore time.Time) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `
+UPDATE runners
+SET state = 'OFFLINE',
+    current_lease_id = NULL,
+    updated_at = NOW()
+WHERE state <> 'OFFLINE'
+  AND last_seen_at < $1
+RETURNING id
+`, seenBefore)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// ListUnroutableAttempts returns queued attempts that have been available since
+// before queuedBefore and whose runs_on labels are not all held by any online
+// runner seen since seenSince. Nothing is returned while no runner has been
+// seen, so an idle fleet does not fail every labelled job.
+func (s *Store) ListUnroutableAttempts(ctx context.Context, queuedBefore, seenSince time.Time, limit int) ([]UnroutableAttempt, error) {
+	if limit <= 0 {
+		limit = 10
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+SELECT a.id, a.job_id, j.run_id, js.spec_json->'runs_on'
+FROM job_queue q
+JOIN job_attempts a ON a.id = q.attempt_id
+JOIN jobs j ON j.id = a.job_id
+JOIN job_specs js ON js.job_id = j.id
+WHERE a.state = 'QUEUED'
+  AND q.available_at <= $1
+  AND jsonb_typeof(js.spec_json->'runs_on') = 'array'
+  AND jsonb_array_length(js.spec_json->'runs_on') > 0
+  AND EXISTS (SELECT 1 FROM runners WHERE last_seen_at >= $2 AND state <> 'OFFLINE')
+  AND NOT EXISTS (
+    SELECT 1 FROM runners rn
+    WHERE rn.last_seen_at >= $2
+      AND rn.state <> 'OFFLINE'
+      AND rn.labels @> js.spec_json->'runs_on'
+  )
+ORDER BY q.available_at ASC, a.id ASC
+LIMIT $3
+`, queuedBefore, seenSince, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var attempts []UnroutableAttempt
+	for rows.Next() {
+		var attempt UnroutableAttempt
+		var runsOn []byte
+		if err := rows.Scan(&attempt.AttemptID, &attempt.JobID, &attempt.RunID, &runsOn); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(runsOn, &attempt.RunsOn); err != nil {
+			return nil, err
+		}
+		attempts = append(attempts, attempt)
+	}
+	return attempts, rows.Err()
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanRunner(row rowScanner) (Runner, error) {
+	var runner Runner
+	var labels []byte
+	var leaseID sql.NullString
+	if err := row.Scan(
+		&runner.ID,
+		&runner.Version,
+		&labels,
+		&runner.State,
+		&leaseID,
+		&runner.ExpiredLeases,
+		&runner.LastSeenAt,
+		&runner.RegisteredAt,
+		&runner.UpdatedAt,
+	); err != nil {
+		return Runner{}, err
+	}
+	if err := json.Unmarshal(labels, &runner.Labels); err != nil {
+		return Runner{}, err
+	}
+	if leaseID.Valid {
+		runner.CurrentLeaseID = &leaseID.String
+	}
+	return runner, nil
+}
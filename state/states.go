@@ -25,9 +25,9 @@ var runTransitions = map[RunState][]RunState{
 	RunStateCreated:         {RunStateCreated, RunStatePlanning},
 	RunStatePlanning:        {RunStatePlanning, RunStateQueued, RunStatePlanFailed},
 	RunStatePlanFailed:      {RunStatePlanFailed, RunStateFailed},
-	RunStateQueued:          {RunStateQueued, RunStateRunning, RunStateCancelRequested},
+	RunStateQueued:          {RunStateQueued, RunStateRunning, RunStateCancelRequested, RunStateSuccess, RunStateFailed},
 	RunStateRunning:         {RunStateRunning, RunStateSuccess, RunStateFailed, RunStateCancelRequested, RunStateTimeout},
-	RunStateCancelRequested: {RunStateCancelRequested, RunStateCanceled},
+	RunStateCancelRequested: {RunStateCancelRequested, RunStateCanceled, RunStateTimeout},
 	RunStateSuccess:         {RunStateSuccess, RunStateReported},
 	RunStateFailed:          {RunStateFailed, RunStateReported},
 	RunStateCanceled:        {RunStateCanceled, RunStateReported},
@@ -54,14 +54,14 @@ const (
 
 var jobTransitions = map[JobState][]JobState{
 	JobStateCreated:         {JobStateCreated, JobStateQueued},
-	JobStateQueued:          {JobStateQueued, JobStateLeased, JobStateCancelRequested},
+	JobStateQueued:          {JobStateQueued, JobStateLeased, JobStateCancelRequested, JobStateFailed},
 	JobStateLeased:          {JobStateLeased, JobStateStarting, JobStateQueued, JobStateCancelRequested, JobStateStale},
 	JobStateStarting:        {JobStateStarting, JobStateRunning, JobStateQueued, JobStateCancelRequested, JobStateStale},
 	JobStateRunning:         {JobStateRunning, JobStateUploading, JobStateTimedOut, JobStateQueued, JobStateCancelRequested, JobStateStale},
 	JobStateUploading:       {JobStateUploading, JobStateSucceeded, JobStateFailed},
 	JobStateSucceeded:       {JobStateSucceeded},
 	JobStateFailed:          {JobStateFailed, JobStateQueued},
-	JobStateCancelRequested: {JobStateCancelRequested, JobStateCanceled},
+	JobStateCancelRequested: {JobStateCancelRequested, JobStateCanceled, JobStateTimedOut},
 	JobStateCanceled:        {JobStateCanceled},
 	JobStateTimedOut:        {JobStateTimedOut, JobStateQueued},
 	JobStateStale:           {JobStateStale},
@@ -87,6 +87,16 @@ var leaseTransitions = map[LeaseState][]LeaseState{
 	LeaseStateCanceled:  {LeaseStateCanceled},
 }
 
+// RunnerState is a registered runner's availability for new leases.
+type RunnerState string
+
+const (
+	RunnerStateIdle     RunnerState = "IDLE"
+	RunnerStateBusy     RunnerState = "BUSY"
+	RunnerStateDraining RunnerState = "DRAINING"
+	RunnerStateOffline  RunnerState = "OFFLINE"
+)
+
 // TransitionError signals an illegal state transition detected in the persistence layer.
 type TransitionError struct {
 	Entity string
@@ -109,6 +119,12 @@ func (e UnknownStateError) Error() string {
 	return fmt.Sprintf("%s: unknown state %q", e.Entity, e.State)
 }
 
+// ValidRunState reports whether runState is part of the run state machine.
+func ValidRunState(runState RunState) bool {
+	_, ok := runTransitions[runState]
+	return ok
+}
+
 func validateRunTransition(id string, from, to RunState) error {
 	allowed, ok := runTransitions[from]
 	if !ok {
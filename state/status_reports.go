@@ -13,7 +13,7 @@ func (s *Store) GetStatusReport(ctx context.Context, runID, provider string) (St
 	var checkRunID sql.NullString
 	var prCommentID sql.NullString
 	err := s.db.QueryRowContext(ctx, `
-SELECT run_id, provider, check_run_id, pr_comment_id, last_state, created_at, updated_at
+SELECT run_id, provider, check_run_id, pr_comment_id, last_state, last_progress, created_at, updated_at
 FROM vcs_status_reports
 WHERE run_id = $1 AND provider = $2
 `, runID, provider).Scan(
@@ -22,6 +22,7 @@ WHERE run_id = $1 AND provider = $2
 		&checkRunID,
 		&prCommentID,
 		&report.LastState,
+		&report.LastProgress,
 		&report.CreatedAt,
 		&report.UpdatedAt,
 	)
@@ -59,21 +60,23 @@ func (s *Store) UpsertStatusReport(ctx context.Context, report StatusReport) (St
 	var storedCheck sql.NullString
 	var storedComment sql.NullString
 	err := s.db.QueryRowContext(ctx, `
-INSERT INTO vcs_status_reports (run_id, provider, check_run_id, pr_comment_id, last_state)
-VALUES ($1, $2, $3, $4, $5)
+INSERT INTO vcs_status_reports (run_id, provider, check_run_id, pr_comment_id, last_state, last_progress)
+VALUES ($1, $2, $3, $4, $5, $6)
 ON CONFLICT (run_id, provider)
 DO UPDATE SET
 	check_run_id = COALESCE(EXCLUDED.check_run_id, vcs_status_reports.check_run_id),
 	pr_comment_id = COALESCE(EXCLUDED.pr_comment_id, vcs_status_reports.pr_comment_id),
 	last_state = EXCLUDED.last_state,
+	last_progress = EXCLUDED.last_progress,
 	updated_at = NOW()
-RETURNING run_id, provider, check_run_id, pr_comment_id, last_state, created_at, updated_at
-`, report.RunID, report.Provider, checkRunID, prCommentID, report.LastState).Scan(
+RETURNING run_id, provider, check_run_id, pr_comment_id, last_state, last_progress, created_at, updated_at
+`, report.RunID, report.Provider, checkRunID, prCommentID, report.LastState, report.LastProgress).Scan(
 		&stored.RunID,
 		&stored.Provider,
 		&storedCheck,
 		&storedComment,
 		&stored.LastState,
+		&stored.LastProgress,
 		&stored.CreatedAt,
 		&stored.UpdatedAt,
 	)
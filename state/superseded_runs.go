@@ -0,0 +1,62 @@
+package state
+
+import "context"
+
+// ListSupersededRuns returns QUEUED or RUNNING webhook-triggered runs that
+// run makes obsolete: same repository, created earlier for another commit, and
+// for the same pull request when prNumber is set or the same ref otherwise.
+func (s *Store) ListSupersededRuns(ctx context.Context, run Run, prNumber *int) ([]string, error) {
+	match, value := "r.ref = $5", any(run.Ref)
+	if prNumber != nil {
+		match, value = "t.pr_number = $5", *prNumber
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+SELECT r.id
+FROM runs r
+JOIN run_triggers t ON t.run_id = r.id
+WHERE r.repo_id = $1
+  AND r.id <> $2
+  AND r.commit_sha <> $3
+  AND r.created_at <= $4
+  AND r.state IN ('QUEUED', 'RUNNING')
+  AND `+match+`
+ORDER BY r.created_at ASC, r.id ASC
+`, run.RepoID, run.ID, run.CommitSHA, run.CreatedAt, value)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var runIDs []string
+	for rows.Next() {
+		var runID string
+		if err := rows.Scan(&runID); err != nil {
+			return nil, err
+		}
+		runIDs = append(runIDs, runID)
+	}
+	return runIDs, rows.Err()
+}
+
+// MarkRunSuperseded records that supersededBy replaced runID and reports
+// whether it did; runs that already finished, or were already superseded, are
+// left alone.
+func (s *Store) MarkRunSuperseded(ctx context.Context, runID, supersededBy string) (bool, error) {
+	result, err := s.db.ExecContext(ctx, `
+UPDATE runs
+SET superseded_by = $2,
+    updated_at = NOW()
+WHERE id = $1
+  AND superseded_by IS NULL
+  AND state IN ('QUEUED', 'RUNNING')
+`, runID, supersededBy)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows > 0, nil
+}
@@ -0,0 +1,74 @@
+package state
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// TestResult captures a single test outcome reported for a job attempt.
+type TestResult struct {
+	JobAttemptID string `json:"job_attempt_id"`
+	Package      string `json:"package,omitempty"`
+	Name         string `json:"name"`
+	Status       string `json:"status"`
+	DurationMs   int64  `json:"duration_ms"`
+	Output       string `json:"output,omitempty"`
+}
+
+// RecordTestResults persists test results for a job attempt.
+func (s *Store) RecordTestResults(ctx context.Context, attemptID string, results []TestResult) error {
+	if attemptID == "" {
+		return errors.New("attempt id required")
+	}
+	if len(results) == 0 {
+		return nil
+	}
+
+	return s.withTx(ctx, func(tx *sql.Tx) error {
+		stmt, err := tx.PrepareContext(ctx, `
+INSERT INTO job_test_results (job_attempt_id, package, name, status, duration_ms, output)
+VALUES ($1, $2, $3, $4, $5, $6)
+`)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+
+		for _, result := range results {
+			if result.Status == "" {
+				return errors.New("test status required")
+			}
+			if _, err := stmt.ExecContext(ctx, attemptID, result.Package, result.Name, result.Status, result.DurationMs, result.Output); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ListFailedTestsByJob returns failed tests for a job across attempts.
+func (s *Store) ListFailedTestsByJob(ctx context.Context, jobID string) ([]TestResult, error) {
+	rows, err := s.db.QueryContext(ctx, `
+SELECT t.job_attempt_id, t.package, t.name, t.status, t.duration_ms, t.output
+FROM job_test_results t
+JOIN job_attempts ja ON ja.id = t.job_attempt_id
+WHERE ja.job_id = $1 AND t.status = 'FAILED'
+ORDER BY ja.attempt_number ASC, t.id ASC
+`, jobID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []TestResult
+	for rows.Next() {
+		var result TestResult
+		if err := rows.Scan(&result.JobAttemptID, &result.Package, &result.Name, &result.Status, &result.DurationMs, &result.Output); err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+
+	return results, rows.Err()
+}
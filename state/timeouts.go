@@ -0,0 +1,280 @@
+package state
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// OverdueAttempt is a running attempt that exceeded its job timeout.
+type OverdueAttempt struct {
+	AttemptID      string
+	JobID          string
+	RunID          string
+	TimeoutSeconds int
+}
+
+// StalledStop is a CANCEL_REQUESTED attempt whose runner did not stop within
+// the grace period after a job or run timeout.
+type StalledStop struct {
+	AttemptID string
+	JobID     string
+	RunID     string
+	// JobTimedOut is set when the attempt itself exceeded the job timeout, as
+	// opposed to being stopped because its run did.
+	JobTimedOut bool
+}
+
+// ListOverdueAttempts returns STARTING or RUNNING attempts that started more
+// than their job spec's timeout_seconds before now. Jobs without a timeout use
+// defaultTimeout; zero disables the check for them.
+func (s *Store) ListOverdueAttempts(ctx context.Context, now time.Time, defaultTimeout time.Duration, limit int) ([]OverdueAttempt, error) {
+	if limit <= 0 {
+		limit = 10
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+SELECT a.id, a.job_id, j.run_id, t.timeout_seconds
+FROM job_attempts a
+JOIN jobs j ON j.id = a.job_id
+LEFT JOIN job_specs js ON js.job_id = j.id
+CROSS JOIN LATERAL (
+  SELECT COALESCE(NULLIF((js.spec_json->>'timeout_seconds')::int, 0), $2) AS timeout_seconds
+) t
+WHERE a.state IN ('STARTING', 'RUNNING')
+  AND a.timeout_requested_at IS NULL
+  AND a.started_at IS NOT NULL
+  AND t.timeout_seconds > 0
+  AND a.started_at + t.timeout_seconds * INTERVAL '1 second' <= $1
+ORDER BY a.started_at ASC, a.id ASC
+LIMIT $3
+`, now, int(defaultTimeout/time.Second), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var attempts []OverdueAttempt
+	for rows.Next() {
+		var attempt OverdueAttempt
+		if err := rows.Scan(&attempt.AttemptID, &attempt.JobID, &attempt.RunID, &attempt.TimeoutSeconds); err != nil {
+			return nil, err
+		}
+		attempts = append(attempts, attempt)
+	}
+	return attempts, rows.Err()
+}
+
+// RequestJobAttemptTimeout moves a running attempt and its job to
+// CANCEL_REQUESTED and records when the timeout was requested, so the runner
+// is told to stop on its next heartbeat and the attempt ends TIMED_OUT.
+func (s *Store) RequestJobAttemptTimeout(ctx context.Context, attemptID string, now time.Time) error {
+	return s.withTx(ctx, func(tx *sql.Tx) error {
+		var jobID string
+		var attemptState, jobState JobState
+		if err := tx.QueryRowContext(ctx, `
+SELECT a.job_id, a.state, j.state
+FROM job_attempts a
+JOIN jobs j ON j.id = a.job_id
+WHERE a.id = $1
+FOR UPDATE
+`, attemptID).Scan(&jobID, &attemptState, &jobState); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("%w: job attempt %s", ErrNotFound, attemptID)
+			}
+			return err
+		}
+		if err := validateJobTransition(attemptID, attemptState, JobStateCancelRequested); err != nil {
+			return err
+		}
+		if err := validateJobTransition(jobID, jobState, JobStateCancelRequested); err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, `
+UPDATE job_attempts
+SET state = $2,
+    timeout_requested_at = $3,
+    updated_at = NOW()
+WHERE id = $1
+`, attemptID, JobStateCancelRequested, now); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, `UPDATE jobs SET state = $2, updated_at = NOW() WHERE id = $1`, jobID, JobStateCancelRequested)
+		return err
+	})
+}
+
+// ListOverdueRuns returns QUEUED or RUNNING runs created at or before
+// createdBefore that have not been asked to time out yet.
+func (s *Store) ListOverdueRuns(ctx context.Context, createdBefore time.Time, limit int) ([]string, error) {
+	if limit <= 0 {
+		limit = 10
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+SELECT id
+FROM runs
+WHERE state IN ('QUEUED', 'RUNNING')
+  AND timeout_requested_at IS NULL
+  AND created_at <= $1
+ORDER BY created_at ASC, id ASC
+LIMIT $2
+`, createdBefore, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var runIDs []string
+	for rows.Next() {
+		var runID string
+		if err := rows.Scan(&runID); err != nil {
+			return nil, err
+		}
+		runIDs = append(runIDs, runID)
+	}
+	return runIDs, rows.Err()
+}
+
+// RequestRunTimeout moves a run to CANCEL_REQUESTED and records when the
+// timeout was requested, so the run ends TIMEOUT once its jobs have stopped.
+func (s *Store) RequestRunTimeout(ctx context.Context, runID string, now time.Time) error {
+	return s.withTx(ctx, func(tx *sql.Tx) error {
+		var current RunState
+		if err := tx.QueryRowContext(ctx, `SELECT state FROM runs WHERE id = $1 FOR UPDATE`, runID).Scan(&current); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("%w: run %s", ErrNotFound, runID)
+			}
+			return err
+		}
+		if err := validateRunTransition(runID, current, RunStateCancelRequested); err != nil {
+			return err
+		}
+
+		_, err := tx.ExecContext(ctx, `
+UPDATE runs
+SET state = $2,
+    timeout_requested_at = $3,
+    updated_at = NOW()
+WHERE id = $1
+`, runID, RunStateCancelRequested, now)
+		return err
+	})
+}
+
+// ListStalledStops returns CANCEL_REQUESTED attempts whose own or whose run's
+// timeout was requested at or before requestedBefore.
+func (s *Store) ListStalledStops(ctx context.Context, requestedBefore time.Time, limit int) ([]StalledStop, error) {
+	if limit <= 0 {
+		limit = 10
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+SELECT a.id, a.job_id, j.run_id, a.timeout_requested_at IS NOT NULL
+FROM job_attempts a
+JOIN jobs j ON j.id = a.job_id
+JOIN runs r ON r.id = j.run_id
+WHERE a.state = 'CANCEL_REQUESTED'
+  AND (a.timeout_requested_at <= $1 OR r.timeout_requested_at <= $1)
+ORDER BY COALESCE(a.timeout_requested_at, r.timeout_requested_at) ASC, a.id ASC
+LIMIT $2
+`, requestedBefore, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stops []StalledStop
+	for rows.Next() {
+		var stop StalledStop
+		if err := rows.Scan(&stop.AttemptID, &stop.JobID, &stop.RunID, &stop.JobTimedOut); err != nil {
+			return nil, err
+		}
+		stops = append(stops, stop)
+	}
+	return stops, rows.Err()
+}
+
+// ForceStopJobAttempt ends a CANCEL_REQUESTED attempt whose runner never
+// acknowledged the cancel: the attempt and job move to final (CANCELED or
+// TIMED_OUT), any live lease is revoked and the runner holding it is freed.
+// A TIMED_OUT attempt may be retried with next, as in FinishJobAttempt; the
+// result reports whether it was queued.
+func (s *Store) ForceStopJobAttempt(ctx context.Context, attemptID string, final JobState, next *JobAttempt, now time.Time) (bool, error) {
+	if now.IsZero() {
+		now = time.Now().UTC()
+	}
+
+	var retried bool
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		var jobID string
+		var attemptState, jobState JobState
+		if err := tx.QueryRowContext(ctx, `
+SELECT a.job_id, a.state, j.state
+FROM job_attempts a
+JOIN jobs j ON j.id = a.job_id
+WHERE a.id = $1
+FOR UPDATE
+`, attemptID).Scan(&jobID, &attemptState, &jobState); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("%w: job attempt %s", ErrNotFound, attemptID)
+			}
+			return err
+		}
+		if attemptState != JobStateCancelRequested {
+			return TransitionError{Entity: "job", ID: attemptID, From: string(attemptState), To: string(final)}
+		}
+		if final != JobStateTimedOut {
+			next = nil
+		}
+		var err error
+		if retried, err = finishJobAttempt(ctx, tx, attemptID, final, next); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE job_attempts SET completed_at = $2 WHERE id = $1`, attemptID, now); err != nil {
+			return err
+		}
+
+		var leaseID string
+		var runnerID sql.NullString
+		err = tx.QueryRowContext(ctx, `
+SELECT id, runner_id
+FROM leases
+WHERE job_attempt_id = $1
+  AND state IN ('GRANTED', 'ACTIVE')
+FOR UPDATE
+`, attemptID).Scan(&leaseID, &runnerID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, `
+UPDATE leases
+SET state = $2,
+    updated_at = NOW()
+WHERE id = $1
+`, leaseID, LeaseStateRevoked); err != nil {
+			return err
+		}
+		if runnerID.Valid {
+			if _, err := tx.ExecContext(ctx, `
+UPDATE runners
+SET current_lease_id = NULL,
+    state = CASE WHEN state = 'BUSY' THEN 'IDLE' ELSE state END,
+    updated_at = NOW()
+WHERE id = $1
+  AND current_lease_id = $2
+`, runnerID.String, leaseID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return retried, err
+}
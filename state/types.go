@@ -2,15 +2,45 @@ package state
 
 import "time"
 
-// Run represents a CI run.
+// Run represents a CI run. FullRun asks the planner to skip diff-based
+// pruning; Parameters are free-form inputs passed to the planner and to jobs.
+// TimeoutRequestedAt is set once the run exceeded its runtime limit and is
+// being stopped; the run then ends TIMEOUT rather than CANCELED. SupersededBy
+// names the newer run for the same PR or branch that canceled this one.
 type Run struct {
-	ID        string    `json:"id"`
-	RepoID    string    `json:"repo_id"`
-	Ref       string    `json:"ref"`
-	CommitSHA string    `json:"commit_sha"`
-	State     RunState  `json:"state"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID                 string            `json:"id"`
+	RepoID             string            `json:"repo_id"`
+	RepoURL            string            `json:"repo_url,omitempty"`
+	Ref                string            `json:"ref"`
+	CommitSHA          string            `json:"commit_sha"`
+	FullRun            bool              `json:"full_run"`
+	Parameters         map[string]string `json:"parameters,omitempty"`
+	State              RunState          `json:"state"`
+	TimeoutRequestedAt *time.Time        `json:"timeout_requested_at,omitempty"`
+	SupersededBy       *string           `json:"superseded_by,omitempty"`
+	CreatedAt          time.Time         `json:"created_at"`
+	UpdatedAt          time.Time         `json:"updated_at"`
+}
+
+// RunSummary is a run as returned by run listings, with how it was triggered
+// and how many of its jobs are in each phase.
+type RunSummary struct {
+	Run
+	TriggerType string    `json:"trigger_type"`
+	PRNumber    *int      `json:"pr_number,omitempty"`
+	JobCounts   JobCounts `json:"job_counts"`
+}
+
+// JobCounts groups a run's jobs by phase. Pending covers CREATED and QUEUED,
+// Running covers everything leased but not finished, and Failed includes
+// TIMED_OUT and STALE jobs.
+type JobCounts struct {
+	Total     int `json:"total"`
+	Pending   int `json:"pending"`
+	Running   int `json:"running"`
+	Succeeded int `json:"succeeded"`
+	Failed    int `json:"failed"`
+	Canceled  int `json:"canceled"`
 }
 
 // Job represents a logical unit of work within a run.
@@ -37,6 +67,22 @@ type JobAttempt struct {
 	UpdatedAt     time.Time  `json:"updated_at"`
 	StartedAt     *time.Time `json:"started_at,omitempty"`
 	CompletedAt   *time.Time `json:"completed_at,omitempty"`
+	// TimeoutRequestedAt is set when the attempt exceeded the job timeout and
+	// its runner was asked to stop; the attempt then ends TIMED_OUT.
+	TimeoutRequestedAt *time.Time `json:"timeout_requested_at,omitempty"`
+	// Progress is the latest progress reported in a heartbeat.
+	Progress *JobProgress `json:"progress,omitempty"`
+}
+
+// JobProgress is a running attempt's position as reported by its runner.
+type JobProgress struct {
+	StepIndex      int       `json:"step_index"`
+	StepCount      int       `json:"step_count"`
+	StepName       string    `json:"step_name,omitempty"`
+	ElapsedSeconds int64     `json:"elapsed_seconds"`
+	LogBytes       int64     `json:"log_bytes"`
+	Status         string    `json:"status,omitempty"`
+	UpdatedAt      time.Time `json:"updated_at"`
 }
 
 // Lease represents an execution lease for a job attempt.
@@ -55,10 +101,25 @@ type Lease struct {
 	CompletedAt              *time.Time `json:"completed_at,omitempty"`
 }
 
+// Runner is a registered runner as last seen by the orchestrator.
+type Runner struct {
+	ID             string      `json:"id"`
+	Version        string      `json:"version,omitempty"`
+	Labels         []string    `json:"labels"`
+	State          RunnerState `json:"state"`
+	CurrentLeaseID *string     `json:"current_lease_id,omitempty"`
+	ExpiredLeases  int         `json:"expired_leases"`
+	LastSeenAt     time.Time   `json:"last_seen_at"`
+	RegisteredAt   time.Time   `json:"registered_at"`
+	UpdatedAt      time.Time   `json:"updated_at"`
+}
+
 // ArtifactRef is a lightweight reference to an external artifact.
 type ArtifactRef struct {
-	Type string `json:"type"`
-	URI  string `json:"uri"`
+	Type      string `json:"type"`
+	URI       string `json:"uri"`
+	SizeBytes int64  `json:"size_bytes,omitempty"`
+	SHA256    string `json:"sha256,omitempty"`
 }
 
 // Artifact represents a stored artifact reference for a job attempt.
@@ -67,6 +128,8 @@ type Artifact struct {
 	JobAttemptID string    `json:"job_attempt_id"`
 	Type         string    `json:"type"`
 	URI          string    `json:"uri"`
+	SizeBytes    int64     `json:"size_bytes,omitempty"`
+	SHA256       string    `json:"sha256,omitempty"`
 	CreatedAt    time.Time `json:"created_at"`
 }
 
@@ -121,11 +184,13 @@ type RunTrigger struct {
 
 // StatusReport stores outbound VCS reporting metadata for a run.
 type StatusReport struct {
-	RunID       string    `json:"run_id"`
-	Provider    string    `json:"provider"`
-	CheckRunID  *string   `json:"check_run_id,omitempty"`
-	PRCommentID *string   `json:"pr_comment_id,omitempty"`
-	LastState   string    `json:"last_state"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	RunID       string  `json:"run_id"`
+	Provider    string  `json:"provider"`
+	CheckRunID  *string `json:"check_run_id,omitempty"`
+	PRCommentID *string `json:"pr_comment_id,omitempty"`
+	LastState   string  `json:"last_state"`
+	// LastProgress fingerprints the job progress shown in the last report.
+	LastProgress string    `json:"last_progress"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
 }